@@ -0,0 +1,34 @@
+package realip
+
+import (
+	"net"
+	"net/http"
+	"strconv"
+)
+
+// AddrFromRequest returns the client address FromRequestHostPort would
+// return as a *net.TCPAddr, for APIs keyed on a net.Addr, such as rate
+// limiters. The port is best-effort: it is the source port recorded with the
+// address, which proxies often leave out, and is zero when there is none. A
+// nil net.Addr is returned when no valid address is found.
+func (e *Extractor) AddrFromRequest(r *http.Request) net.Addr {
+	ip, port := e.FromRequestHostPort(r)
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return nil
+	}
+
+	// The port was validated when it was recorded, so it always parses
+	p, _ := strconv.Atoi(port)
+	if ipv4 := parsed.To4(); ipv4 != nil {
+		parsed = ipv4
+	}
+
+	return &net.TCPAddr{IP: parsed, Port: p}
+}
+
+// AddrFromRequest returns client's real public IP address from http request
+// headers as a *net.TCPAddr, with the recorded source port or a zero port.
+func AddrFromRequest(r *http.Request) net.Addr {
+	return defaultExtractor.AddrFromRequest(r)
+}
@@ -0,0 +1,42 @@
+package realip
+
+import (
+	"net"
+	"net/http"
+	"testing"
+)
+
+func TestAddrFromRequest(t *testing.T) {
+	testData := []struct {
+		name       string
+		remoteAddr string
+		header     http.Header
+		expected   string
+	}{
+		{name: "RemoteAddr", remoteAddr: "144.12.54.87:51234", header: http.Header{}, expected: "144.12.54.87:51234"},
+		{name: "RemoteAddr IPv6", remoteAddr: "[2a00:1450::1]:443", header: http.Header{}, expected: "[2a00:1450::1]:443"},
+		{name: "Forwarded with port", remoteAddr: "10.0.0.1:8080", header: http.Header{"Forwarded": {`for="[2a00:1450::1]:4711"`}}, expected: "[2a00:1450::1]:4711"},
+		{name: "X-Forwarded-For without port", remoteAddr: "10.0.0.1:8080", header: http.Header{"X-Forwarded-For": {"144.12.54.87"}}, expected: "144.12.54.87:0"},
+		{name: "No address", remoteAddr: "10.0.0.1:8080", header: http.Header{"X-Forwarded-For": {"192.168.0.1"}}, expected: ""},
+	}
+
+	for _, v := range testData {
+		r := &http.Request{RemoteAddr: v.remoteAddr, Header: v.header}
+		addr := AddrFromRequest(r)
+		if v.expected == "" {
+			if addr != nil {
+				t.Errorf("%s: expected no address but get %s", v.name, addr)
+			}
+			continue
+		}
+
+		tcpAddr, ok := addr.(*net.TCPAddr)
+		if !ok {
+			t.Errorf("%s: expected a *net.TCPAddr but get %T", v.name, addr)
+			continue
+		}
+		if actual := tcpAddr.String(); actual != v.expected {
+			t.Errorf("%s: expected %s but get %s", v.name, v.expected, actual)
+		}
+	}
+}
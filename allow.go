@@ -0,0 +1,29 @@
+package realip
+
+import (
+	"net"
+	"net/http"
+)
+
+// Allowed resolves the client's IP address and reports whether it lies
+// within one of the allow networks. IPv4-mapped IPv6 addresses match the
+// IPv4 networks. It returns false when no valid address could be resolved.
+func (e *Extractor) Allowed(r *http.Request, allow []*net.IPNet) bool {
+	address, err := e.FromRequestE(r)
+	if err != nil {
+		return false
+	}
+
+	ip, err := parseAddress(address)
+	if err != nil {
+		return false
+	}
+
+	for _, n := range allow {
+		if prefix, ok := prefixFromIPNet(n); ok && prefix.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,34 @@
+package realip
+
+import (
+	"net"
+	"net/http"
+	"testing"
+)
+
+func TestAllowed(t *testing.T) {
+	_, v4, _ := net.ParseCIDR("203.0.113.0/24")
+	_, v6, _ := net.ParseCIDR("2001:db8::/32")
+	allow := []*net.IPNet{v4, v6}
+
+	testData := []struct {
+		name       string
+		remoteAddr string
+		expected   bool
+	}{
+		{name: "IPv4 allowed", remoteAddr: "203.0.113.5:8080", expected: true},
+		{name: "IPv4 denied", remoteAddr: "198.51.100.5:8080", expected: false},
+		{name: "IPv6 allowed", remoteAddr: "[2001:db8::1]:443", expected: true},
+		{name: "IPv6 denied", remoteAddr: "[2001:4860::8888]:443", expected: false},
+		{name: "IPv4-mapped allowed", remoteAddr: "[::ffff:203.0.113.5]:8080", expected: true},
+		{name: "Unresolvable", remoteAddr: "@", expected: false},
+	}
+
+	e := New()
+	for _, v := range testData {
+		r := &http.Request{RemoteAddr: v.remoteAddr, Header: http.Header{}}
+		if actual := e.Allowed(r, allow); actual != v.expected {
+			t.Errorf("%s: expected %t but get %t", v.name, v.expected, actual)
+		}
+	}
+}
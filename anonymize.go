@@ -0,0 +1,38 @@
+package realip
+
+import (
+	"fmt"
+	"net"
+)
+
+// Prefix lengths kept by Anonymize.
+const (
+	anonymizeV4Bits = 24
+	anonymizeV6Bits = 48
+)
+
+// Anonymize truncates ip for storage in logs and analytics: the last octet
+// of an IPv4 address and the last 80 bits of an IPv6 address are zeroed.
+// IPv4-mapped IPv6 addresses are anonymized as IPv4 addresses. It returns a
+// new net.IP, or nil if ip is not a valid address.
+func Anonymize(ip net.IP) net.IP {
+	if v4 := ip.To4(); v4 != nil {
+		return v4.Mask(net.CIDRMask(anonymizeV4Bits, 8*net.IPv4len))
+	}
+	if len(ip) != net.IPv6len {
+		return nil
+	}
+
+	return ip.Mask(net.CIDRMask(anonymizeV6Bits, 8*net.IPv6len))
+}
+
+// AnonymizeString is like Anonymize for an address in text form, such as the
+// result of FromRequest. It returns ErrInvalidIP if s does not parse.
+func AnonymizeString(s string) (string, error) {
+	ip := net.ParseIP(s)
+	if ip == nil {
+		return "", fmt.Errorf("%w: %q", ErrInvalidIP, s)
+	}
+
+	return Anonymize(ip).String(), nil
+}
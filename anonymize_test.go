@@ -0,0 +1,39 @@
+package realip
+
+import (
+	"errors"
+	"net"
+	"testing"
+)
+
+func TestAnonymize(t *testing.T) {
+	testData := map[string]string{
+		"144.12.54.87":                  "144.12.54.0",
+		"::ffff:144.12.54.87":           "144.12.54.0",
+		"2a00:1450:4001:82b::200e":      "2a00:1450:4001::",
+		"2a00:1450:ffff:ffff:ffff::1:2": "2a00:1450:ffff::",
+		"10.0.0.255":                    "10.0.0.0",
+	}
+
+	for addr, expected := range testData {
+		ip := net.ParseIP(addr)
+		original := append(net.IP(nil), ip...)
+		if actual := Anonymize(ip); actual.String() != expected {
+			t.Errorf("%s: expected %s but get %s", addr, expected, actual)
+		}
+		if !ip.Equal(original) {
+			t.Errorf("%s: input modified to %s", addr, ip)
+		}
+
+		if actual, err := AnonymizeString(addr); actual != expected || err != nil {
+			t.Errorf("%s: expected %s but get %s (%v)", addr, expected, actual, err)
+		}
+	}
+
+	if actual := Anonymize(net.IP{1, 2, 3}); actual != nil {
+		t.Errorf("invalid: expected nil but get %s", actual)
+	}
+	if actual, err := AnonymizeString("not-an-ip"); actual != "" || !errors.Is(err, ErrInvalidIP) {
+		t.Errorf("not-an-ip: expected %v but get %s (%v)", ErrInvalidIP, actual, err)
+	}
+}
@@ -0,0 +1,65 @@
+package realip
+
+// AuditKind identifies why an AuditEvent was emitted.
+type AuditKind int
+
+const (
+	// AuditUntrustedPeer is emitted when a peer that is not a trusted proxy
+	// sent forwarding headers, which were ignored.
+	AuditUntrustedPeer AuditKind = iota + 1
+
+	// AuditSpoofingDetected is emitted when the forwarding headers relayed
+	// by a trusted proxy contradict each other.
+	AuditSpoofingDetected
+)
+
+// String returns the name of the audit kind.
+func (k AuditKind) String() string {
+	switch k {
+	case AuditUntrustedPeer:
+		return "untrusted-peer"
+	case AuditSpoofingDetected:
+		return "spoofing-detected"
+	default:
+		return "unknown"
+	}
+}
+
+// AuditEvent describes a request whose forwarding headers were rejected.
+type AuditEvent struct {
+	Kind       AuditKind
+	RemoteAddr string
+
+	// Raw values of the forwarding headers of the request
+	XForwardedFor []string
+	Forwarded     []string
+	XRealIP       []string
+
+	// IP is the address the request was resolved to, if any, and Source
+	// and Trusted describe it as FromRequestDetailed does
+	IP      string
+	Source  Source
+	Trusted bool
+}
+
+// audit emits an event of the given kind to the audit sink, if any, for a
+// request resolved to ip for reason.
+func (e *Extractor) audit(kind AuditKind, in input, ip string, reason Reason) {
+	if e.auditSink == nil {
+		return
+	}
+
+	event := AuditEvent{
+		Kind:          kind,
+		RemoteAddr:    in.remoteAddr,
+		XForwardedFor: e.values(in, xForwardedForHeader),
+		Forwarded:     e.values(in, forwardedHeader),
+		XRealIP:       e.values(in, xRealIpHeader),
+		IP:            ip,
+	}
+	if ip != "" {
+		result := e.source(in, ip, reason)
+		event.Source, event.Trusted = result.Source, result.Trusted
+	}
+	e.auditSink(event)
+}
@@ -0,0 +1,60 @@
+package realip
+
+import (
+	"net"
+	"net/http"
+	"testing"
+)
+
+func TestWithAuditSink(t *testing.T) {
+	var events []AuditEvent
+	_, trusted, _ := net.ParseCIDR("10.0.0.0/8")
+	e := New(WithTrustedProxies(trusted), WithAuditSink(func(event AuditEvent) {
+		events = append(events, event)
+	}))
+
+	testData := []struct {
+		name     string
+		request  *http.Request
+		expected AuditKind
+	}{
+		{
+			name: "Spoofing",
+			request: &http.Request{RemoteAddr: "10.0.0.1:8080", Header: http.Header{
+				"X-Forwarded-For": {"144.12.54.87"},
+				"Forwarded":       {"for=119.14.55.11"},
+			}},
+			expected: AuditSpoofingDetected,
+		}, {
+			name: "Untrusted peer",
+			request: &http.Request{RemoteAddr: "119.14.55.11:8080", Header: http.Header{
+				"X-Forwarded-For": {"144.12.54.87"},
+			}},
+			expected: AuditUntrustedPeer,
+		}, {
+			name: "Trusted peer",
+			request: &http.Request{RemoteAddr: "10.0.0.1:8080", Header: http.Header{
+				"X-Forwarded-For": {"144.12.54.87"},
+			}},
+		},
+	}
+
+	for _, v := range testData {
+		events = nil
+		e.FromRequest(v.request)
+
+		switch {
+		case v.expected == 0 && len(events) != 0:
+			t.Errorf("%s: expected no event but get %+v", v.name, events)
+		case v.expected != 0 && (len(events) != 1 || events[0].Kind != v.expected):
+			t.Errorf("%s: expected one %s event but get %+v", v.name, v.expected, events)
+		case v.expected != 0 && events[0].RemoteAddr != v.request.RemoteAddr:
+			t.Errorf("%s: expected remote address %s but get %s", v.name, v.request.RemoteAddr, events[0].RemoteAddr)
+		case v.expected != 0:
+			event, detailed := events[0], e.FromRequestDetailed(v.request)
+			if event.IP != detailed.IP || event.Source != detailed.Source || event.Trusted != detailed.Trusted {
+				t.Errorf("%s: expected %+v but get %+v", v.name, detailed, event)
+			}
+		}
+	}
+}
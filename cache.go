@@ -0,0 +1,97 @@
+package realip
+
+import (
+	"context"
+	"net"
+	"sync"
+)
+
+// connCacheKey is the context key of the per-connection cache.
+type connCacheKey struct{}
+
+// connCache memoizes the last resolution made for requests on a connection.
+type connCache struct {
+	mu     sync.Mutex
+	valid  bool
+	key    connCacheEntryKey
+	ip     string
+	reason Reason
+	err    error
+}
+
+// connCacheEntryKey holds everything a resolution depends on.
+type connCacheEntryKey struct {
+	// The Extractor that resolved the request, as Extractors configured
+	// differently may share a connection
+	extractor  *Extractor
+	remoteAddr string
+
+	// Values of the headers of the header chain, in order
+	headers [][]string
+}
+
+// equal reports whether k and o hold the same request data.
+func (k *connCacheEntryKey) equal(o *connCacheEntryKey) bool {
+	if k.extractor != o.extractor || k.remoteAddr != o.remoteAddr || len(k.headers) != len(o.headers) {
+		return false
+	}
+	for i := range k.headers {
+		if !equalStrings(k.headers[i], o.headers[i]) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// equalStrings reports whether a and b hold the same strings.
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// ConnContext installs a per-connection resolution cache in the context of
+// a connection, for use by Extractors created with WithConnectionCache. It
+// has the signature of http.Server.ConnContext:
+//
+//	srv := &http.Server{ConnContext: realip.ConnContext}
+//
+// The cache lives as long as the connection, so it never outgrows the
+// number of open connections.
+func ConnContext(ctx context.Context, _ net.Conn) context.Context {
+	return context.WithValue(ctx, connCacheKey{}, &connCache{})
+}
+
+// resolveCached resolves the client address, reusing the previous result held
+// by c when the request carries the same forwarding headers and remote
+// address as the previous one, and was resolved by the same Extractor.
+func (e *Extractor) resolveCached(in input, c *connCache) (string, Reason, error) {
+	key := connCacheEntryKey{extractor: e, remoteAddr: in.remoteAddr, headers: make([][]string, len(e.headerChain))}
+	for i, spec := range e.headerChain {
+		key.headers[i] = e.values(in, spec.Name)
+	}
+
+	c.mu.Lock()
+	if c.valid && c.key.equal(&key) {
+		ip, reason, err := c.ip, c.reason, c.err
+		c.mu.Unlock()
+		return ip, reason, err
+	}
+	c.mu.Unlock()
+
+	ip, reason, err := e.resolveRequest(in)
+
+	c.mu.Lock()
+	c.valid, c.key, c.ip, c.reason, c.err = true, key, ip, reason, err
+	c.mu.Unlock()
+
+	return ip, reason, err
+}
@@ -0,0 +1,76 @@
+package realip
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"testing"
+)
+
+func TestWithConnectionCache(t *testing.T) {
+	e := New(WithConnectionCache())
+	ctx := ConnContext(context.Background(), nil)
+	c := ctx.Value(connCacheKey{}).(*connCache)
+
+	newRequest := func(xForwardedFor string) *http.Request {
+		r := &http.Request{
+			RemoteAddr: "10.0.0.1:8080",
+			Header:     http.Header{"X-Forwarded-For": {xForwardedFor}},
+		}
+		return r.WithContext(ctx)
+	}
+
+	if actual := e.FromRequest(newRequest("144.12.54.87")); actual != "144.12.54.87" {
+		t.Errorf("miss: expected %s but get %s", "144.12.54.87", actual)
+	}
+
+	// Tamper with the cached result to observe whether it is served
+	c.ip = "119.14.55.11"
+	if actual := e.FromRequest(newRequest("144.12.54.87")); actual != "119.14.55.11" {
+		t.Errorf("hit: expected the cached %s but get %s", "119.14.55.11", actual)
+	}
+
+	if actual := e.FromRequest(newRequest("13.182.55.11")); actual != "13.182.55.11" {
+		t.Errorf("changed headers: expected %s but get %s", "13.182.55.11", actual)
+	}
+
+	r := &http.Request{Header: http.Header{"X-Forwarded-For": {"144.12.54.87"}}}
+	if actual := e.FromRequest(r); actual != "144.12.54.87" {
+		t.Errorf("no cache: expected %s but get %s", "144.12.54.87", actual)
+	}
+}
+
+func TestWithConnectionCacheHeaderChain(t *testing.T) {
+	e := New(WithConnectionCache(), WithHeaders(CFConnectingIPHeader))
+	ctx := ConnContext(context.Background(), nil)
+
+	newRequest := func(client string) *http.Request {
+		r := &http.Request{RemoteAddr: "10.0.0.1:8080", Header: http.Header{"Cf-Connecting-Ip": {client}}}
+		return r.WithContext(ctx)
+	}
+
+	for _, client := range []string{"144.12.54.87", "119.14.55.11"} {
+		if actual := e.FromRequest(newRequest(client)); actual != client {
+			t.Errorf("%s: expected %s but get %s", client, client, actual)
+		}
+	}
+}
+
+func TestWithConnectionCacheExtractors(t *testing.T) {
+	_, proxies, _ := net.ParseCIDR("10.0.0.0/8")
+	trusted := New(WithConnectionCache(), WithTrustedProxies(proxies))
+	plain := New(WithConnectionCache())
+	ctx := ConnContext(context.Background(), nil)
+
+	r := &http.Request{RemoteAddr: "119.14.55.11:8080", Header: http.Header{"X-Forwarded-For": {"144.12.54.87"}}}
+	r = r.WithContext(ctx)
+
+	for i := 0; i < 2; i++ {
+		if actual := trusted.FromRequest(r); actual != "119.14.55.11" {
+			t.Errorf("trusted: expected %s but get %s", "119.14.55.11", actual)
+		}
+		if actual := plain.FromRequest(r); actual != "144.12.54.87" {
+			t.Errorf("plain: expected %s but get %s", "144.12.54.87", actual)
+		}
+	}
+}
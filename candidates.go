@@ -0,0 +1,152 @@
+package realip
+
+import (
+	"net/http"
+	"sort"
+)
+
+// Source identifies where a client address was found.
+type Source int
+
+const (
+	// SourceRemoteAddr is the address of the peer of the connection.
+	SourceRemoteAddr Source = iota + 1
+
+	// SourceXForwardedFor is an address of the X-Forwarded-For header.
+	SourceXForwardedFor
+
+	// SourceForwarded is a for address of the Forwarded header.
+	SourceForwarded
+
+	// SourceXRealIP is the address of the X-Real-IP header.
+	SourceXRealIP
+
+	// SourceHeader is an address of another header of the header chain,
+	// e.g. Cf-Connecting-Ip.
+	SourceHeader
+)
+
+// String returns the name of the source.
+func (s Source) String() string {
+	switch s {
+	case SourceRemoteAddr:
+		return "remote-addr"
+	case SourceXForwardedFor:
+		return "x-forwarded-for"
+	case SourceForwarded:
+		return "forwarded"
+	case SourceXRealIP:
+		return "x-real-ip"
+	case SourceHeader:
+		return "header"
+	default:
+		return "unknown"
+	}
+}
+
+// Candidate is a plausible client address of a request.
+type Candidate struct {
+	IP     string
+	Source Source
+
+	// Trusted reports whether the address was recorded by the peer of the
+	// connection or by a trusted proxy that every proxy between it and the
+	// server vouches for.
+	Trusted bool
+}
+
+// RankedCandidates returns every valid address of the request as a
+// candidate client address, trusted candidates first. Within each group,
+// candidates are ordered from the closest to the server to the farthest:
+// the peer of the connection, then the X-Forwarded-For and the Forwarded
+// addresses from right to left, then X-Real-IP.
+//
+// Only the peer of the connection is trusted unless trusted proxies or a
+// number of trusted hops are configured.
+func (e *Extractor) RankedCandidates(r *http.Request) []Candidate {
+	return e.rankedCandidates(requestInput(r))
+}
+
+// rankedCandidates implements RankedCandidates.
+func (e *Extractor) rankedCandidates(in input) []Candidate {
+	remote := remoteIP(in)
+	var candidates []Candidate
+	if _, err := parseAddress(remote); err == nil {
+		candidates = append(candidates, Candidate{IP: remote, Source: SourceRemoteAddr, Trusted: true})
+	}
+
+	candidates = e.appendCandidates(candidates, in, SourceXForwardedFor, e.walkXForwardedForReverse)
+	candidates = e.appendCandidates(candidates, in, SourceForwarded, e.walkForwardedReverse)
+	if xRealIP := e.xRealIP(in); xRealIP != "" {
+		if _, err := parseAddress(xRealIP); err == nil {
+			candidates = append(candidates, Candidate{IP: xRealIP, Source: SourceXRealIP, Trusted: e.vouches(remote, 0)})
+		}
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].Trusted && !candidates[j].Trusted
+	})
+
+	return candidates
+}
+
+// appendCandidates appends the valid addresses of a forwarding header, which
+// reverse walks from right to left, to candidates. An address is trusted as
+// long as every address to its right, and the peer, vouches for it.
+func (e *Extractor) appendCandidates(candidates []Candidate, in input, source Source, reverse func(input, func(string) bool) bool) []Candidate {
+	trusted, hops, presenter := true, 0, remoteIP(in)
+	reverse(in, func(address string) bool {
+		if _, err := parseAddress(address); err != nil {
+			return false
+		}
+		trusted = trusted && e.vouches(presenter, hops)
+		candidates = append(candidates, Candidate{IP: address, Source: source, Trusted: trusted})
+		hops++
+		presenter = address
+		return false
+	})
+
+	return candidates
+}
+
+// vouches reports whether the address recorded by presenter, hops addresses
+// away from the peer of the connection, can be trusted.
+func (e *Extractor) vouches(presenter string, hops int) bool {
+	return e.isTrusted(presenter) || e.trustedHops > 0 && hops <= e.trustedHops
+}
+
+// RateLimitKey returns the client address of the request, formatted like
+// FromRequest, as a rate limiting key, and whether it is trustworthy: it is
+// the peer of the connection or was recorded by trusted proxies, as ranked by
+// RankedCandidates. Untrustworthy keys may have been chosen by the client, and
+// call for a stricter, shared limit.
+func (e *Extractor) RateLimitKey(r *http.Request) (key string, trustworthy bool) {
+	ip, err := e.resolve(r)
+	ip = e.accept(ip)
+	if err == nil && ip != "" {
+		for _, candidate := range e.RankedCandidates(r) {
+			if !candidate.Trusted {
+				break
+			}
+			if candidate.IP == ip {
+				trustworthy = true
+				break
+			}
+		}
+	}
+
+	return e.finish(ip), trustworthy
+}
+
+// RankedCandidates returns every valid address of the request as a
+// candidate client address, the peer of the connection first.
+func RankedCandidates(r *http.Request) []Candidate {
+	return defaultExtractor.RankedCandidates(r)
+}
+
+// RateLimitKey returns the client address of the request as a rate limiting
+// key, and whether it is trustworthy. Without trusted proxies, only the peer
+// of the connection is.
+func RateLimitKey(r *http.Request) (key string, trustworthy bool) {
+	return defaultExtractor.RateLimitKey(r)
+}
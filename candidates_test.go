@@ -0,0 +1,139 @@
+package realip
+
+import (
+	"net"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestRankedCandidates(t *testing.T) {
+	_, trusted, _ := net.ParseCIDR("10.0.0.0/8")
+	r := &http.Request{RemoteAddr: "10.0.0.1:8080", Header: http.Header{
+		"X-Forwarded-For": {"1.2.3.4, 144.12.54.87, garbage, 10.0.0.2"},
+		"Forwarded":       {"for=13.182.55.11"},
+		"X-Real-Ip":       {"119.14.55.11"},
+	}}
+
+	testData := []struct {
+		name      string
+		extractor *Extractor
+		expected  []Candidate
+	}{
+		{
+			name:      "Trusted proxies",
+			extractor: New(WithTrustedProxies(trusted)),
+			expected: []Candidate{
+				{IP: "10.0.0.1", Source: SourceRemoteAddr, Trusted: true},
+				{IP: "10.0.0.2", Source: SourceXForwardedFor, Trusted: true},
+				{IP: "144.12.54.87", Source: SourceXForwardedFor, Trusted: true},
+				{IP: "13.182.55.11", Source: SourceForwarded, Trusted: true},
+				{IP: "119.14.55.11", Source: SourceXRealIP, Trusted: true},
+				{IP: "1.2.3.4", Source: SourceXForwardedFor, Trusted: false},
+			},
+		}, {
+			name:      "Trusted hops",
+			extractor: New(WithTrustedHops(1)),
+			expected: []Candidate{
+				{IP: "10.0.0.1", Source: SourceRemoteAddr, Trusted: true},
+				{IP: "10.0.0.2", Source: SourceXForwardedFor, Trusted: true},
+				{IP: "144.12.54.87", Source: SourceXForwardedFor, Trusted: true},
+				{IP: "13.182.55.11", Source: SourceForwarded, Trusted: true},
+				{IP: "119.14.55.11", Source: SourceXRealIP, Trusted: true},
+				{IP: "1.2.3.4", Source: SourceXForwardedFor, Trusted: false},
+			},
+		}, {
+			name:      "No trust",
+			extractor: New(),
+			expected: []Candidate{
+				{IP: "10.0.0.1", Source: SourceRemoteAddr, Trusted: true},
+				{IP: "10.0.0.2", Source: SourceXForwardedFor, Trusted: false},
+				{IP: "144.12.54.87", Source: SourceXForwardedFor, Trusted: false},
+				{IP: "1.2.3.4", Source: SourceXForwardedFor, Trusted: false},
+				{IP: "13.182.55.11", Source: SourceForwarded, Trusted: false},
+				{IP: "119.14.55.11", Source: SourceXRealIP, Trusted: false},
+			},
+		},
+	}
+
+	for _, v := range testData {
+		if actual := v.extractor.RankedCandidates(r); !reflect.DeepEqual(actual, v.expected) {
+			t.Errorf("%s: expected %v but get %v", v.name, v.expected, actual)
+		}
+	}
+}
+
+func TestRateLimitKey(t *testing.T) {
+	_, trusted, _ := net.ParseCIDR("10.0.0.0/8")
+	proxied := New(WithTrustedProxies(trusted))
+
+	testData := []struct {
+		name        string
+		extractor   *Extractor
+		request     *http.Request
+		key         string
+		trustworthy bool
+	}{
+		{
+			name:        "Direct connection",
+			extractor:   New(),
+			request:     &http.Request{RemoteAddr: "144.12.54.87:8080", Header: http.Header{}},
+			key:         "144.12.54.87",
+			trustworthy: true,
+		}, {
+			name:      "Untrusted header",
+			extractor: New(),
+			request: &http.Request{RemoteAddr: "10.0.0.1:8080", Header: http.Header{
+				"X-Forwarded-For": {"144.12.54.87"},
+			}},
+			key: "144.12.54.87",
+		}, {
+			name:      "Trusted proxy",
+			extractor: proxied,
+			request: &http.Request{RemoteAddr: "10.0.0.1:8080", Header: http.Header{
+				"X-Forwarded-For": {"1.2.3.4, 144.12.54.87, 10.0.0.2"},
+			}},
+			key:         "144.12.54.87",
+			trustworthy: true,
+		}, {
+			name:      "Untrusted private peer",
+			extractor: proxied,
+			request: &http.Request{RemoteAddr: "192.168.0.1:8080", Header: http.Header{
+				"X-Forwarded-For": {"144.12.54.87"},
+			}},
+			key: "192.168.0.1",
+		}, {
+			name:      "Aggregated key",
+			extractor: New(WithTrustedProxies(trusted), WithAggregateToPrefix(24, 48)),
+			request: &http.Request{RemoteAddr: "10.0.0.1:8080", Header: http.Header{
+				"X-Forwarded-For": {"144.12.54.87"},
+			}},
+			key:         "144.12.54.0",
+			trustworthy: true,
+		},
+	}
+
+	for _, v := range testData {
+		key, trustworthy := v.extractor.RateLimitKey(v.request)
+		if key != v.key || trustworthy != v.trustworthy {
+			t.Errorf("%s: expected (%s, %t) but get (%s, %t)", v.name, v.key, v.trustworthy, key, trustworthy)
+		}
+	}
+}
+
+func TestSourceString(t *testing.T) {
+	testData := map[Source]string{
+		SourceRemoteAddr:    "remote-addr",
+		SourceXForwardedFor: "x-forwarded-for",
+		SourceForwarded:     "forwarded",
+		SourceXRealIP:       "x-real-ip",
+		SourceHeader:        "header",
+		Source(0):           "unknown",
+	}
+
+	for source, expected := range testData {
+		if actual := source.String(); actual != expected {
+			t.Errorf("%d: expected %s but get %s", source, expected, actual)
+		}
+	}
+}
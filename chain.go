@@ -0,0 +1,82 @@
+package realip
+
+import (
+	"net/http"
+	"net/netip"
+)
+
+// ChainFromRequest returns every valid address found in the X-Forwarded-For
+// and Forwarded headers, in header order, for callers implementing their own
+// selection. Entries are trimmed, Forwarded nodes lose their brackets and
+// ports, and malformed or obfuscated entries are dropped.
+func (e *Extractor) ChainFromRequest(r *http.Request) []string {
+	return e.AppendChain(nil, r)
+}
+
+// AppendChain appends the addresses ChainFromRequest would return to dst and
+// returns the extended slice, allowing callers to reuse a backing slice
+// across requests.
+//
+// With WithCanonicalize, addresses are appended in canonical form, and those
+// already appended by the call are dropped.
+func (e *Extractor) AppendChain(dst []string, r *http.Request) []string {
+	start := len(dst)
+	e.walk(requestInput(r), func(address string) bool {
+		ip, err := parseAddress(address)
+		switch {
+		case err != nil:
+		case !e.canonicalize:
+			dst = append(dst, address)
+		case !containsString(dst[start:], ip.String()):
+			dst = append(dst, ip.String())
+		}
+		return false
+	})
+
+	return dst
+}
+
+// containsString reports whether s is one of values.
+func containsString(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+
+	return false
+}
+
+// UniquePublicChain returns the distinct public addresses found in the
+// X-Forwarded-For and Forwarded headers, in the order they are first seen.
+// Private, malformed and repeated entries are dropped; addresses written
+// differently but parsing to the same IP count as repeats.
+func (e *Extractor) UniquePublicChain(r *http.Request) []string {
+	var chain []string
+	seen := make(map[netip.Addr]bool)
+	e.walk(requestInput(r), func(address string) bool {
+		if !e.isPublic(address) {
+			return false
+		}
+		ip, _ := parseAddress(address)
+		if !seen[ip] {
+			seen[ip] = true
+			chain = append(chain, address)
+		}
+		return false
+	})
+
+	return chain
+}
+
+// ChainFromRequest returns every valid address found in the X-Forwarded-For
+// and Forwarded headers, in header order. Malformed entries are dropped.
+func ChainFromRequest(r *http.Request) []string {
+	return defaultExtractor.ChainFromRequest(r)
+}
+
+// UniquePublicChain returns the distinct public addresses found in the
+// X-Forwarded-For and Forwarded headers, in the order they are first seen.
+func UniquePublicChain(r *http.Request) []string {
+	return defaultExtractor.UniquePublicChain(r)
+}
@@ -0,0 +1,169 @@
+package realip
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestChainFromRequest(t *testing.T) {
+	r := &http.Request{
+		RemoteAddr: "10.0.0.2:8080",
+		Header: http.Header{
+			"X-Forwarded-For": {"144.12.54.87, garbage,10.0.0.1", "119.14.55.11"},
+			"Forwarded":       {"for=13.182.55.11, for=unknown"},
+		},
+	}
+	expected := []string{"144.12.54.87", "10.0.0.1", "119.14.55.11", "13.182.55.11"}
+
+	if actual := ChainFromRequest(r); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("ChainFromRequest: expected %v but get %v", expected, actual)
+	}
+
+	dst := []string{"existing"}
+	if actual := New().AppendChain(dst, r); !reflect.DeepEqual(actual, append([]string{"existing"}, expected...)) {
+		t.Errorf("AppendChain: expected %v after existing but get %v", expected, actual)
+	}
+
+	if actual := ChainFromRequest(&http.Request{Header: http.Header{}}); actual != nil {
+		t.Errorf("No header: expected nil but get %v", actual)
+	}
+}
+
+func TestChainFromRequestNodes(t *testing.T) {
+	r := &http.Request{
+		RemoteAddr: "10.0.0.2:8080",
+		Header: http.Header{
+			"X-Forwarded-For": {" 144.12.54.87 ,, 2a00:1450::1 "},
+			"Forwarded":       {`for="[2a00:1450::2]:4711";proto=https, for="192.0.2.60:8080", for=_hidden, for="[2a00:1450::3"`},
+		},
+	}
+	expected := []string{"144.12.54.87", "2a00:1450::1", "2a00:1450::2", "192.0.2.60"}
+
+	if actual := ChainFromRequest(r); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("ChainFromRequest: expected %v but get %v", expected, actual)
+	}
+}
+
+func TestZonedAddressInChain(t *testing.T) {
+	r := &http.Request{
+		RemoteAddr: "[fe80::1%eth0]:8080",
+		Header:     http.Header{"X-Forwarded-For": {"fe80::2%eth0, 144.12.54.87"}},
+	}
+
+	expected := []string{"fe80::2", "144.12.54.87"}
+	if actual := ChainFromRequest(r); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("ChainFromRequest: expected %v but get %v", expected, actual)
+	}
+
+	expected = []string{"144.12.54.87"}
+	if actual := UniquePublicChain(r); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("UniquePublicChain: expected %v but get %v", expected, actual)
+	}
+
+	if actual := FromRequest(r); actual != "144.12.54.87" {
+		t.Errorf("FromRequest: expected %s but get %s", "144.12.54.87", actual)
+	}
+}
+
+func TestZoneNeverReturned(t *testing.T) {
+	testData := []struct {
+		name       string
+		remoteAddr string
+		header     http.Header
+		expected   string
+	}{
+		{name: "X-Forwarded-For", remoteAddr: "10.0.0.1:8080", header: http.Header{"X-Forwarded-For": {"2a00:1450::1%<script>"}}, expected: "2a00:1450::1"},
+		{name: "X-Forwarded-For with port", remoteAddr: "10.0.0.1:8080", header: http.Header{"X-Forwarded-For": {"[2a00:1450::1%<script>]:443"}}, expected: "2a00:1450::1"},
+		{name: "Forwarded", remoteAddr: "10.0.0.1:8080", header: http.Header{"Forwarded": {`for="[2a00:1450::1%<script>]"`}}, expected: "2a00:1450::1"},
+		{name: "X-Real-IP", remoteAddr: "10.0.0.1:8080", header: http.Header{"X-Real-Ip": {"fe80::1%<script>"}}, expected: "fe80::1"},
+		{name: "X-Real-IP with port", remoteAddr: "10.0.0.1:8080", header: http.Header{"X-Real-Ip": {"[fe80::1%<script>]:443"}}, expected: "fe80::1"},
+		{name: "Remote address", remoteAddr: "[fe80::1%eth0]:8080", header: http.Header{}, expected: "fe80::1"},
+	}
+
+	for _, v := range testData {
+		r := &http.Request{RemoteAddr: v.remoteAddr, Header: v.header}
+		if actual := FromRequest(r); actual != v.expected {
+			t.Errorf("%s: expected %s but get %s", v.name, v.expected, actual)
+		}
+		if actual, err := FromRequestE(r); err == nil && actual != v.expected {
+			t.Errorf("%s: FromRequestE expected %s but get %s", v.name, v.expected, actual)
+		}
+		for _, address := range ChainFromRequest(r) {
+			if strings.Contains(address, "%") {
+				t.Errorf("%s: expected no zone in the chain but get %s", v.name, address)
+			}
+		}
+	}
+}
+
+func TestWithCanonicalize(t *testing.T) {
+	r := &http.Request{RemoteAddr: "10.0.0.1:8080", Header: http.Header{
+		"X-Forwarded-For": {"203.0.113.9, ::ffff:203.0.113.9, 2001:0db8:0:0::1, 10.0.0.2"},
+		"Forwarded":       {`for=203.0.113.9, for="[2001:db8::1]:4711", for="[fe80::1%eth0]", for=10.0.0.2`},
+	}}
+
+	expected := []string{"203.0.113.9", "2001:db8::1", "10.0.0.2", "fe80::1"}
+	if actual := New(WithCanonicalize()).ChainFromRequest(r); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("canonical: expected %v but get %v", expected, actual)
+	}
+
+	expected = []string{"203.0.113.9", "::ffff:203.0.113.9", "2001:0db8:0:0::1", "10.0.0.2", "203.0.113.9", "2001:db8::1", "fe80::1", "10.0.0.2"}
+	if actual := New().ChainFromRequest(r); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("default: expected %v but get %v", expected, actual)
+	}
+
+	// Addresses already in dst are kept
+	expected = []string{"203.0.113.9", "203.0.113.9", "2001:db8::1", "10.0.0.2", "fe80::1"}
+	if actual := New(WithCanonicalize()).AppendChain([]string{"203.0.113.9"}, r); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("append: expected %v but get %v", expected, actual)
+	}
+}
+
+func TestUniquePublicChain(t *testing.T) {
+	r := &http.Request{
+		RemoteAddr: "10.0.0.2:8080",
+		Header: http.Header{
+			"X-Forwarded-For": {"144.12.54.87, 10.0.0.1, garbage, 119.14.55.11", "144.12.54.87, 192.168.0.1, 2a00:1450::1"},
+			"Forwarded":       {`for=119.14.55.11, for="[2a00:1450:0::1]", for=13.182.55.11`},
+		},
+	}
+	expected := []string{"144.12.54.87", "119.14.55.11", "2a00:1450::1", "13.182.55.11"}
+
+	if actual := UniquePublicChain(r); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("UniquePublicChain: expected %v but get %v", expected, actual)
+	}
+
+	private := &http.Request{Header: http.Header{"X-Forwarded-For": {"10.0.0.1, 172.16.0.1"}}}
+	if actual := UniquePublicChain(private); actual != nil {
+		t.Errorf("Private only: expected nil but get %v", actual)
+	}
+}
+
+func benchmarkChainRequest() *http.Request {
+	return &http.Request{
+		Header: http.Header{
+			"X-Forwarded-For": {"144.12.54.87, 10.0.0.1, 119.14.55.11, 13.182.55.11, 192.168.0.1"},
+		},
+	}
+}
+
+func BenchmarkChainFromRequest(b *testing.B) {
+	e, r := New(), benchmarkChainRequest()
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_ = e.ChainFromRequest(r)
+	}
+}
+
+func BenchmarkAppendChain(b *testing.B) {
+	e, r := New(), benchmarkChainRequest()
+	var dst []string
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		dst = e.AppendChain(dst[:0], r)
+	}
+}
@@ -0,0 +1,65 @@
+package realip
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrNoValidIP is returned when no valid client IP address could be
+	// determined from the request.
+	ErrNoValidIP = errors.New("no valid IP address found")
+
+	// ErrInvalidRemoteAddr is returned when no forwarding header provided an
+	// address and the remote address of the request is malformed. It wraps
+	// ErrNoValidIP.
+	ErrInvalidRemoteAddr = fmt.Errorf("%w: malformed remote address", ErrNoValidIP)
+
+	// ErrUnixSocket is returned instead of ErrInvalidRemoteAddr, which it
+	// wraps, when the request came through a unix socket, whose remote
+	// address net/http sets to an empty string or "@".
+	ErrUnixSocket = fmt.Errorf("%w: unix socket", ErrInvalidRemoteAddr)
+
+	// ErrUntrustedPeer is returned when the forwarding headers were ignored
+	// because the peer is not a trusted proxy, and the peer's own address
+	// is private.
+	ErrUntrustedPeer = errors.New("peer is not a trusted proxy")
+
+	// ErrSpoofingDetected is returned when the forwarding headers relayed by
+	// a trusted proxy contradict each other, which indicates that one of
+	// them was forged by the client.
+	ErrSpoofingDetected = errors.New("spoofed forwarding header detected")
+
+	// ErrMalformedForwarded is returned in strict RFC 7239 mode when a
+	// Forwarded header does not follow the specification.
+	ErrMalformedForwarded = errors.New("malformed Forwarded header")
+
+	// ErrInvalidIP is returned when an address supplied to a function is not
+	// a valid IP address.
+	ErrInvalidIP = errors.New("invalid IP address")
+
+	// ErrInvalidRange is returned when an IP range supplied to an option is
+	// not a valid CIDR block.
+	ErrInvalidRange = errors.New("invalid IP range")
+
+	// ErrPublicTrust is returned by Validate when a trusted proxy range
+	// overlaps public address space.
+	ErrPublicTrust = errors.New("trusted proxy range overlaps public address space")
+
+	// ErrPortInResult is returned with WithResultIPOnly when the resolved
+	// address carries a port.
+	ErrPortInResult = errors.New("resolved address carries a port")
+
+	// ErrMalformedProxyProtocol is returned when a PROXY protocol header
+	// does not follow the specification.
+	ErrMalformedProxyProtocol = errors.New("malformed PROXY protocol header")
+
+	// ErrProxyProtocolUnknown is returned for a PROXY protocol header of the
+	// UNKNOWN form, which carries no address.
+	ErrProxyProtocolUnknown = errors.New("PROXY protocol header carries no address")
+
+	// ErrProxyProtocolLocal is returned instead of ErrProxyProtocolUnknown,
+	// which it wraps, for a PROXY protocol v2 header of the LOCAL command,
+	// sent by the proxy for its own connections, e.g. health checks.
+	ErrProxyProtocolLocal = fmt.Errorf("%w: LOCAL command", ErrProxyProtocolUnknown)
+)
@@ -0,0 +1,804 @@
+package realip
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Extractor resolves client's real IP address from http requests according
+// to its configuration. An Extractor created without options behaves the
+// same as the package level FromRequest.
+//
+// An Extractor must be created with New and must not be modified afterwards.
+// It is then safe for concurrent use by multiple goroutines.
+type Extractor struct {
+	// address classification
+	private     []netip.Prefix
+	nonRoutable bool
+	specialUse  bool
+	publicOnly  bool
+	preferred   []netip.Prefix
+	proxyIPs    []netip.Addr
+
+	internalPredicate func(net.IP) bool
+
+	// trust model
+	trusted     []netip.Prefix
+	trustedSet  bool
+	trustedHops int
+	internal    []netip.Prefix
+	clients     []netip.Prefix
+
+	allowPublicTrust bool
+	trustBoundary    bool
+	verifyTerminus   bool
+
+	// header parsing
+	denied         map[string]bool
+	strictRFC7239  bool
+	lenientParsing bool
+	validation     bool
+	stripPorts     bool
+	headerChain    []HeaderSpec
+	fallbackHeader string
+	canonicalize   bool
+
+	lenientForwardedSeparators bool
+	lenientForwardedFor        bool
+	xffOrder                   XFFOrder
+	policy                     Policy
+	allPrivate                 AllPrivateMode
+	maxChainLength             int
+
+	// result formatting
+	aggregate       bool
+	aggregateV4Bits int
+	aggregateV6Bits int
+	forceIPv6       bool
+	resultIPOnly    bool
+	transform       func(ip string) string
+
+	connectionCache bool
+	auditSink       func(AuditEvent)
+	observer        Observer
+	verifier        func(ctx context.Context, ip string) (bool, error)
+	maxResolveTime  time.Duration
+}
+
+// New returns an Extractor configured with the given options.
+func New(opts ...Option) *Extractor {
+	// The default blocks and header chain are shared by every Extractor, so
+	// their capacity is capped to make options appending to them copy them
+	e := &Extractor{
+		private:     cidrs[:len(cidrs):len(cidrs)],
+		headerChain: defaultHeaderChain[:len(defaultHeaderChain):len(defaultHeaderChain)],
+
+		fallbackHeader: xRealIpHeader,
+		maxChainLength: DefaultMaxChainLength,
+	}
+	for _, opt := range opts {
+		opt(e)
+	}
+
+	return e
+}
+
+// NewExtractor returns an Extractor that only honors the forwarding headers
+// of requests relayed by trustedProxies. The X-Forwarded-For chain is walked
+// from right to left, skipping trusted proxies, and the first address that is
+// not a trusted proxy, the one the closest trusted proxy received the request
+// from, is the client. Requests whose peer is not a trusted proxy resolve to
+// the peer's address, as their headers may have been forged. A nil or empty
+// trustedProxies trusts no proxy, so every request resolves to its peer.
+//
+// It is a shorthand for New(WithTrustedProxies(trustedProxies...)).
+func NewExtractor(trustedProxies []*net.IPNet) *Extractor {
+	return New(WithTrustedProxies(trustedProxies...))
+}
+
+// NewSecure returns an Extractor that only honors the forwarding headers of
+// requests relayed by a loopback peer or a peer within the RFC 1918 ranges,
+// which suits applications running behind their own infrastructure. It is
+// the recommended starting point: unlike FromRequest, it resolves requests
+// sent straight from the internet to their peer, whatever headers they carry.
+// Other private ranges, such as the carrier-grade NAT space 100.64.0.0/10 or
+// IPv6 unique local addresses, are not trusted.
+//
+// The given options are applied afterwards, so WithTrustedProxies adds
+// proxies to these ranges.
+func NewSecure(opts ...Option) *Extractor {
+	return New(append([]Option{withTrustedPrefixes(secureCidrs)}, opts...)...)
+}
+
+// values returns the values of the named header, or nil if the Extractor
+// is configured to ignore it.
+func (e *Extractor) values(in input, name string) []string {
+	if e.denied[name] {
+		return nil
+	}
+
+	return in.lookup(name)
+}
+
+// value returns the first value of the named header, or an empty string if
+// the Extractor is configured to ignore it.
+func (e *Extractor) value(in input, name string) string {
+	if v := e.values(in, name); len(v) > 0 {
+		return v[0]
+	}
+
+	return ""
+}
+
+// isPublic reports whether address is a valid address the Extractor accepts
+// as a client address.
+func (e *Extractor) isPublic(address string) bool {
+	ip, err := parseAddress(address)
+	if err != nil || containsAddress(e.private, ip) || e.isExcluded(ip) {
+		return false
+	}
+
+	return !e.isProxyIP(ip)
+}
+
+// isExcluded reports whether ip is skipped by the options of the Extractor
+// although it is not private.
+func (e *Extractor) isExcluded(ip netip.Addr) bool {
+	if e.nonRoutable && containsAddress(nonRoutableCidrs, ip) {
+		return true
+	}
+
+	if e.specialUse && containsAddress(specialUseCidrs, ip) {
+		return true
+	}
+
+	if e.internalPredicate != nil && e.internalPredicate(ip.AsSlice()) {
+		return true
+	}
+
+	return e.publicOnly && ip == limitedBroadcast
+}
+
+// isPreferred reports whether address lies within the preferred client
+// ranges of the Extractor.
+func (e *Extractor) isPreferred(address string) bool {
+	ip, err := parseAddress(address)
+	return err == nil && containsAddress(e.preferred, ip) && !e.isProxyIP(ip)
+}
+
+// isProxyIP reports whether ip is one of the proxy's own addresses.
+func (e *Extractor) isProxyIP(ip netip.Addr) bool {
+	for _, proxyIP := range e.proxyIPs {
+		if proxyIP == ip {
+			return true
+		}
+	}
+
+	return false
+}
+
+// accept returns address, or an empty string if the Extractor must not
+// return it: it is one of the proxy's own addresses, or the Extractor only
+// returns public addresses and it is not one.
+func (e *Extractor) accept(address string) string {
+	ip, err := parseAddress(address)
+	if err == nil && e.isProxyIP(ip) {
+		return ""
+	}
+	if e.publicOnly && !e.isPublic(address) {
+		return ""
+	}
+
+	return address
+}
+
+// HasForwardingHeaders reports whether the request carries any of the
+// forwarding headers the Extractor takes into account. It is a cheap way to
+// tell a direct connection from one relayed by a proxy.
+func (e *Extractor) HasForwardingHeaders(r *http.Request) bool {
+	return e.hasHeaders(requestInput(r))
+}
+
+// hasHeaders reports whether the request carries any forwarding header the
+// Extractor takes into account.
+func (e *Extractor) hasHeaders(in input) bool {
+	for _, spec := range e.headerChain {
+		if e.value(in, spec.Name) != "" || spec.Kind == HeaderList && len(e.values(in, spec.Name)) > 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// walk calls fn with every address found in the list and Forwarded headers
+// of the header chain, in order of precedence, until fn returns true.
+func (e *Extractor) walk(in input, fn func(address string) bool) {
+	for _, spec := range e.headerChain {
+		if spec.Kind != HeaderSingle && e.walkHeader(in, spec, fn) {
+			return
+		}
+	}
+}
+
+// walkXForwardedFor calls fn with every address of the X-Forwarded-For
+// header, from the client to the closest proxy, until fn returns true, and
+// reports whether it did.
+func (e *Extractor) walkXForwardedFor(in input, fn func(address string) bool) bool {
+	return e.walkList(e.values(in, xForwardedForHeader), fn)
+}
+
+// walkList calls fn with every address of the lines of a comma separated
+// list header, from the client to the closest proxy, until fn returns true,
+// and reports whether it did.
+func (e *Extractor) walkList(lines []string, fn func(address string) bool) bool {
+	if e.xffOrder == NewestFirst {
+		return e.scanListReverse(lines, false, fn)
+	}
+
+	return e.scanList(lines, false, fn)
+}
+
+// listSeparators separate the addresses of a list header. Besides the
+// standard comma, some misbehaving appliances use semicolons.
+const listSeparators = ",;"
+
+// scanList calls fn with every address of the lines of a comma separated
+// list header, from left to right, until fn returns true, and reports
+// whether it did. At most the maximum chain length of entries are scanned.
+// Hidden nodes are skipped, or end the scan when stopAtHidden is set.
+func (e *Extractor) scanList(lines []string, stopAtHidden bool, fn func(address string) bool) bool {
+	n := 0
+	for _, line := range lines {
+		for start := 0; start <= len(line); n++ {
+			if e.exceedsChainLength(n) {
+				return false
+			}
+			end := strings.IndexAny(line[start:], listSeparators)
+			if end < 0 {
+				end = len(line)
+			} else {
+				end += start
+			}
+			if stop, found := e.visit(strings.TrimSpace(line[start:end]), stopAtHidden, fn); stop {
+				return found
+			}
+			start = end + 1
+		}
+	}
+
+	return false
+}
+
+// walkForwarded calls fn with every for address of the Forwarded header
+// until fn returns true, and reports whether it did.
+func (e *Extractor) walkForwarded(in input, fn func(address string) bool) bool {
+	return e.walkForwardedLines(e.values(in, forwardedHeader), fn)
+}
+
+// walkForwardedLines calls fn with every for address of the lines of a
+// Forwarded header, in order, until fn returns true, and reports whether it
+// did. At most the maximum chain length of nodes are considered.
+func (e *Extractor) walkForwardedLines(lines []string, fn func(address string) bool) bool {
+	n, found := 0, false
+	e.eachForwardedName(lines, func(name string) bool {
+		if e.exceedsChainLength(n) {
+			return true
+		}
+		n++
+		var stop bool
+		stop, found = e.visit(name, false, fn)
+		return stop
+	})
+
+	return found
+}
+
+// visit calls fn with an entry of a forwarding header, cleaned up by token,
+// and reports whether the walk ends there, and whether because fn returned
+// true. Empty entries, as left by a leading, trailing or doubled comma, are
+// skipped. Hidden nodes are skipped too, unless stopAtHidden is set: the
+// walks for the client recorded by trusted proxies end at a hop those
+// proxies chose not to disclose, rather than reach the client's own entries.
+func (e *Extractor) visit(address string, stopAtHidden bool, fn func(address string) bool) (stop, found bool) {
+	switch {
+	case address == "":
+		return false, false
+	case isHiddenNode(address):
+		return stopAtHidden, false
+	}
+
+	found = fn(e.token(address))
+	return found, found
+}
+
+// eachForwardedName calls fn with the unparsed address of every for node of
+// the lines of a Forwarded header, in order, until fn returns true, and
+// reports whether it did. Nodes rejected in strict RFC 7239 mode are skipped.
+func (e *Extractor) eachForwardedName(lines []string, fn func(name string) bool) bool {
+	for _, line := range lines {
+		if e.eachForwardedLineName(line, fn) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// eachForwardedLineName calls fn with the unparsed address of every for node
+// of a line of a Forwarded header, as eachForwardedName does.
+func (e *Extractor) eachForwardedLineName(line string, fn func(name string) bool) bool {
+	stopped := false
+	e.eachFor(line, func(node string) bool {
+		if e.strictRFC7239 && !isStrictForwardedNode(node) {
+			return false
+		}
+		stopped = fn(forwardedNodeName(node))
+		return stopped
+	})
+
+	return stopped
+}
+
+// exceedsChainLength reports whether n entries of a header reach the maximum
+// chain length, after which the remaining entries are ignored.
+func (e *Extractor) exceedsChainLength(n int) bool {
+	return e.maxChainLength > 0 && n >= e.maxChainLength
+}
+
+// token cleans up an address taken from a forwarding header according to the
+// parsing mode of the Extractor.
+func (e *Extractor) token(address string) string {
+	if e.lenientParsing {
+		address = stripPrefixLength(address)
+	}
+
+	// Some proxies append the port of the client to its address
+	if _, err := netip.ParseAddr(address); err == nil {
+		return withoutZone(address)
+	}
+
+	return withoutZone(stripPort(address))
+}
+
+// aggregateToPrefix returns the network address of the prefix of the given
+// length containing ip. Addresses are returned as is when they don't parse
+// or the length is out of range for their family.
+func aggregateToPrefix(ip string, v4Bits, v6Bits int) string {
+	addr, err := parseAddress(ip)
+	if err != nil {
+		return ip
+	}
+
+	bits := v6Bits
+	if addr.Is4() {
+		bits = v4Bits
+	}
+	prefix, err := addr.Prefix(bits)
+	if err != nil {
+		return ip
+	}
+
+	return prefix.Addr().String()
+}
+
+// stripPrefixLength removes a CIDR prefix length suffix from an address such
+// as 203.0.113.5/32. Any other value is returned as is.
+func stripPrefixLength(address string) string {
+	i := strings.IndexByte(address, '/')
+	if i < 0 {
+		return address
+	}
+	if _, err := strconv.ParseUint(address[i+1:], 10, 8); err != nil {
+		return address
+	}
+
+	return address[:i]
+}
+
+// stripPort removes the port number from an address such as 203.0.113.5:80 or
+// [2001:db8::1]:443, and the brackets from [2001:db8::1]. A port is only
+// removed from a bracketed IPv6 address, so the last group of a bare one such
+// as 2001:db8::1:80 is never mistaken for a port. Any other value is returned
+// as is.
+func stripPort(address string) string {
+	if ip, _, ok := NormalizeRemoteAddr(address); ok {
+		return ip
+	}
+
+	return address
+}
+
+// withoutZone removes the zone of an IPv6 address, as in fe80::1%eth0 or
+// [fe80::1%eth0]:443. A zone only means something on the host that set it,
+// so it is never returned from a forwarding header, where a client could
+// write anything after the percent sign. Any other value is returned as is.
+func withoutZone(address string) string {
+	if strings.IndexByte(address, '%') < 0 {
+		return address
+	}
+
+	if ip, err := netip.ParseAddr(address); err == nil {
+		return ip.WithZone("").String()
+	}
+	if host, port, err := net.SplitHostPort(address); err == nil {
+		if ip, err := netip.ParseAddr(host); err == nil {
+			return net.JoinHostPort(ip.WithZone("").String(), port)
+		}
+	}
+
+	return address
+}
+
+// xRealIP returns the value of the fallback header, X-Real-IP by default,
+// without a trailing port.
+func (e *Extractor) xRealIP(in input) string {
+	if e.fallbackHeader == "" {
+		return ""
+	}
+
+	return e.singleValue(e.value(in, e.fallbackHeader))
+}
+
+// singleValue returns the value of a single address header without
+// surrounding whitespace and a trailing port, or an empty string if it is
+// not an IP address, with or without a port. The port is removed from IPv4
+// and bracketed IPv6 addresses alike, e.g. [2001:db8::1]:443, so the result
+// is always a bare address.
+func (e *Extractor) singleValue(value string) string {
+	value = strings.TrimSpace(value)
+	if _, _, err := parseRemoteAddr(value); err != nil {
+		return ""
+	}
+
+	return withoutZone(stripPort(value))
+}
+
+// FromRequest returns client's real public IP address from http request headers.
+//
+// Without trusted proxies, the leftmost public address of the forwarding
+// headers is returned. With trusted proxies, it is the rightmost address
+// that is not trusted. Addresses are selected by position, so an address
+// appearing several times is always picked at the same index, while trust
+// is decided by value, so every occurrence of a trusted address is skipped.
+//
+// The unknown token and the obfuscated identifiers of RFC 7239, such as
+// for=_hidden, stand for undisclosed addresses and are ignored, in the
+// Forwarded header as well as in X-Forwarded-For.
+func (e *Extractor) FromRequest(r *http.Request) string {
+	return e.fromInput(requestInput(r), e.requestCache(r))
+}
+
+// fromInput implements FromRequest and FromHeaders.
+func (e *Extractor) fromInput(in input, c *connCache) string {
+	ip, _, _ := e.resolveInput(in, c)
+	ip = e.finish(e.accept(ip))
+	if e.checkNoPort(ip) != nil {
+		return ""
+	}
+
+	return ip
+}
+
+// checkNoPort returns ErrPortInResult if the Extractor must only return bare
+// IP addresses and ip carries a port.
+func (e *Extractor) checkNoPort(ip string) error {
+	if !e.resultIPOnly || net.ParseIP(ip) != nil {
+		return nil
+	}
+	if _, _, err := net.SplitHostPort(ip); err == nil {
+		return ErrPortInResult
+	}
+
+	return nil
+}
+
+// finish applies the configured result formatting and transform to a
+// resolved address.
+func (e *Extractor) finish(ip string) string {
+	ip = withoutZone(ip)
+	if e.stripPorts {
+		ip = stripPort(ip)
+	}
+
+	// IPv4 clients of a dual-stack listener show up as IPv4-mapped IPv6
+	// addresses, which are always returned in dotted-quad form
+	if addr, err := netip.ParseAddr(ip); err == nil && addr.Is4In6() {
+		ip = addr.Unmap().String()
+	}
+
+	if e.aggregate {
+		ip = aggregateToPrefix(ip, e.aggregateV4Bits, e.aggregateV6Bits)
+	}
+
+	if e.forceIPv6 {
+		if addr, err := parseAddress(ip); err == nil && addr.Is4() {
+			ip = netip.AddrFrom16(addr.As16()).String()
+		}
+	}
+
+	if ip != "" && e.transform != nil {
+		ip = e.transform(ip)
+	}
+
+	return ip
+}
+
+// resolve implements FromRequest. The returned error, if any, describes why
+// the returned address should not be relied upon.
+func (e *Extractor) resolve(r *http.Request) (string, error) {
+	ip, _, err := e.resolveReason(r)
+	return ip, err
+}
+
+// resolveReason resolves the client address of r and the reason it was
+// chosen.
+func (e *Extractor) resolveReason(r *http.Request) (string, Reason, error) {
+	return e.resolveInput(requestInput(r), e.requestCache(r))
+}
+
+// requestCache returns the connection cache of r, or nil if the Extractor
+// does not use one or the connection has none.
+func (e *Extractor) requestCache(r *http.Request) *connCache {
+	if !e.connectionCache {
+		return nil
+	}
+	c, _ := r.Context().Value(connCacheKey{}).(*connCache)
+
+	return c
+}
+
+// resolveInput resolves the client address of in and the reason it was
+// chosen, reusing the result held by c, if not nil, when in is unchanged.
+func (e *Extractor) resolveInput(in input, c *connCache) (string, Reason, error) {
+	var (
+		ip     string
+		reason Reason
+		err    error
+	)
+	if c != nil {
+		ip, reason, err = e.resolveCached(in, c)
+	} else {
+		ip, reason, err = e.resolveRequest(in)
+	}
+	if e.observer != nil {
+		e.observe(in, ip, reason)
+	}
+
+	return ip, reason, err
+}
+
+func (e *Extractor) resolveRequest(in input) (string, Reason, error) {
+	if len(e.clients) > 0 {
+		ip, reason := e.resolveClientRanges(in)
+		return ip, reason, nil
+	}
+
+	// If there are no headers, return IP from remote address
+	if !e.hasHeaders(in) {
+		return remoteIP(in), ReasonRemoteAddr, nil
+	}
+
+	if e.validation {
+		if err := e.validateHeaders(in); err != nil {
+			return "", ReasonNoCandidate, err
+		}
+	}
+
+	if !e.chainTerminusVerified(in) {
+		return remoteIP(in), ReasonRemoteAddr, nil
+	}
+
+	if e.trustedSet || e.trustedHops > 0 {
+		return e.resolveTrusted(in)
+	}
+
+	// Return the first address within the preferred client ranges, if any
+	if ip := e.firstPreferred(in); ip != "" {
+		return ip, ReasonPreferredRange, nil
+	}
+
+	ip, reason := e.firstPublic(in)
+	if ip == "" {
+		ip, reason = e.allPrivateFallback(in)
+	}
+
+	return ip, reason, nil
+}
+
+// allPrivateFallback returns the result of a request whose forwarding headers
+// yield no address, according to the WhenAllPrivate mode.
+func (e *Extractor) allPrivateFallback(in input) (string, Reason) {
+	switch e.allPrivate {
+	case ReturnLeftmost:
+		var ip string
+		e.walk(in, func(address string) bool {
+			if _, err := parseAddress(address); err == nil {
+				ip = address
+				return true
+			}
+			return false
+		})
+		if ip != "" {
+			return ip, ReasonLeftmostPrivate
+		}
+	case ReturnRemoteAddr:
+		return remoteIP(in), ReasonRemoteAddr
+	}
+
+	return "", ReasonNoCandidate
+}
+
+// firstPreferred returns the first address of the list and Forwarded headers
+// within the preferred client ranges, or an empty string.
+func (e *Extractor) firstPreferred(in input) string {
+	var ip string
+	if len(e.preferred) > 0 {
+		e.walk(in, func(address string) bool {
+			if e.isPreferred(address) {
+				ip = address
+				return true
+			}
+			return false
+		})
+	}
+
+	return ip
+}
+
+// firstPublic returns the first public address of the list and Forwarded
+// headers of the header chain, walked in the direction of the policy, or the
+// value of the first single address header reached before one is found,
+// e.g. X-Real-IP.
+func (e *Extractor) firstPublic(in input) (string, Reason) {
+	for _, spec := range e.headerChain {
+		if spec.Kind == HeaderSingle {
+			if ip := e.single(in, spec); ip != "" {
+				return ip, e.singleReason(spec)
+			}
+			continue
+		}
+
+		var ip string
+		reverse := e.policy == PolicyRightmostNonPrivate
+		e.walkSelected(in, spec, reverse, func(address string) bool {
+			if e.isPublic(address) {
+				ip = address
+				return true
+			}
+			return false
+		})
+		if ip != "" {
+			return ip, e.walkReason(spec)
+		}
+	}
+
+	return "", ReasonNoCandidate
+}
+
+// FromRequestE returns client's real public IP address from http request
+// headers. Unlike FromRequest, it returns an error when the address can't be
+// relied upon:
+//
+//   - ErrNoValidIP when no valid address could be determined, wrapped in
+//     ErrInvalidRemoteAddr, or ErrUnixSocket, when the remote address is
+//     malformed
+//   - ErrUntrustedPeer when headers were ignored because the peer is not a
+//     trusted proxy, and its own address is private
+//   - ErrSpoofingDetected when the forwarding headers contradict each other
+//   - ErrMalformedForwarded in strict RFC 7239 mode when a Forwarded header
+//     does not follow the specification
+//   - ErrPortInResult with WithResultIPOnly when the result carries a port
+//   - in validation mode, an error describing why RemoteAddr is malformed
+//     when no forwarding header provided a valid address either
+func (e *Extractor) FromRequestE(r *http.Request) (string, error) {
+	if e.strictRFC7239 {
+		for _, line := range e.values(requestInput(r), forwardedHeader) {
+			if err := checkForwarded(line); err != nil {
+				return "", err
+			}
+		}
+	}
+
+	ip, err := e.resolve(r)
+	if err != nil {
+		return "", err
+	}
+
+	ip = e.accept(ip)
+	if _, err := netip.ParseAddr(ip); err != nil {
+		if err := e.checkNoPort(ip); err != nil {
+			return "", err
+		}
+		return "", e.noValidIP(requestInput(r))
+	}
+
+	ip = e.finish(ip)
+	if err := e.checkNoPort(ip); err != nil {
+		return "", err
+	}
+
+	return ip, nil
+}
+
+// noValidIP returns the error of FromRequestE when no valid address could be
+// determined, wrapping ErrInvalidRemoteAddr if RemoteAddr is malformed. In
+// validation mode, it describes why RemoteAddr is malformed instead, as this
+// indicates a broken server setup.
+func (e *Extractor) noValidIP(in input) error {
+	_, _, err := parseRemoteAddr(in.remoteAddr)
+	switch {
+	case err == nil:
+		return ErrNoValidIP
+	case e.validation:
+		return fmt.Errorf("malformed RemoteAddr %q: %w", in.remoteAddr, err)
+	case isUnixSocketAddr(in.remoteAddr):
+		return fmt.Errorf("%w %q", ErrUnixSocket, in.remoteAddr)
+	}
+
+	return fmt.Errorf("%w %q: %v", ErrInvalidRemoteAddr, in.remoteAddr, err)
+}
+
+// FromRequestIP returns client's real public IP address from http request
+// headers as a net.IP. On a nil error, the returned IP is always a valid
+// address, including when it comes from the X-Real-IP fallback; otherwise the
+// error is ErrNoValidIP, or one of the other errors of FromRequestE.
+func (e *Extractor) FromRequestIP(r *http.Request) (net.IP, error) {
+	ip, err := e.FromRequestE(r)
+	if err != nil {
+		return nil, err
+	}
+
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return nil, ErrNoValidIP
+	}
+
+	return parsed, nil
+}
+
+// ResolveDualStack returns the first public IPv4 and the first public IPv6
+// address of the client. Either of them is empty if the request does not
+// carry an address of that family.
+//
+// With trusted proxies or a number of trusted hops, only the address
+// FromRequest resolves to can be relied upon, as every address to its left
+// was written by the client. It is then the only one returned, and the peer
+// of a request whose peer is not trusted is returned instead.
+func (e *Extractor) ResolveDualStack(r *http.Request) (v4 string, v6 string) {
+	assign := func(address string) bool {
+		if !e.isPublic(address) {
+			return false
+		}
+		if ip, _ := netip.ParseAddr(address); ip.Unmap().Is4() {
+			if v4 == "" {
+				v4 = address
+			}
+		} else if v6 == "" {
+			v6 = address
+		}
+		return v4 != "" && v6 != ""
+	}
+
+	in := requestInput(r)
+	if e.trustedSet || e.trustedHops > 0 {
+		ip, _, _ := e.resolveTrusted(in)
+		assign(ip)
+		return v4, v6
+	}
+	if !e.hasHeaders(in) {
+		assign(remoteIP(in))
+		return v4, v6
+	}
+
+	e.walk(in, assign)
+	assign(e.xRealIP(in))
+
+	return v4, v6
+}
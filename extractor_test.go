@@ -0,0 +1,395 @@
+package realip
+
+import (
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestNewExtractor(t *testing.T) {
+	_, proxies, _ := net.ParseCIDR("10.0.0.0/8")
+	e := NewExtractor([]*net.IPNet{proxies})
+
+	testData := []struct {
+		name     string
+		request  *http.Request
+		expected string
+		spoofed  string
+	}{
+		{
+			name: "Spoofed leftmost entry",
+			request: &http.Request{RemoteAddr: "10.0.0.1:8080", Header: http.Header{
+				"X-Forwarded-For": {"1.2.3.4, 144.12.54.87, 10.0.0.2"},
+			}},
+			expected: "144.12.54.87",
+			spoofed:  "1.2.3.4",
+		}, {
+			name: "Untrusted peer",
+			request: &http.Request{RemoteAddr: "119.14.55.11:8080", Header: http.Header{
+				"X-Forwarded-For": {"1.2.3.4"},
+			}},
+			expected: "119.14.55.11",
+			spoofed:  "1.2.3.4",
+		},
+	}
+
+	for _, v := range testData {
+		if actual := e.FromRequest(v.request); actual != v.expected {
+			t.Errorf("%s: expected %s but get %s", v.name, v.expected, actual)
+		}
+		if actual := FromRequest(v.request); actual != v.spoofed {
+			t.Errorf("%s: package FromRequest expected %s but get %s", v.name, v.spoofed, actual)
+		}
+	}
+}
+
+func TestNewExtractorWithoutProxies(t *testing.T) {
+	r := &http.Request{RemoteAddr: "198.51.100.7:8080", Header: http.Header{
+		"X-Forwarded-For": {"203.0.113.66"},
+		"Forwarded":       {"for=203.0.113.66"},
+		"X-Real-Ip":       {"203.0.113.66"},
+	}}
+
+	for name, e := range map[string]*Extractor{
+		"nil":   NewExtractor(nil),
+		"empty": NewExtractor([]*net.IPNet{}),
+	} {
+		if actual := e.FromRequest(r); actual != "198.51.100.7" {
+			t.Errorf("%s: expected %s but get %s", name, "198.51.100.7", actual)
+		}
+	}
+}
+
+func TestNewWithoutOptions(t *testing.T) {
+	e := New()
+
+	headers := []http.Header{
+		{},
+		{"X-Forwarded-For": {"10.0.0.2, 144.12.54.87, 119.14.55.11"}},
+		{"X-Forwarded-For": {"192.168.0.1"}, "X-Real-Ip": {"119.14.55.11"}},
+		{"Forwarded": {`for="[2a00:1450::1]:443", for=144.12.54.87`}},
+		{"X-Forwarded-For": {"garbage"}, "Forwarded": {"for=unknown"}},
+		{"X-Real-Ip": {"not-an-ip"}},
+	}
+	for _, remoteAddr := range []string{"10.0.0.1:8080", "144.12.54.87:80", "@"} {
+		for _, header := range headers {
+			r := &http.Request{RemoteAddr: remoteAddr, Header: header}
+			if actual, expected := e.FromRequest(r), FromRequest(r); actual != expected {
+				t.Errorf("%s %v: expected %s but get %s", remoteAddr, header, expected, actual)
+			}
+		}
+	}
+}
+
+func TestCombinedOptions(t *testing.T) {
+	_, proxies, _ := net.ParseCIDR("13.182.0.0/16")
+	extra, err := WithExtraPrivateRanges("119.14.55.0/24")
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := New(WithTrustedProxies(proxies), WithHeaders("X-Forwarded-For"), WithoutXRealIP(), extra)
+
+	testData := []struct {
+		name       string
+		remoteAddr string
+		header     http.Header
+		expected   string
+	}{
+		{name: "Trusted proxy", remoteAddr: "13.182.0.1:80", header: http.Header{"X-Forwarded-For": {"1.2.3.4, 144.12.54.87, 13.182.0.2"}}, expected: "144.12.54.87"},
+		{name: "Ignored Forwarded", remoteAddr: "13.182.0.1:80", header: http.Header{"Forwarded": {"for=144.12.54.87"}}, expected: "13.182.0.1"},
+		{name: "Ignored X-Real-IP", remoteAddr: "13.182.0.1:80", header: http.Header{"X-Real-Ip": {"144.12.54.87"}}, expected: "13.182.0.1"},
+		{name: "Untrusted private peer", remoteAddr: "119.14.55.11:80", header: http.Header{"X-Forwarded-For": {"144.12.54.87"}}, expected: "119.14.55.11"},
+	}
+
+	for _, v := range testData {
+		r := &http.Request{RemoteAddr: v.remoteAddr, Header: v.header}
+		if actual := e.FromRequest(r); actual != v.expected {
+			t.Errorf("%s: expected %s but get %s", v.name, v.expected, actual)
+		}
+	}
+}
+
+func TestNewSecure(t *testing.T) {
+	_, proxies, _ := net.ParseCIDR("119.14.55.0/24")
+
+	testData := []struct {
+		name       string
+		extractor  *Extractor
+		remoteAddr string
+		header     http.Header
+		expected   string
+	}{
+		{name: "Public peer", extractor: NewSecure(), remoteAddr: "119.14.55.11:8080", header: http.Header{"X-Forwarded-For": {"1.2.3.4"}}, expected: "119.14.55.11"},
+		{name: "Public peer X-Real-IP", extractor: NewSecure(), remoteAddr: "119.14.55.11:8080", header: http.Header{"X-Real-Ip": {"1.2.3.4"}}, expected: "119.14.55.11"},
+		{name: "Private peer", extractor: NewSecure(), remoteAddr: "10.0.0.1:8080", header: http.Header{"X-Forwarded-For": {"1.2.3.4, 144.12.54.87, 192.168.0.2"}}, expected: "144.12.54.87"},
+		{name: "Loopback peer", extractor: NewSecure(), remoteAddr: "127.0.0.1:8080", header: http.Header{"Forwarded": {"for=144.12.54.87"}}, expected: "144.12.54.87"},
+		{name: "IPv6 loopback peer", extractor: NewSecure(), remoteAddr: "[::1]:8080", header: http.Header{"X-Forwarded-For": {"144.12.54.87"}}, expected: "144.12.54.87"},
+		{name: "Carrier-grade NAT peer", extractor: NewSecure(), remoteAddr: "100.64.0.1:8080", header: http.Header{"X-Forwarded-For": {"144.12.54.87"}}, expected: "100.64.0.1"},
+		{name: "Benchmarking peer", extractor: NewSecure(), remoteAddr: "198.18.0.1:8080", header: http.Header{"X-Forwarded-For": {"144.12.54.87"}}, expected: "198.18.0.1"},
+		{name: "Documentation IPv6 peer", extractor: NewSecure(), remoteAddr: "[2001:db8::1]:8080", header: http.Header{"X-Forwarded-For": {"144.12.54.87"}}, expected: "2001:db8::1"},
+		{name: "Added proxy", extractor: NewSecure(WithTrustedProxies(proxies)), remoteAddr: "119.14.55.11:8080", header: http.Header{"X-Forwarded-For": {"144.12.54.87"}}, expected: "144.12.54.87"},
+	}
+
+	for _, v := range testData {
+		r := &http.Request{RemoteAddr: v.remoteAddr, Header: v.header}
+		if actual := v.extractor.FromRequest(r); actual != v.expected {
+			t.Errorf("%s: expected %s but get %s", v.name, v.expected, actual)
+		}
+	}
+}
+
+func TestBareIPv6InXForwardedFor(t *testing.T) {
+	r := &http.Request{RemoteAddr: "10.0.0.1:8080", Header: http.Header{
+		"X-Forwarded-For": {"2001:db8::1, 203.0.113.5"},
+	}}
+	expected := []string{"2001:db8::1", "203.0.113.5"}
+
+	for _, e := range []*Extractor{New(), New(WithStripAllPorts()), New(WithLenientParsing())} {
+		if actual := e.ChainFromRequest(r); len(actual) != 2 || actual[0] != expected[0] || actual[1] != expected[1] {
+			t.Errorf("ChainFromRequest: expected %v but get %v", expected, actual)
+		}
+	}
+
+	r.Header.Set("X-Forwarded-For", "2a00:1450::1, 144.12.54.87")
+	for _, e := range []*Extractor{New(), New(WithStripAllPorts()), New(WithResultIPOnly())} {
+		if actual := e.FromRequest(r); actual != "2a00:1450::1" {
+			t.Errorf("FromRequest: expected %s but get %s", "2a00:1450::1", actual)
+		}
+	}
+
+	// The last group of a bare IPv6 address is not mistaken for a port
+	r.Header.Set("X-Forwarded-For", "2a00:1450::1:80")
+	if actual := New(WithStripAllPorts()).FromRequest(r); actual != "2a00:1450::1:80" {
+		t.Errorf("Bare IPv6: expected %s but get %s", "2a00:1450::1:80", actual)
+	}
+	r.Header.Set("X-Forwarded-For", "[2a00:1450::1]:80")
+	if actual := New(WithStripAllPorts()).FromRequest(r); actual != "2a00:1450::1" {
+		t.Errorf("Bracketed IPv6: expected %s but get %s", "2a00:1450::1", actual)
+	}
+}
+
+func TestXForwardedForSeparators(t *testing.T) {
+	_, proxies, _ := net.ParseCIDR("10.0.0.0/8")
+
+	testData := []struct {
+		name     string
+		xff      string
+		chain    []string
+		expected string
+		trusted  string
+	}{
+		{name: "Commas", xff: "10.0.0.3, 203.0.113.9, 144.12.54.87", chain: []string{"10.0.0.3", "203.0.113.9", "144.12.54.87"}, expected: "203.0.113.9", trusted: "144.12.54.87"},
+		{name: "Semicolons", xff: "10.0.0.3; 203.0.113.9;144.12.54.87", chain: []string{"10.0.0.3", "203.0.113.9", "144.12.54.87"}, expected: "203.0.113.9", trusted: "144.12.54.87"},
+		{name: "Mixed separators", xff: " 10.0.0.3 ;203.0.113.9 ,  144.12.54.87 ", chain: []string{"10.0.0.3", "203.0.113.9", "144.12.54.87"}, expected: "203.0.113.9", trusted: "144.12.54.87"},
+		{name: "Private then public", xff: "10.0.0.1; 203.0.113.9", chain: []string{"10.0.0.1", "203.0.113.9"}, expected: "203.0.113.9", trusted: "203.0.113.9"},
+	}
+
+	for _, v := range testData {
+		r := &http.Request{RemoteAddr: "10.0.0.1:8080", Header: http.Header{"X-Forwarded-For": {v.xff}}}
+		if actual := ChainFromRequest(r); strings.Join(actual, " ") != strings.Join(v.chain, " ") {
+			t.Errorf("%s: expected chain %v but get %v", v.name, v.chain, actual)
+		}
+		if actual := FromRequest(r); actual != v.expected {
+			t.Errorf("%s: expected %s but get %s", v.name, v.expected, actual)
+		}
+		if actual := NewExtractor([]*net.IPNet{proxies}).FromRequest(r); actual != v.trusted {
+			t.Errorf("%s: trusted proxies expected %s but get %s", v.name, v.trusted, actual)
+		}
+	}
+}
+
+func TestEmptyTokens(t *testing.T) {
+	_, proxies, _ := net.ParseCIDR("10.0.0.0/8")
+
+	testData := []struct {
+		name     string
+		header   http.Header
+		chain    []string
+		expected string
+		trusted  string
+	}{
+		{name: "Trailing comma", header: http.Header{"X-Forwarded-For": {"203.0.113.9,"}}, chain: []string{"203.0.113.9"}, expected: "203.0.113.9", trusted: "203.0.113.9"},
+		{name: "Leading commas", header: http.Header{"X-Forwarded-For": {",,203.0.113.9"}}, chain: []string{"203.0.113.9"}, expected: "203.0.113.9", trusted: "203.0.113.9"},
+		{name: "Doubled comma", header: http.Header{"X-Forwarded-For": {"203.0.113.9, ,144.12.54.87,,"}}, chain: []string{"203.0.113.9", "144.12.54.87"}, expected: "203.0.113.9", trusted: "144.12.54.87"},
+		{name: "Only commas", header: http.Header{"X-Forwarded-For": {" , ,"}}, chain: nil, expected: "", trusted: "10.0.0.1"},
+		{name: "Empty line", header: http.Header{"X-Forwarded-For": {"", "203.0.113.9"}}, chain: []string{"203.0.113.9"}, expected: "203.0.113.9", trusted: "203.0.113.9"},
+		{name: "Forwarded trailing comma", header: http.Header{"Forwarded": {"for=203.0.113.9,"}}, chain: []string{"203.0.113.9"}, expected: "203.0.113.9", trusted: "203.0.113.9"},
+		{name: "Forwarded leading commas", header: http.Header{"Forwarded": {",,for=203.0.113.9"}}, chain: []string{"203.0.113.9"}, expected: "203.0.113.9", trusted: "203.0.113.9"},
+		{name: "Forwarded doubled comma", header: http.Header{"Forwarded": {"for=203.0.113.9,,for=144.12.54.87"}}, chain: []string{"203.0.113.9", "144.12.54.87"}, expected: "203.0.113.9", trusted: "144.12.54.87"},
+		{name: "Forwarded empty for", header: http.Header{"Forwarded": {`for=203.0.113.9, for="", for=`}}, chain: []string{"203.0.113.9"}, expected: "203.0.113.9", trusted: "203.0.113.9"},
+	}
+
+	for _, v := range testData {
+		r := &http.Request{RemoteAddr: "10.0.0.1:8080", Header: v.header}
+		defaultExtractor.walk(requestInput(r), func(address string) bool {
+			if address == "" {
+				t.Errorf("%s: expected empty tokens to be skipped", v.name)
+			}
+			return false
+		})
+		if actual := ChainFromRequest(r); strings.Join(actual, " ") != strings.Join(v.chain, " ") {
+			t.Errorf("%s: expected chain %v but get %v", v.name, v.chain, actual)
+		}
+		if actual := FromRequest(r); actual != v.expected {
+			t.Errorf("%s: expected %s but get %s", v.name, v.expected, actual)
+		}
+		if actual := NewExtractor([]*net.IPNet{proxies}).FromRequest(r); actual != v.trusted {
+			t.Errorf("%s: trusted proxies expected %s but get %s", v.name, v.trusted, actual)
+		}
+	}
+}
+
+func TestPortsInXForwardedFor(t *testing.T) {
+	_, proxies, _ := net.ParseCIDR("10.0.0.0/8")
+
+	testData := []struct {
+		name     string
+		xff      string
+		expected string
+	}{
+		{name: "IPv4 with port", xff: "10.0.0.3:1234, 203.0.113.9:443", expected: "203.0.113.9"},
+		{name: "Bracketed IPv6 with port", xff: "[2001:db8::1]:443", expected: "2001:db8::1"},
+		{name: "Bracketed IPv6", xff: "[2a00:1450::1]", expected: "2a00:1450::1"},
+		{name: "Bare IPv6", xff: "2a00:1450::1:443", expected: "2a00:1450::1:443"},
+		{name: "Invalid port", xff: "203.0.113.9:http, 144.12.54.87", expected: "144.12.54.87"},
+	}
+
+	for _, v := range testData {
+		r := &http.Request{RemoteAddr: "10.0.0.1:8080", Header: http.Header{"X-Forwarded-For": {v.xff}}}
+		if actual := ChainFromRequest(r); len(actual) == 0 || actual[len(actual)-1] != v.expected {
+			t.Errorf("%s: expected chain ending with %s but get %v", v.name, v.expected, actual)
+		}
+		if actual := NewExtractor([]*net.IPNet{proxies}).FromRequest(r); actual != v.expected {
+			t.Errorf("%s: trusted proxies expected %s but get %s", v.name, v.expected, actual)
+		}
+	}
+
+	r := &http.Request{RemoteAddr: "10.0.0.1:8080", Header: http.Header{"X-Forwarded-For": {"10.0.0.3:1234, 203.0.113.9:443"}}}
+	if actual := FromRequest(r); actual != "203.0.113.9" {
+		t.Errorf("FromRequest: expected %s but get %s", "203.0.113.9", actual)
+	}
+}
+
+func TestResolveDualStack(t *testing.T) {
+	testData := []struct {
+		name       string
+		request    *http.Request
+		expectedV4 string
+		expectedV6 string
+	}{
+		{
+			name: "Both families",
+			request: &http.Request{Header: http.Header{
+				"X-Forwarded-For": {"10.0.0.1, 203.0.113.5, 2a00:1450::1, 198.51.100.7"},
+			}},
+			expectedV4: "203.0.113.5",
+			expectedV6: "2a00:1450::1",
+		}, {
+			name: "IPv6 only",
+			request: &http.Request{Header: http.Header{
+				"X-Forwarded-For": {"fd00::1, 2a00:1450::1"},
+			}},
+			expectedV6: "2a00:1450::1",
+		}, {
+			name:       "No header",
+			request:    &http.Request{RemoteAddr: "203.0.113.5:8080", Header: http.Header{}},
+			expectedV4: "203.0.113.5",
+		},
+	}
+
+	for _, v := range testData {
+		v4, v6 := ResolveDualStack(v.request)
+		if v4 != v.expectedV4 || v6 != v.expectedV6 {
+			t.Errorf("%s: expected (%s, %s) but get (%s, %s)", v.name, v.expectedV4, v.expectedV6, v4, v6)
+		}
+	}
+
+	secure := NewSecure()
+	trustedData := []struct {
+		name       string
+		remoteAddr string
+		expectedV4 string
+		expectedV6 string
+	}{
+		{name: "Untrusted peer", remoteAddr: "144.12.54.87:8080", expectedV4: "144.12.54.87"},
+		{name: "Trusted peer", remoteAddr: "10.0.0.1:8080", expectedV6: "2a00:1450::66"},
+	}
+
+	for _, v := range trustedData {
+		r := &http.Request{RemoteAddr: v.remoteAddr, Header: http.Header{"X-Forwarded-For": {"6.6.6.6, 2a00:1450::66"}}}
+		v4, v6 := secure.ResolveDualStack(r)
+		if v4 != v.expectedV4 || v6 != v.expectedV6 {
+			t.Errorf("%s: expected (%s, %s) but get (%s, %s)", v.name, v.expectedV4, v.expectedV6, v4, v6)
+		}
+		if ip := secure.FromRequest(r); ip != v4+v6 {
+			t.Errorf("%s: expected FromRequest to agree but get %s", v.name, ip)
+		}
+	}
+}
+
+func TestHasForwardingHeaders(t *testing.T) {
+	testData := []struct {
+		name     string
+		header   http.Header
+		expected bool
+	}{
+		{name: "No header", header: http.Header{}, expected: false},
+		{name: "Unrelated header", header: http.Header{"Via": {"1.1 proxy"}}, expected: false},
+		{name: "X-Forwarded-For", header: http.Header{"X-Forwarded-For": {"144.12.54.87"}}, expected: true},
+		{name: "Forwarded", header: http.Header{"Forwarded": {"for=144.12.54.87"}}, expected: true},
+		{name: "X-Real-IP", header: http.Header{"X-Real-Ip": {"144.12.54.87"}}, expected: true},
+	}
+
+	for _, v := range testData {
+		r := &http.Request{RemoteAddr: "10.0.0.1:8080", Header: v.header}
+		if actual := HasForwardingHeaders(r); actual != v.expected {
+			t.Errorf("%s: expected %t but get %t", v.name, v.expected, actual)
+		}
+	}
+
+	r := &http.Request{Header: http.Header{"Forwarded": {"for=144.12.54.87"}}}
+	if New(WithDenyHeaders("Forwarded")).HasForwardingHeaders(r) {
+		t.Errorf("Denied header: expected false but get true")
+	}
+}
+
+// TestConcurrentUse is meant to be run with -race.
+func TestConcurrentUse(t *testing.T) {
+	_, proxies, _ := net.ParseCIDR("10.0.0.0/8")
+	extra, err := WithExtraPrivateRanges("119.14.55.0/24")
+	if err != nil {
+		t.Fatal(err)
+	}
+	shared := []Option{WithTrustedProxies(proxies), WithHeader(CFConnectingIPHeader, HeaderSingle), extra}
+	e := New(shared...)
+
+	r := &http.Request{RemoteAddr: "10.0.0.1:8080", Header: http.Header{
+		"X-Forwarded-For": {"1.2.3.4, 144.12.54.87, 10.0.0.2"},
+		"Forwarded":       {"for=144.12.54.87"},
+	}}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 32; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				if actual := FromRequest(r); actual != "1.2.3.4" {
+					t.Errorf("FromRequest: expected %s but get %s", "1.2.3.4", actual)
+					return
+				}
+				if actual := e.FromRequest(r); actual != "144.12.54.87" {
+					t.Errorf("Extractor: expected %s but get %s", "144.12.54.87", actual)
+					return
+				}
+				if actual := New(shared...).FromRequest(r); actual != "144.12.54.87" {
+					t.Errorf("New: expected %s but get %s", "144.12.54.87", actual)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,30 @@
+package realip
+
+import "net/http"
+
+// FamilyMismatch reports whether the client address resolved from the
+// request and the address of the peer of the connection are of different
+// families, e.g. an IPv4 client behind a proxy reached over IPv6. It can
+// reveal address translation or a misconfigured proxy. IPv4-mapped IPv6
+// addresses count as IPv4, and it is false if either address is invalid.
+func (e *Extractor) FamilyMismatch(r *http.Request) bool {
+	remote, err := parseAddress(remoteIP(requestInput(r)))
+	if err != nil {
+		return false
+	}
+
+	ip, _ := e.resolve(r)
+	client, err := parseAddress(e.accept(ip))
+	if err != nil {
+		return false
+	}
+
+	return remote.Is4() != client.Is4()
+}
+
+// FamilyMismatch reports whether the client address resolved from the
+// request and the address of the peer of the connection are of different
+// families.
+func FamilyMismatch(r *http.Request) bool {
+	return defaultExtractor.FamilyMismatch(r)
+}
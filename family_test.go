@@ -0,0 +1,31 @@
+package realip
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestFamilyMismatch(t *testing.T) {
+	testData := []struct {
+		name       string
+		remoteAddr string
+		header     http.Header
+		expected   bool
+	}{
+		{name: "IPv4 behind IPv6", remoteAddr: "[fd00::1]:80", header: http.Header{"X-Forwarded-For": {"144.12.54.87"}}, expected: true},
+		{name: "IPv6 behind IPv4", remoteAddr: "10.0.0.1:80", header: http.Header{"X-Forwarded-For": {"2a00:1450::1"}}, expected: true},
+		{name: "IPv4 behind IPv4", remoteAddr: "10.0.0.1:80", header: http.Header{"X-Forwarded-For": {"144.12.54.87"}}, expected: false},
+		{name: "IPv6 behind IPv6", remoteAddr: "[fd00::1]:80", header: http.Header{"X-Forwarded-For": {"2a00:1450::1"}}, expected: false},
+		{name: "IPv4-mapped", remoteAddr: "[::ffff:10.0.0.1]:80", header: http.Header{"X-Forwarded-For": {"144.12.54.87"}}, expected: false},
+		{name: "Direct", remoteAddr: "[2a00:1450::1]:80", header: http.Header{}, expected: false},
+		{name: "Invalid header", remoteAddr: "[fd00::1]:80", header: http.Header{"X-Real-Ip": {"garbage"}}, expected: false},
+		{name: "Unix socket", remoteAddr: "@", header: http.Header{"X-Forwarded-For": {"144.12.54.87"}}, expected: false},
+	}
+
+	for _, v := range testData {
+		r := &http.Request{RemoteAddr: v.remoteAddr, Header: v.header}
+		if actual := FamilyMismatch(r); actual != v.expected {
+			t.Errorf("%s: expected %t but get %t", v.name, v.expected, actual)
+		}
+	}
+}
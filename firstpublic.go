@@ -0,0 +1,23 @@
+package realip
+
+// FirstPublic returns the leftmost public address of an X-Forwarded-For
+// header value, the one FromRequest would return for a request carrying only
+// that header, and whether there is one. The value is scanned in place with
+// the scanner of FromRequest, stopping at the first public address, so hot
+// paths that only need it don't pay for building the chain. At most
+// DefaultMaxChainLength entries are considered.
+func FirstPublic(xff string) (string, bool) {
+	var ip string
+	found := defaultExtractor.scanList([]string{xff}, false, func(address string) bool {
+		if defaultExtractor.isPublic(address) {
+			ip = address
+			return true
+		}
+		return false
+	})
+	if !found {
+		return "", false
+	}
+
+	return defaultExtractor.finish(ip), true
+}
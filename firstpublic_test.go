@@ -0,0 +1,75 @@
+package realip
+
+import (
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestFirstPublic(t *testing.T) {
+	testData := []struct {
+		xff      string
+		expected string
+	}{
+		{xff: "144.12.54.87", expected: "144.12.54.87"},
+		{xff: "10.0.0.3, 192.168.0.1, 144.12.54.87, 119.14.55.11", expected: "144.12.54.87"},
+		{xff: "  10.0.0.3 ,\t2a00:1450::1 ", expected: "2a00:1450::1"},
+		{xff: "unknown, 144.12.54.87:4711", expected: "144.12.54.87"},
+		{xff: "[2a00:1450::1]:443, 144.12.54.87", expected: "2a00:1450::1"},
+		{xff: "not an address, ::ffff:144.12.54.87", expected: "144.12.54.87"},
+		{xff: "2a00:1450::1%<script>, 144.12.54.87", expected: "2a00:1450::1"},
+		{xff: ",, 10.0.0.3,,144.12.54.87,", expected: "144.12.54.87"},
+		{xff: "10.0.0.3, 192.168.0.1", expected: ""},
+		{xff: "", expected: ""},
+	}
+
+	for _, v := range testData {
+		actual, ok := FirstPublic(v.xff)
+		if actual != v.expected || ok != (v.expected != "") {
+			t.Errorf("%q: expected (%s, %t) but get (%s, %t)", v.xff, v.expected, v.expected != "", actual, ok)
+		}
+
+		r := &http.Request{RemoteAddr: "10.0.0.1:8080", Header: http.Header{"X-Forwarded-For": {v.xff}}}
+		if expected := FromRequest(r); v.expected != "" && expected != actual {
+			t.Errorf("%q: expected the result of FromRequest %s but get %s", v.xff, expected, actual)
+		}
+	}
+
+	if allocs := testing.AllocsPerRun(100, func() { FirstPublic("10.0.0.3, 192.168.0.1, 144.12.54.87") }); allocs != 0 {
+		t.Errorf("expected no allocations but get %.0f", allocs)
+	}
+}
+
+// splitFirstPublic is the strings.Split based approach FirstPublic replaces.
+func splitFirstPublic(xff string) (string, bool) {
+	for _, address := range strings.Split(xff, ",") {
+		address = strings.TrimSpace(address)
+		if ip := net.ParseIP(address); ip != nil && !IsPrivateIP(ip) {
+			return address, true
+		}
+	}
+
+	return "", false
+}
+
+func BenchmarkFirstPublic(b *testing.B) {
+	xff := "10.0.0.5, 192.168.0.1, 172.16.3.4, 144.12.54.87, 10.0.0.3, 10.0.0.2"
+
+	b.Run("FirstPublic", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if ip, _ := FirstPublic(xff); ip != "144.12.54.87" {
+				b.Fatalf("expected %s but get %s", "144.12.54.87", ip)
+			}
+		}
+	})
+	b.Run("Split", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if ip, _ := splitFirstPublic(xff); ip != "144.12.54.87" {
+				b.Fatalf("expected %s but get %s", "144.12.54.87", ip)
+			}
+		}
+	})
+}
@@ -0,0 +1,369 @@
+package realip
+
+import (
+	"fmt"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// ForwardedElement holds the parameters of a single element of an RFC 7239
+// Forwarded header, e.g. for=192.0.2.60;proto=https;by=203.0.113.43. Values
+// are unquoted, and empty when the parameter is missing.
+type ForwardedElement struct {
+	// For is the node the request came from, as is, e.g. 192.0.2.60,
+	// [2001:db8::1]:4711 or an obfuscated identifier such as _hidden
+	For string
+
+	// By is the node of the proxy that received the request
+	By string
+
+	// Host is the Host request header the proxy received
+	Host string
+
+	// Proto is the protocol the request was received with, e.g. https
+	Proto string
+}
+
+// ParseForwarded parses the value of an RFC 7239 Forwarded header into its
+// elements, e.g. to reconstruct the URL the client requested. Elements are
+// separated by commas and their parameters by semicolons, and parameter
+// names are case-insensitive. Quoted values are unquoted, unknown parameters
+// are ignored. Runs of spaces and tabs around separators, as left by unfolded
+// header lines, are ignored.
+func ParseForwarded(header string) []ForwardedElement {
+	var elements []ForwardedElement
+	for _, element := range splitQuoted(header, ',') {
+		var fe ForwardedElement
+		for _, pair := range splitQuoted(element, ';') {
+			if key, value, ok := cutForwardedPair(pair); ok {
+				fe.set(key, value)
+			}
+		}
+		elements = append(elements, fe)
+	}
+
+	return elements
+}
+
+// parseForwardedLenient parses the value of a Forwarded header produced by a
+// proxy that separates parameters with commas as well as semicolons, e.g.
+// for=192.0.2.1, proto=https. A parameter that is already set in the current
+// element starts a new element.
+func parseForwardedLenient(header string) []ForwardedElement {
+	var elements []ForwardedElement
+	var fe ForwardedElement
+	for _, part := range splitQuoted(header, ',') {
+		for _, pair := range splitQuoted(part, ';') {
+			key, value, ok := cutForwardedPair(pair)
+			if !ok {
+				continue
+			}
+			if fe.has(key) {
+				elements = append(elements, fe)
+				fe = ForwardedElement{}
+			}
+			fe.set(key, value)
+		}
+	}
+	if fe != (ForwardedElement{}) {
+		elements = append(elements, fe)
+	}
+
+	return elements
+}
+
+// parseForwarded parses the value of a Forwarded header according to the
+// separator handling of the Extractor.
+func (e *Extractor) parseForwarded(header string) []ForwardedElement {
+	if e.lenientForwardedSeparators {
+		return parseForwardedLenient(header)
+	}
+
+	return ParseForwarded(header)
+}
+
+// cutForwardedPair splits a forwarded-pair into its lowercased key and its
+// unquoted value.
+func cutForwardedPair(pair string) (key, value string, ok bool) {
+	key, value, ok = strings.Cut(pair, "=")
+	if !ok {
+		return "", "", false
+	}
+
+	return strings.ToLower(strings.TrimSpace(key)), unquote(strings.TrimSpace(value)), true
+}
+
+// field returns a pointer to the field of fe holding the parameter key, or
+// nil for unknown parameters.
+func (fe *ForwardedElement) field(key string) *string {
+	switch key {
+	case "for":
+		return &fe.For
+	case "by":
+		return &fe.By
+	case "host":
+		return &fe.Host
+	case "proto":
+		return &fe.Proto
+	}
+
+	return nil
+}
+
+// set sets the parameter key of fe. Unknown parameters are ignored.
+func (fe *ForwardedElement) set(key, value string) {
+	if f := fe.field(key); f != nil {
+		*f = value
+	}
+}
+
+// has reports whether the parameter key of fe is set.
+func (fe *ForwardedElement) has(key string) bool {
+	f := fe.field(key)
+	return f != nil && *f != ""
+}
+
+// splitQuoted splits s around each instance of sep that is not inside a
+// quoted string.
+func splitQuoted(s string, sep byte) []string {
+	var parts []string
+	quoted, escaped, start := false, false, 0
+	for i := 0; i < len(s); i++ {
+		switch {
+		case escaped:
+			escaped = false
+		case quoted && s[i] == '\\':
+			escaped = true
+		case s[i] == '"':
+			quoted = !quoted
+		case !quoted && s[i] == sep:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+
+	return append(parts, s[start:])
+}
+
+// unquote removes the quotes and escapes of a quoted-string. Values that are
+// not quoted are returned as is.
+func unquote(s string) string {
+	if len(s) < 2 || s[0] != '"' || s[len(s)-1] != '"' {
+		return s
+	}
+
+	s = s[1 : len(s)-1]
+	if strings.IndexByte(s, '\\') < 0 {
+		return s
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			i++
+		}
+		b.WriteByte(s[i])
+	}
+
+	return b.String()
+}
+
+// eachForwardedFor calls fn with the unparsed node of every for parameter of
+// a Forwarded header, in order, until fn returns true. Elements are split on
+// commas and their parameters on semicolons, outside of quoted strings, and
+// the optional whitespace of the HTTP grammar, any run of spaces and tabs,
+// is trimmed around parameter names and values.
+func eachForwardedFor(header string, fn func(node string) bool) {
+	for _, element := range splitQuoted(header, ',') {
+		for _, pair := range splitQuoted(element, ';') {
+			key, value, ok := strings.Cut(pair, "=")
+			if ok && strings.EqualFold(strings.TrimSpace(key), "for") && fn(strings.TrimSpace(value)) {
+				return
+			}
+		}
+	}
+}
+
+// eachForwardedForLenient is like eachForwardedFor, but also calls fn with
+// the bare tokens following a for parameter, as written by proxies that
+// append to the node list of the previous element, e.g. 198.51.100.1 in
+// for=203.0.113.9, 198.51.100.1.
+func eachForwardedForLenient(header string, fn func(node string) bool) {
+	continued := false
+	for _, element := range splitQuoted(header, ',') {
+		if token := strings.TrimSpace(element); continued && isBareForwardedToken(token) {
+			if fn(token) {
+				return
+			}
+			continue
+		}
+
+		continued = false
+		for _, pair := range splitQuoted(element, ';') {
+			key, value, ok := strings.Cut(pair, "=")
+			if !ok || !strings.EqualFold(strings.TrimSpace(key), "for") {
+				continue
+			}
+			if fn(strings.TrimSpace(value)) {
+				return
+			}
+			continued = true
+		}
+	}
+}
+
+// isBareForwardedToken reports whether an element of a Forwarded header is a
+// bare node rather than a list of parameters.
+func isBareForwardedToken(element string) bool {
+	return element != "" && !strings.ContainsAny(element, "=;")
+}
+
+// eachFor calls fn with the unparsed node of every for parameter of a
+// Forwarded header according to the parsing mode of the Extractor.
+func (e *Extractor) eachFor(header string, fn func(node string) bool) {
+	if e.lenientForwardedFor {
+		eachForwardedForLenient(header, fn)
+		return
+	}
+
+	eachForwardedFor(header, fn)
+}
+
+// forwardedNodeName returns the address of an unparsed for node, without
+// quotes, brackets and port, e.g. 2001:db8::1 for "[2001:db8::1]:8080". An
+// unterminated bracket yields an empty string.
+func forwardedNodeName(node string) string {
+	node = unquote(node)
+	if strings.HasPrefix(node, "[") {
+		end := strings.IndexByte(node, ']')
+		if end < 0 {
+			return ""
+		}
+		return node[1:end]
+	}
+
+	// A single colon separates an IPv4 address or an obfuscated name from
+	// its port, more are part of an IPv6 address without brackets
+	if i := strings.IndexByte(node, ':'); i >= 0 && strings.IndexByte(node[i+1:], ':') < 0 {
+		return node[:i]
+	}
+
+	return node
+}
+
+// isStrictForwardedNode reports whether node, the unparsed value of a for
+// parameter, follows RFC 7239. IPv6 addresses must be enclosed in brackets
+// and, as brackets and colons are not allowed in a token, quoted.
+func isStrictForwardedNode(node string) bool {
+	quoted := len(node) >= 2 && node[0] == '"' && node[len(node)-1] == '"'
+	node = unquote(node)
+
+	if strings.HasPrefix(node, "[") {
+		end := strings.IndexByte(node, ']')
+		if end < 0 || !quoted {
+			return false
+		}
+		if ip, err := netip.ParseAddr(node[1:end]); err != nil || !ip.Is6() {
+			return false
+		}
+		if rest := node[end+1:]; rest != "" {
+			return rest[0] == ':' && isForwardedNodePort(rest[1:])
+		}
+		return true
+	}
+
+	// A colon can only separate an IPv4 address from its port here, the
+	// node name of an IPv6 address without brackets won't parse
+	name, port, hasPort := strings.Cut(node, ":")
+	if hasPort && (!quoted || !isForwardedNodePort(port)) {
+		return false
+	}
+	if name == "unknown" || isObfuscatedNode(name) {
+		return true
+	}
+	ip, err := netip.ParseAddr(name)
+
+	return err == nil && ip.Is4()
+}
+
+// isForwardedNodePort reports whether port is a valid RFC 7239 node port.
+func isForwardedNodePort(port string) bool {
+	if isObfuscatedNode(port) {
+		return true
+	}
+	if port == "" || len(port) > 5 {
+		return false
+	}
+	for i := 0; i < len(port); i++ {
+		if port[i] < '0' || port[i] > '9' {
+			return false
+		}
+	}
+
+	return true
+}
+
+// isHiddenNode reports whether name, the address of a for node or of a list
+// header entry, is the unknown token or an obfuscated identifier, which stand
+// for an address the proxy chose not to disclose. They are skipped by the
+// walks from the client, and end the walks from the closest proxy.
+func isHiddenNode(name string) bool {
+	return strings.EqualFold(name, "unknown") || isObfuscatedNode(name)
+}
+
+// isObfuscatedNode reports whether s is an RFC 7239 obfuscated identifier,
+// an underscore followed by letters, digits, dots, underscores or dashes.
+func isObfuscatedNode(s string) bool {
+	if len(s) < 2 || s[0] != '_' {
+		return false
+	}
+	for i := 1; i < len(s); i++ {
+		c := s[i]
+		if !('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || c == '.' || c == '_' || c == '-') {
+			return false
+		}
+	}
+
+	return true
+}
+
+// checkForwarded returns an error wrapping ErrMalformedForwarded for the first
+// for parameter of a Forwarded header that does not follow RFC 7239.
+func checkForwarded(header string) error {
+	var err error
+	eachForwardedFor(header, func(node string) bool {
+		if !isStrictForwardedNode(node) {
+			err = fmt.Errorf("%w: for=%s", ErrMalformedForwarded, node)
+			return true
+		}
+		return false
+	})
+
+	return err
+}
+
+// TerminatingProxy returns the by parameter of the Forwarded header added by
+// the proxy closest to the server, that is the rightmost element with a by
+// value that is neither unknown nor obfuscated. It identifies which internal
+// proxy handled the request, and is empty if none is known.
+func (e *Extractor) TerminatingProxy(r *http.Request) string {
+	lines := e.values(requestInput(r), forwardedHeader)
+	for i := len(lines) - 1; i >= 0; i-- {
+		elements := e.parseForwarded(lines[i])
+		for j := len(elements) - 1; j >= 0; j-- {
+			by := elements[j].By
+			if by != "" && !isHiddenNode(by) {
+				return by
+			}
+		}
+	}
+
+	return ""
+}
+
+// TerminatingProxy returns the by parameter of the Forwarded header added by
+// the proxy closest to the server, skipping unknown and obfuscated values.
+func TerminatingProxy(r *http.Request) string {
+	return defaultExtractor.TerminatingProxy(r)
+}
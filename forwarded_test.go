@@ -0,0 +1,383 @@
+package realip
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestParseForwarded(t *testing.T) {
+	testData := []struct {
+		header   string
+		expected []ForwardedElement
+	}{
+		{
+			header:   "for=192.0.2.60;proto=https;by=203.0.113.43",
+			expected: []ForwardedElement{{For: "192.0.2.60", Proto: "https", By: "203.0.113.43"}},
+		}, {
+			header: `for=192.0.2.43, for="[2001:db8:cafe::17]:4711";host="example.com"`,
+			expected: []ForwardedElement{
+				{For: "192.0.2.43"},
+				{For: "[2001:db8:cafe::17]:4711", Host: "example.com"},
+			},
+		}, {
+			header:   `for="[2001:db8::1]";PROTO=https;by=_proxy;host="example.com:8443"`,
+			expected: []ForwardedElement{{For: "[2001:db8::1]", Proto: "https", By: "_proxy", Host: "example.com:8443"}},
+		}, {
+			header:   `For="a\"b;c,d"; Host=example.com`,
+			expected: []ForwardedElement{{For: `a"b;c,d`, Host: "example.com"}},
+		},
+	}
+
+	for _, v := range testData {
+		if actual := ParseForwarded(v.header); !reflect.DeepEqual(actual, v.expected) {
+			t.Errorf("%s: expected %+v but get %+v", v.header, v.expected, actual)
+		}
+	}
+}
+
+func TestForwardedElements(t *testing.T) {
+	testData := []struct {
+		forwarded string
+		expected  []string
+	}{
+		{
+			forwarded: "for=1.1.1.1;proto=https, for=2.2.2.2;proto=http",
+			expected:  []string{"1.1.1.1", "2.2.2.2"},
+		}, {
+			forwarded: `proto=https;for="[2001:db8::1]:8080";by=10.0.0.1, for=144.12.54.87`,
+			expected:  []string{"2001:db8::1", "144.12.54.87"},
+		}, {
+			forwarded: `for="144.12.54.87:4711";host="forum.example.com", FOR=119.14.55.11`,
+			expected:  []string{"144.12.54.87", "119.14.55.11"},
+		}, {
+			forwarded: `host="a;for=6.6.6.6,b";for=13.182.55.11`,
+			expected:  []string{"13.182.55.11"},
+		}, {
+			forwarded: `for="[2001:db8::1"`,
+			expected:  nil,
+		},
+	}
+
+	for _, v := range testData {
+		r := &http.Request{Header: http.Header{"Forwarded": {v.forwarded}}}
+		if actual := ChainFromRequest(r); !reflect.DeepEqual(actual, v.expected) {
+			t.Errorf("%s: expected %v but get %v", v.forwarded, v.expected, actual)
+		}
+	}
+}
+
+func TestForwardedMultipleLines(t *testing.T) {
+	r := &http.Request{RemoteAddr: "10.0.0.1:8080", Header: http.Header{
+		"Forwarded": {"for=192.168.1.10;proto=http", "for=144.12.54.87;proto=https"},
+	}}
+
+	if actual := FromRequest(r); actual != "144.12.54.87" {
+		t.Errorf("FromRequest: expected %s but get %s", "144.12.54.87", actual)
+	}
+	if actual := ChainFromRequest(r); !reflect.DeepEqual(actual, []string{"192.168.1.10", "144.12.54.87"}) {
+		t.Errorf("ChainFromRequest: expected %v but get %v", []string{"192.168.1.10", "144.12.54.87"}, actual)
+	}
+
+	_, trusted, _ := net.ParseCIDR("10.0.0.0/8")
+	r.Header["Forwarded"] = []string{"for=144.12.54.87", "for=10.0.0.2"}
+	if actual := New(WithTrustedProxies(trusted)).FromRequest(r); actual != "144.12.54.87" {
+		t.Errorf("Trusted: expected %s but get %s", "144.12.54.87", actual)
+	}
+
+	r.Header["Forwarded"] = []string{"for=144.12.54.87", "for=[2001:db8::1]"}
+	if _, err := New(WithStrictRFC7239()).FromRequestE(r); !errors.Is(err, ErrMalformedForwarded) {
+		t.Errorf("Strict second line: expected %v but get %v", ErrMalformedForwarded, err)
+	}
+}
+
+func TestForwardedInternalWhitespace(t *testing.T) {
+	testData := []string{
+		"for=144.12.54.87 \t ;  proto=https  ,\t for=119.14.55.11",
+		"\tfor = 144.12.54.87;\tby=10.0.0.1",
+		"for=10.0.0.1,\r\n\tfor=144.12.54.87",
+		"for=\"144.12.54.87\"\t\t;\t\tproto=http",
+		"proto=https ;   for =  144.12.54.87",
+	}
+
+	for _, forwarded := range testData {
+		r := &http.Request{Header: http.Header{"Forwarded": {forwarded}}}
+		if actual := FromRequest(r); actual != "144.12.54.87" {
+			t.Errorf("%q: expected %s but get %s", forwarded, "144.12.54.87", actual)
+		}
+		if actual, err := New(WithStrictRFC7239()).FromRequestE(r); actual != "144.12.54.87" || err != nil {
+			t.Errorf("%q strict: expected %s but get %s (%v)", forwarded, "144.12.54.87", actual, err)
+		}
+		if elements := ParseForwarded(forwarded); len(elements) == 0 {
+			t.Errorf("%q: expected elements but get none", forwarded)
+		}
+	}
+
+	r := &http.Request{Header: http.Header{"Forwarded": {"for=144.12.54.87 \t;\tby=10.0.0.1 ,\t for=119.14.55.11;  by = 10.0.0.2"}}}
+	if actual := TerminatingProxy(r); actual != "10.0.0.2" {
+		t.Errorf("TerminatingProxy: expected %s but get %s", "10.0.0.2", actual)
+	}
+}
+
+func TestForwardedOptionalWhitespace(t *testing.T) {
+	_, proxies, _ := net.ParseCIDR("10.0.0.0/8")
+
+	testData := []struct {
+		header string
+		chain  []string
+		port   string
+	}{
+		{header: "for= 203.0.113.9 ; proto=https", chain: []string{"203.0.113.9"}},
+		{header: "for=\t203.0.113.9\t;\tproto=https", chain: []string{"203.0.113.9"}},
+		{header: "for  =  \"203.0.113.9:4711\"  ;proto = https", chain: []string{"203.0.113.9"}, port: "4711"},
+		{header: "for=\t\"[2a00:1450::1]:4711\" \t; proto=https", chain: []string{"2a00:1450::1"}, port: "4711"},
+		{header: "for=203.0.113.9 \t,\t\t for = 10.0.0.2 ;by=10.0.0.1", chain: []string{"203.0.113.9", "10.0.0.2"}},
+		{header: "proto=https\t;  for=\t 203.0.113.9", chain: []string{"203.0.113.9"}},
+	}
+
+	trusted := NewExtractor([]*net.IPNet{proxies})
+	for _, v := range testData {
+		r := &http.Request{RemoteAddr: "10.0.0.1:8080", Header: http.Header{"Forwarded": {v.header}}}
+		if actual := ChainFromRequest(r); !reflect.DeepEqual(actual, v.chain) {
+			t.Errorf("%q: expected chain %v but get %v", v.header, v.chain, actual)
+		}
+		if actual := trusted.FromRequest(r); actual != v.chain[0] {
+			t.Errorf("%q trusted: expected %s but get %s", v.header, v.chain[0], actual)
+		}
+		if _, actual := FromRequestHostPort(r); actual != v.port {
+			t.Errorf("%q port: expected %q but get %q", v.header, v.port, actual)
+		}
+		if actual, err := New(WithStrictRFC7239()).FromRequestE(r); err != nil {
+			t.Errorf("%q strict: expected %s but get %s (%v)", v.header, v.chain[0], actual, err)
+		}
+	}
+
+	elements := ParseForwarded("for=\t203.0.113.9 ;  proto = https\t; host =\texample.com ,\tfor = 10.0.0.2")
+	expected := []ForwardedElement{{For: "203.0.113.9", Proto: "https", Host: "example.com"}, {For: "10.0.0.2"}}
+	if !reflect.DeepEqual(elements, expected) {
+		t.Errorf("ParseForwarded: expected %+v but get %+v", expected, elements)
+	}
+}
+
+func TestIsStrictForwardedNode(t *testing.T) {
+	testData := map[string]bool{
+		"192.0.2.43":                 true,
+		`"192.0.2.43:47011"`:         true,
+		"192.0.2.43:47011":           false,
+		`"[2001:db8:cafe::17]"`:      true,
+		`"[2001:db8:cafe::17]:4711"`: true,
+		"[2001:db8:cafe::17]":        false,
+		"2001:db8:cafe::17":          false,
+		`"2001:db8:cafe::17"`:        false,
+		`"[192.0.2.43]"`:             false,
+		"unknown":                    true,
+		`":_p1"`:                     false,
+		`""`:                         false,
+		"":                           false,
+		"_hidden":                    true,
+		`"_hidden:_port"`:            true,
+		`"192.0.2.43:123456"`:        false,
+		`"]:"`:                       false,
+		"_":                          false,
+	}
+
+	for node, expected := range testData {
+		if actual := isStrictForwardedNode(node); actual != expected {
+			t.Errorf("%s: expected %t but get %t", node, expected, actual)
+		}
+	}
+}
+
+func TestForwardedPartialNodes(t *testing.T) {
+	testData := []string{
+		`for=":_p1", for=144.12.54.87`,
+		`for="", for=144.12.54.87`,
+		`for=, for=144.12.54.87`,
+		`for=":", for=144.12.54.87`,
+		`for="[", for=144.12.54.87`,
+		`for="]:", for=144.12.54.87`,
+	}
+
+	for _, forwarded := range testData {
+		r := &http.Request{Header: http.Header{"Forwarded": {forwarded}}}
+		if actual := FromRequest(r); actual != "144.12.54.87" {
+			t.Errorf("%s: expected %s but get %s", forwarded, "144.12.54.87", actual)
+		}
+		if _, err := New(WithStrictRFC7239()).FromRequestE(r); !errors.Is(err, ErrMalformedForwarded) {
+			t.Errorf("%s: expected %v in strict mode but get %v", forwarded, ErrMalformedForwarded, err)
+		}
+	}
+}
+
+func TestForwardedForParameterName(t *testing.T) {
+	testData := []string{
+		"For=203.0.113.9",
+		"FOR=203.0.113.9",
+		"forbidden=1; for=203.0.113.9",
+		"forwarded-something=144.12.54.87; for=203.0.113.9",
+		"by=144.12.54.87;For=203.0.113.9",
+		"for-real=144.12.54.87, for=203.0.113.9",
+	}
+
+	for _, forwarded := range testData {
+		r := &http.Request{RemoteAddr: "10.0.0.1:8080", Header: http.Header{"Forwarded": {forwarded}}}
+		if actual := FromRequest(r); actual != "203.0.113.9" {
+			t.Errorf("%s: expected %s but get %s", forwarded, "203.0.113.9", actual)
+		}
+		if actual := ChainFromRequest(r); len(actual) != 1 || actual[0] != "203.0.113.9" {
+			t.Errorf("%s: expected chain [%s] but get %v", forwarded, "203.0.113.9", actual)
+		}
+	}
+}
+
+func TestHiddenNodes(t *testing.T) {
+	_, proxies, _ := net.ParseCIDR("10.0.0.0/8")
+	trusted := NewExtractor([]*net.IPNet{proxies})
+
+	testData := []struct {
+		name   string
+		header http.Header
+	}{
+		{name: "Forwarded unknown", header: http.Header{"Forwarded": {"for=unknown, for=203.0.113.9"}}},
+		{name: "Forwarded quoted unknown", header: http.Header{"Forwarded": {`for="UNKNOWN", for=203.0.113.9`}}},
+		{name: "Forwarded obfuscated", header: http.Header{"Forwarded": {"for=_secret, for=203.0.113.9"}}},
+		{name: "Forwarded obfuscated port", header: http.Header{"Forwarded": {`for="_secret:_port", for=203.0.113.9`}}},
+		{name: "X-Forwarded-For unknown", header: http.Header{"X-Forwarded-For": {"unknown, 203.0.113.9"}}},
+		{name: "X-Forwarded-For obfuscated", header: http.Header{"X-Forwarded-For": {"_secret, 203.0.113.9"}}},
+	}
+
+	for _, v := range testData {
+		r := &http.Request{RemoteAddr: "10.0.0.1:8080", Header: v.header}
+		if actual := FromRequest(r); actual != "203.0.113.9" {
+			t.Errorf("%s: expected %s but get %s", v.name, "203.0.113.9", actual)
+		}
+		if actual := ChainFromRequest(r); len(actual) != 1 || actual[0] != "203.0.113.9" {
+			t.Errorf("%s: expected chain [%s] but get %v", v.name, "203.0.113.9", actual)
+		}
+	}
+
+	// A hidden node written by a trusted proxy ends the walk for the client,
+	// so the entries to its left, written by the client, are never promoted
+	hops := New(WithTrustedHops(1))
+	trustedData := []struct {
+		name      string
+		extractor *Extractor
+		xff       string
+		expected  string
+	}{
+		{name: "trusted proxies unknown", extractor: trusted, xff: "6.6.6.6, unknown", expected: "10.0.0.1"},
+		{name: "trusted proxies obfuscated", extractor: trusted, xff: "6.6.6.6, _secret", expected: "10.0.0.1"},
+		{name: "trusted proxies unknown behind a trusted hop", extractor: trusted, xff: "6.6.6.6, unknown, 10.0.0.2", expected: "10.0.0.2"},
+		{name: "trusted proxies unknown after the client", extractor: trusted, xff: "unknown, 203.0.113.9, 10.0.0.2", expected: "203.0.113.9"},
+		{name: "trusted hops unknown", extractor: hops, xff: "6.6.6.6, 203.0.113.9, unknown", expected: "10.0.0.1"},
+		{name: "trusted hops obfuscated", extractor: hops, xff: "6.6.6.6, _secret, 10.0.0.2", expected: "10.0.0.1"},
+	}
+
+	for _, v := range trustedData {
+		r := &http.Request{RemoteAddr: "10.0.0.1:8080", Header: http.Header{"X-Forwarded-For": {v.xff}}}
+		if actual := v.extractor.FromRequest(r); actual != v.expected {
+			t.Errorf("%s: expected %s but get %s", v.name, v.expected, actual)
+		}
+	}
+
+	for _, forwarded := range []string{"for=6.6.6.6, for=unknown", `for=6.6.6.6, for="_secret:_port"`} {
+		r := &http.Request{RemoteAddr: "10.0.0.1:8080", Header: http.Header{"Forwarded": {forwarded}}}
+		if actual := trusted.FromRequest(r); actual != "10.0.0.1" {
+			t.Errorf("trusted proxies %s: expected %s but get %s", forwarded, "10.0.0.1", actual)
+		}
+	}
+}
+
+func TestWithLenientForwardedSeparators(t *testing.T) {
+	testData := []struct {
+		header  string
+		strict  []ForwardedElement
+		lenient []ForwardedElement
+	}{
+		{
+			header:  "for=192.0.2.1, proto=https",
+			strict:  []ForwardedElement{{For: "192.0.2.1"}, {Proto: "https"}},
+			lenient: []ForwardedElement{{For: "192.0.2.1", Proto: "https"}},
+		}, {
+			header:  "for=192.0.2.1, proto=https, for=198.51.100.1;proto=http",
+			strict:  []ForwardedElement{{For: "192.0.2.1"}, {Proto: "https"}, {For: "198.51.100.1", Proto: "http"}},
+			lenient: []ForwardedElement{{For: "192.0.2.1", Proto: "https"}, {For: "198.51.100.1", Proto: "http"}},
+		}, {
+			header:  "for=192.0.2.1;proto=https, for=198.51.100.1",
+			strict:  []ForwardedElement{{For: "192.0.2.1", Proto: "https"}, {For: "198.51.100.1"}},
+			lenient: []ForwardedElement{{For: "192.0.2.1", Proto: "https"}, {For: "198.51.100.1"}},
+		},
+	}
+
+	strict, lenient := New(), New(WithLenientForwardedSeparators())
+	for _, v := range testData {
+		if actual := strict.parseForwarded(v.header); !reflect.DeepEqual(actual, v.strict) {
+			t.Errorf("strict %s: expected %+v but get %+v", v.header, v.strict, actual)
+		}
+		if actual := lenient.parseForwarded(v.header); !reflect.DeepEqual(actual, v.lenient) {
+			t.Errorf("lenient %s: expected %+v but get %+v", v.header, v.lenient, actual)
+		}
+	}
+}
+
+func TestWithLenientForwardedFor(t *testing.T) {
+	testData := []struct {
+		header  string
+		strict  []string
+		lenient []string
+	}{
+		{header: "for=203.0.113.9, 198.51.100.1", strict: []string{"203.0.113.9"}, lenient: []string{"203.0.113.9", "198.51.100.1"}},
+		{header: `for=203.0.113.9, 198.51.100.1, "[2a00:1450::1]:4711"`, strict: []string{"203.0.113.9"}, lenient: []string{"203.0.113.9", "198.51.100.1", "2a00:1450::1"}},
+		{header: "for=203.0.113.9;proto=https, 198.51.100.1, for=144.12.54.87", strict: []string{"203.0.113.9", "144.12.54.87"}, lenient: []string{"203.0.113.9", "198.51.100.1", "144.12.54.87"}},
+		{header: "proto=https, 198.51.100.1, for=144.12.54.87", strict: []string{"144.12.54.87"}, lenient: []string{"144.12.54.87"}},
+		{header: "198.51.100.1, for=144.12.54.87", strict: []string{"144.12.54.87"}, lenient: []string{"144.12.54.87"}},
+		{header: "for=203.0.113.9, proto=https, 198.51.100.1", strict: []string{"203.0.113.9"}, lenient: []string{"203.0.113.9"}},
+		{header: "for=203.0.113.9, unknown, 198.51.100.1", strict: []string{"203.0.113.9"}, lenient: []string{"203.0.113.9", "198.51.100.1"}},
+	}
+
+	strict, lenient := New(), New(WithLenientForwardedFor())
+	for _, v := range testData {
+		r := &http.Request{RemoteAddr: "10.0.0.1:8080", Header: http.Header{"Forwarded": {v.header}}}
+		if actual := strict.ChainFromRequest(r); !reflect.DeepEqual(actual, v.strict) {
+			t.Errorf("strict %s: expected %v but get %v", v.header, v.strict, actual)
+		}
+		if actual := lenient.ChainFromRequest(r); !reflect.DeepEqual(actual, v.lenient) {
+			t.Errorf("lenient %s: expected %v but get %v", v.header, v.lenient, actual)
+		}
+	}
+
+	_, proxies, _ := net.ParseCIDR("10.0.0.0/8")
+	r := &http.Request{RemoteAddr: "10.0.0.1:8080", Header: http.Header{"Forwarded": {"for=203.0.113.9, 144.12.54.87"}}}
+	if actual := New(WithLenientForwardedFor(), WithTrustedProxies(proxies)).FromRequest(r); actual != "144.12.54.87" {
+		t.Errorf("trusted: expected %s but get %s", "144.12.54.87", actual)
+	}
+}
+
+func TestTerminatingProxy(t *testing.T) {
+	testData := []struct {
+		name      string
+		forwarded []string
+		expected  string
+	}{
+		{name: "No header", expected: ""},
+		{name: "Single element", forwarded: []string{"for=144.12.54.87;by=10.0.0.1"}, expected: "10.0.0.1"},
+		{name: "Distinct by values", forwarded: []string{"for=144.12.54.87;by=13.182.55.11, for=13.182.55.11;by=10.0.0.1"}, expected: "10.0.0.1"},
+		{name: "Several lines", forwarded: []string{"for=144.12.54.87;by=13.182.55.11", "for=13.182.55.11;by=localhost"}, expected: "localhost"},
+		{name: "Obfuscated skipped", forwarded: []string{"for=144.12.54.87;by=10.0.0.1, for=10.0.0.1;by=_edge"}, expected: "10.0.0.1"},
+		{name: "Malformed obfuscated kept", forwarded: []string{"for=144.12.54.87;by=10.0.0.1, for=10.0.0.1;by=_"}, expected: "_"},
+		{name: "Unknown skipped", forwarded: []string{"for=144.12.54.87;by=10.0.0.1, for=10.0.0.1;by=unknown"}, expected: "10.0.0.1"},
+		{name: "Quoted IPv6", forwarded: []string{`for=144.12.54.87;by="[2001:db8::1]:443"`}, expected: "[2001:db8::1]:443"},
+	}
+
+	for _, v := range testData {
+		r := &http.Request{Header: http.Header{}}
+		for _, line := range v.forwarded {
+			r.Header.Add("Forwarded", line)
+		}
+		if actual := TerminatingProxy(r); actual != v.expected {
+			t.Errorf("%s: expected %s but get %s", v.name, v.expected, actual)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package realip
+
+import "net/http"
+
+// input is what the resolution of a client address reads: the values of the
+// request headers, and the address of the peer. The headers are looked up in
+// header, or through values when header is nil, so that FromHeaders resolves
+// addresses without building an http.Request.
+type input struct {
+	header     http.Header
+	values     func(name string) []string
+	remoteAddr string
+}
+
+// requestInput returns the input of r.
+func requestInput(r *http.Request) input {
+	return input{header: r.Header, remoteAddr: r.RemoteAddr}
+}
+
+// lookup returns every value of the named header, given in canonical form.
+func (in input) lookup(name string) []string {
+	if in.values != nil {
+		return in.values(name)
+	}
+
+	return in.header[name]
+}
+
+// FromHeaders returns the client address FromRequest would return for a
+// request with the given headers and remote address, for servers and
+// transports that do not use http.Request, such as fasthttp.
+//
+// values returns every value of the named header, in canonical form, e.g.
+// X-Forwarded-For. When values is nil, get, which returns the first value of
+// the named header, is used instead. remoteAddr is the address of the peer,
+// in the form of http.Request.RemoteAddr.
+func (e *Extractor) FromHeaders(get func(name string) string, values func(name string) []string, remoteAddr string) string {
+	if values == nil {
+		return e.FromHeadersBatch(new(Scratch), get, nil, remoteAddr)
+	}
+
+	return e.fromInput(input{values: values, remoteAddr: remoteAddr}, nil)
+}
+
+// Scratch holds the buffer FromHeadersBatch keeps the values returned by get
+// in, so it is reused from one call to the next instead of allocated on every
+// call. The zero Scratch is ready to use. A Scratch must not be shared by
+// concurrent calls; use one per goroutine.
+type Scratch struct {
+	get     func(name string) string
+	singles []string
+	lookup  func(name string) []string
+}
+
+// FromHeadersBatch returns the client address FromHeaders would return,
+// reusing the buffer of s, for callers resolving many inputs in a row such
+// as replayed access logs. It is not safe for concurrent use with a shared
+// Scratch.
+func (e *Extractor) FromHeadersBatch(s *Scratch, get func(name string) string, values func(name string) []string, remoteAddr string) string {
+	if values == nil {
+		if s.lookup == nil {
+			s.lookup = s.values
+		}
+		s.get, s.singles, values = get, s.singles[:0], s.lookup
+	}
+
+	return e.fromInput(input{values: values, remoteAddr: remoteAddr}, nil)
+}
+
+// values returns the value of the named header returned by get, if any, as a
+// single element slice held by s.
+func (s *Scratch) values(name string) []string {
+	if s.get == nil {
+		return nil
+	}
+	v := s.get(name)
+	if v == "" {
+		return nil
+	}
+	s.singles = append(s.singles, v)
+	n := len(s.singles)
+
+	return s.singles[n-1 : n : n]
+}
+
+// FromHeaders returns the client address FromRequest would return for a
+// request with the given headers and remote address.
+func FromHeaders(get func(name string) string, values func(name string) []string, remoteAddr string) string {
+	return defaultExtractor.FromHeaders(get, values, remoteAddr)
+}
+
+// FromHeadersBatch returns the client address FromHeaders would return,
+// reusing the buffer of s. It is not safe for concurrent use with a shared
+// Scratch.
+func FromHeadersBatch(s *Scratch, get func(name string) string, values func(name string) []string, remoteAddr string) string {
+	return defaultExtractor.FromHeadersBatch(s, get, values, remoteAddr)
+}
@@ -0,0 +1,77 @@
+package realip
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestFromHeaders(t *testing.T) {
+	header := http.Header{
+		"X-Forwarded-For": {"10.0.0.2", "144.12.54.87"},
+		"X-Real-Ip":       {"119.14.55.11"},
+	}
+
+	testData := []struct {
+		name       string
+		get        func(string) string
+		values     func(string) []string
+		remoteAddr string
+		expected   string
+	}{
+		{name: "Values", values: func(name string) []string { return header[name] }, remoteAddr: "10.0.0.1:8080", expected: "144.12.54.87"},
+		{name: "Get", get: header.Get, remoteAddr: "10.0.0.1:8080", expected: "119.14.55.11"},
+		{name: "Values over get", get: func(string) string { return "1.2.3.4" }, values: func(name string) []string { return header[name] }, remoteAddr: "10.0.0.1:8080", expected: "144.12.54.87"},
+		{name: "No getter", remoteAddr: "10.0.0.1:8080", expected: "10.0.0.1"},
+		{name: "No headers", get: func(string) string { return "" }, remoteAddr: "[2a00:1450::1]:443", expected: "2a00:1450::1"},
+	}
+
+	for _, v := range testData {
+		if actual := FromHeaders(v.get, v.values, v.remoteAddr); actual != v.expected {
+			t.Errorf("%s: expected %s but get %s", v.name, v.expected, actual)
+		}
+	}
+}
+
+func TestFromHeadersBatch(t *testing.T) {
+	headers := []http.Header{
+		{"X-Forwarded-For": {"10.0.0.2", "144.12.54.87"}},
+		{"X-Real-Ip": {"119.14.55.11"}},
+		{"Forwarded": {"for=1.2.3.4"}},
+		{},
+	}
+
+	var s Scratch
+	for i := 0; i < 3; i++ {
+		for _, header := range headers {
+			values := func(name string) []string { return header[name] }
+			expected := FromHeaders(nil, values, "10.0.0.1:8080")
+			if actual := FromHeadersBatch(&s, nil, values, "10.0.0.1:8080"); actual != expected {
+				t.Errorf("Values %v: expected %s but get %s", header, expected, actual)
+			}
+
+			expected = FromHeaders(header.Get, nil, "10.0.0.1:8080")
+			if actual := FromHeadersBatch(&s, header.Get, nil, "10.0.0.1:8080"); actual != expected {
+				t.Errorf("Get %v: expected %s but get %s", header, expected, actual)
+			}
+		}
+	}
+}
+
+func BenchmarkFromHeadersBatch(b *testing.B) {
+	header := http.Header{"X-Forwarded-For": {"10.0.0.2, 144.12.54.87, 10.0.0.3"}, "X-Real-Ip": {"119.14.55.11"}}
+
+	b.Run("FromHeaders", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			FromHeaders(header.Get, nil, "10.0.0.1:8080")
+		}
+	})
+
+	b.Run("FromHeadersBatch", func(b *testing.B) {
+		var s Scratch
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			FromHeadersBatch(&s, header.Get, nil, "10.0.0.1:8080")
+		}
+	})
+}
@@ -1,3 +1,3 @@
 module go.ajitem.com/realip
 
-go 1.12
+go 1.18
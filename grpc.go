@@ -0,0 +1,32 @@
+package realip
+
+import (
+	"net"
+	"strings"
+)
+
+// FromGRPCMetadata returns the client address of a gRPC call, read from the
+// forwarding headers of its incoming metadata with the same selection logic
+// as FromRequest. fallback is the address of the peer, as found in the Addr
+// of the call's peer.Peer, and is used like the remote address of an HTTP
+// request: when no forwarding header is present, or when it is not a trusted
+// proxy.
+//
+// md is typically a metadata.MD, whose keys are lower case.
+func (e *Extractor) FromGRPCMetadata(md map[string][]string, fallback net.Addr) string {
+	remoteAddr := ""
+	if fallback != nil {
+		remoteAddr = fallback.String()
+	}
+
+	return e.FromHeaders(nil, func(name string) []string {
+		return md[strings.ToLower(name)]
+	}, remoteAddr)
+}
+
+// FromGRPCMetadata returns the client address of a gRPC call, read from the
+// forwarding headers of its incoming metadata like FromRequest, or the
+// address of the peer.
+func FromGRPCMetadata(md map[string][]string, fallback net.Addr) string {
+	return defaultExtractor.FromGRPCMetadata(md, fallback)
+}
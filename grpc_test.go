@@ -0,0 +1,39 @@
+package realip
+
+import (
+	"net"
+	"testing"
+)
+
+func TestFromGRPCMetadata(t *testing.T) {
+	peer := &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 50051}
+	_, proxies, _ := net.ParseCIDR("10.0.0.0/8")
+
+	testData := []struct {
+		name      string
+		extractor *Extractor
+		md        map[string][]string
+		fallback  net.Addr
+		expected  string
+	}{
+		{name: "X-Forwarded-For", extractor: New(), md: map[string][]string{"x-forwarded-for": {"10.0.0.2, 144.12.54.87"}}, fallback: peer, expected: "144.12.54.87"},
+		{name: "Forwarded", extractor: New(), md: map[string][]string{"forwarded": {"for=144.12.54.87"}}, fallback: peer, expected: "144.12.54.87"},
+		{name: "X-Real-IP", extractor: New(), md: map[string][]string{"x-real-ip": {"144.12.54.87"}}, fallback: peer, expected: "144.12.54.87"},
+		{name: "No headers", extractor: New(), md: map[string][]string{"user-agent": {"grpc-go"}}, fallback: peer, expected: "10.0.0.1"},
+		{name: "No metadata", extractor: New(), md: nil, fallback: &net.TCPAddr{IP: net.ParseIP("2a00:1450::1"), Port: 443}, expected: "2a00:1450::1"},
+		{name: "No peer", extractor: New(), md: nil, fallback: nil, expected: ""},
+		{name: "Trusted proxy", extractor: NewExtractor([]*net.IPNet{proxies}), md: map[string][]string{"x-forwarded-for": {"1.2.3.4, 144.12.54.87"}}, fallback: peer, expected: "144.12.54.87"},
+		{name: "Untrusted peer", extractor: NewExtractor([]*net.IPNet{proxies}), md: map[string][]string{"x-forwarded-for": {"1.2.3.4"}}, fallback: &net.TCPAddr{IP: net.ParseIP("119.14.55.11"), Port: 50051}, expected: "119.14.55.11"},
+		{name: "Custom header", extractor: New(WithHeaders(CFConnectingIPHeader)), md: map[string][]string{"cf-connecting-ip": {"144.12.54.87"}}, fallback: peer, expected: "144.12.54.87"},
+	}
+
+	for _, v := range testData {
+		if actual := v.extractor.FromGRPCMetadata(v.md, v.fallback); actual != v.expected {
+			t.Errorf("%s: expected %s but get %s", v.name, v.expected, actual)
+		}
+	}
+
+	if actual := FromGRPCMetadata(map[string][]string{"x-forwarded-for": {"144.12.54.87"}}, peer); actual != "144.12.54.87" {
+		t.Errorf("package FromGRPCMetadata: expected %s but get %s", "144.12.54.87", actual)
+	}
+}
@@ -0,0 +1,210 @@
+package realip
+
+import "net/http"
+
+// HeaderKind describes how the value of a forwarding header is parsed.
+type HeaderKind int
+
+const (
+	// HeaderList is a comma separated list of addresses, from the client to
+	// the closest proxy, like X-Forwarded-For.
+	HeaderList HeaderKind = iota + 1
+
+	// HeaderForwarded is an RFC 7239 Forwarded header, whose client
+	// addresses are the for parameters of its elements.
+	HeaderForwarded
+
+	// HeaderSingle holds a single address, like X-Real-IP. It is set by the
+	// proxy that terminates the connection, so a valid address is used as
+	// is, without checking whether it is public.
+	HeaderSingle
+)
+
+// Selection describes which of the addresses of a header are used.
+type Selection int
+
+const (
+	// SelectAll uses every address of the header. For a single address
+	// header, it is the same as SelectFirst.
+	SelectAll Selection = iota
+
+	// SelectFirst only uses the address of the header farthest from the
+	// server, the one the client claims, or the first line of a single
+	// address header.
+	SelectFirst
+
+	// SelectLast only uses the address of the header closest to the server,
+	// the one added by the closest proxy, or the last line of a single
+	// address header.
+	SelectLast
+)
+
+// HeaderSpec describes a forwarding header of a header chain.
+type HeaderSpec struct {
+	Name         string
+	Kind         HeaderKind
+	SingleOrLast Selection
+}
+
+// Vendor headers holding the client address as a single value, set by the
+// CDN in front of the application.
+const (
+	// CFConnectingIPHeader is set by Cloudflare.
+	CFConnectingIPHeader = "Cf-Connecting-Ip"
+
+	// TrueClientIPHeader is set by Cloudflare Enterprise and Akamai.
+	TrueClientIPHeader = "True-Client-Ip"
+
+	// FastlyClientIPHeader is set by Fastly.
+	FastlyClientIPHeader = "Fastly-Client-Ip"
+
+	// XClientIPHeader is set by some load balancers and hosting platforms.
+	XClientIPHeader = "X-Client-Ip"
+)
+
+// XProxyUserIPHeader is the legacy X-ProxyUser-Ip header, which carried the
+// user's address in some Google-originated traffic. It is not read unless
+// added with WithHeader(XProxyUserIPHeader, HeaderSingle).
+const XProxyUserIPHeader = "X-Proxyuser-Ip"
+
+// XForwardedHeader is the legacy X-Forwarded header sent by some old systems,
+// e.g. X-Forwarded: for=1.2.3.4, whose elements follow the syntax of the RFC
+// 7239 Forwarded header. It is not read unless added with
+// WithHeader(XForwardedHeader, HeaderForwarded), or WithHeaders, which then
+// reads it as a Forwarded header.
+const XForwardedHeader = "X-Forwarded"
+
+// defaultHeaderChain is the header chain of an Extractor created without
+// WithHeaderChain: every address of X-Forwarded-For, then of Forwarded, then
+// X-Real-IP. It is shared by every such Extractor and must not be modified.
+var defaultHeaderChain = []HeaderSpec{
+	{Name: xForwardedForHeader, Kind: HeaderList},
+	{Name: forwardedHeader, Kind: HeaderForwarded},
+	{Name: xRealIpHeader, Kind: HeaderSingle},
+}
+
+// CDNHeaders returns a header chain for applications behind a CDN, to pass to
+// WithHeaderChain: Cf-Connecting-Ip, True-Client-Ip and Fastly-Client-Ip, then
+// the default X-Forwarded-For, Forwarded and X-Real-IP. The CDN headers are
+// read as lists whose last address is used, so a private value is skipped
+// in favor of the next header rather than used as is. X-Client-Ip is left
+// out, as clients can often set it; add it with WithHeader if needed.
+func CDNHeaders() []HeaderSpec {
+	names := CDNHeaderNames()
+	chain := make([]HeaderSpec, len(names))
+	for i, name := range names {
+		chain[i] = headerSpec(name)
+	}
+
+	return chain
+}
+
+// CDNHeaderNames returns the names of the headers of CDNHeaders, in order, to
+// pass to WithHeaders or Options.Headers, which read them the same way.
+func CDNHeaderNames() []string {
+	return []string{
+		CFConnectingIPHeader, TrueClientIPHeader, FastlyClientIPHeader,
+		xForwardedForHeader, forwardedHeader, xRealIpHeader,
+	}
+}
+
+// headerSpec returns the spec of the named header: X-Forwarded-For is a list,
+// Forwarded and X-Forwarded RFC 7239 headers, the headers of CDNHeaders lists
+// whose last address is used, and any other header holds a single address.
+func headerSpec(name string) HeaderSpec {
+	name = http.CanonicalHeaderKey(name)
+	switch name {
+	case xForwardedForHeader:
+		return HeaderSpec{Name: name, Kind: HeaderList}
+	case forwardedHeader, XForwardedHeader:
+		return HeaderSpec{Name: name, Kind: HeaderForwarded}
+	case CFConnectingIPHeader, TrueClientIPHeader, FastlyClientIPHeader:
+		return HeaderSpec{Name: name, Kind: HeaderList, SingleOrLast: SelectLast}
+	}
+
+	return HeaderSpec{Name: name, Kind: HeaderSingle}
+}
+
+// single returns the value of the single address header described by spec.
+func (e *Extractor) single(in input, spec HeaderSpec) string {
+	lines := e.values(in, spec.Name)
+	if len(lines) == 0 {
+		return ""
+	}
+	if spec.SingleOrLast == SelectLast {
+		return e.singleValue(lines[len(lines)-1])
+	}
+
+	return e.singleValue(lines[0])
+}
+
+// walkHeader calls fn with the selected addresses of the header described by
+// spec, from the client to the closest proxy, until fn returns true, and
+// reports whether it did.
+func (e *Extractor) walkHeader(in input, spec HeaderSpec, fn func(address string) bool) bool {
+	return e.walkSelected(in, spec, false, fn)
+}
+
+// reverseWalker returns a function calling fn with the selected addresses of
+// the header described by spec, from the closest proxy to the client.
+func (e *Extractor) reverseWalker(spec HeaderSpec) func(input, func(string) bool) bool {
+	return func(in input, fn func(address string) bool) bool {
+		return e.walkSelected(in, spec, true, fn)
+	}
+}
+
+// walkSelected calls fn with the addresses of the header described by spec,
+// in the given direction, narrowed down to the first or the last one if spec
+// selects so.
+func (e *Extractor) walkSelected(in input, spec HeaderSpec, reverse bool, fn func(address string) bool) bool {
+	if spec.SingleOrLast == SelectAll || spec.Kind == HeaderSingle {
+		return e.scanHeader(in, spec, reverse, fn)
+	}
+
+	var first, last string
+	n := 0
+	e.scanHeader(in, spec, reverse, func(address string) bool {
+		if n == 0 {
+			first = address
+		}
+		last = address
+		n++
+		return false
+	})
+	if n == 0 {
+		return false
+	}
+
+	// Walking in reverse, the first address reached is the one closest to
+	// the server
+	if reverse {
+		first, last = last, first
+	}
+	if spec.SingleOrLast == SelectFirst {
+		return fn(first)
+	}
+
+	return fn(last)
+}
+
+// scanHeader calls fn with every address of the header described by spec, in
+// the given direction, until fn returns true, and reports whether it did.
+func (e *Extractor) scanHeader(in input, spec HeaderSpec, reverse bool, fn func(address string) bool) bool {
+	switch spec.Kind {
+	case HeaderList:
+		if reverse {
+			return e.walkListReverse(e.values(in, spec.Name), fn)
+		}
+		return e.walkList(e.values(in, spec.Name), fn)
+	case HeaderForwarded:
+		if reverse {
+			return e.walkForwardedLinesReverse(e.values(in, spec.Name), fn)
+		}
+		return e.walkForwardedLines(e.values(in, spec.Name), fn)
+	case HeaderSingle:
+		ip := e.single(in, spec)
+		return ip != "" && fn(ip)
+	}
+
+	return false
+}
@@ -0,0 +1,219 @@
+package realip
+
+import (
+	"net"
+	"net/http"
+	"testing"
+)
+
+func TestWithHeaderChain(t *testing.T) {
+	vendor := New(WithHeaderChain([]HeaderSpec{
+		{Name: "cf-connecting-ip", Kind: HeaderSingle},
+		{Name: "X-Forwarded-For", Kind: HeaderList, SingleOrLast: SelectLast},
+		{Name: "Forwarded", Kind: HeaderForwarded},
+	}))
+
+	testData := []struct {
+		name     string
+		request  *http.Request
+		expected string
+	}{
+		{
+			name: "Vendor header first",
+			request: &http.Request{RemoteAddr: "10.0.0.1:80", Header: http.Header{
+				"Cf-Connecting-Ip": {"119.14.55.11"},
+				"X-Forwarded-For":  {"144.12.54.87"},
+			}},
+			expected: "119.14.55.11",
+		}, {
+			name: "Last X-Forwarded-For entry only",
+			request: &http.Request{RemoteAddr: "10.0.0.1:80", Header: http.Header{
+				"X-Forwarded-For": {"1.2.3.4, 144.12.54.87", "13.182.55.11"},
+			}},
+			expected: "13.182.55.11",
+		}, {
+			name: "Private last entry falls through",
+			request: &http.Request{RemoteAddr: "10.0.0.1:80", Header: http.Header{
+				"X-Forwarded-For": {"144.12.54.87, 10.0.0.2"},
+				"Forwarded":       {"for=13.182.55.11"},
+			}},
+			expected: "13.182.55.11",
+		}, {
+			name: "Headers outside the chain are ignored",
+			request: &http.Request{RemoteAddr: "144.12.54.87:80", Header: http.Header{
+				"X-Real-Ip": {"119.14.55.11"},
+			}},
+			expected: "144.12.54.87",
+		},
+	}
+
+	for _, v := range testData {
+		if actual := vendor.FromRequest(v.request); actual != v.expected {
+			t.Errorf("%s: expected %s but get %s", v.name, v.expected, actual)
+		}
+	}
+}
+
+func TestDefaultHeaderChain(t *testing.T) {
+	explicit := New(WithHeaderChain([]HeaderSpec{
+		{Name: "X-Forwarded-For", Kind: HeaderList},
+		{Name: "Forwarded", Kind: HeaderForwarded},
+		{Name: "X-Real-IP", Kind: HeaderSingle},
+	}))
+
+	requests := []*http.Request{
+		{RemoteAddr: "10.0.0.1:80", Header: http.Header{"X-Forwarded-For": {"10.0.0.2, 144.12.54.87"}}},
+		{RemoteAddr: "10.0.0.1:80", Header: http.Header{"X-Forwarded-For": {"10.0.0.2"}, "Forwarded": {"for=119.14.55.11"}}},
+		{RemoteAddr: "10.0.0.1:80", Header: http.Header{"X-Forwarded-For": {"10.0.0.2"}, "X-Real-Ip": {"10.0.0.3"}}},
+		{RemoteAddr: "144.12.54.87:80", Header: http.Header{}},
+	}
+
+	for _, r := range requests {
+		if expected, actual := FromRequest(r), explicit.FromRequest(r); actual != expected {
+			t.Errorf("%v: expected %s but get %s", r.Header, expected, actual)
+		}
+	}
+}
+
+func TestWithHeaderChainTrusted(t *testing.T) {
+	_, trusted, _ := net.ParseCIDR("10.0.0.0/8")
+	e := New(WithTrustedProxies(trusted), WithHeaderChain([]HeaderSpec{
+		{Name: "X-Client-Chain", Kind: HeaderList},
+		{Name: "X-Forwarded-For", Kind: HeaderList},
+	}))
+
+	r := &http.Request{RemoteAddr: "10.0.0.1:80", Header: http.Header{
+		"X-Client-Chain":  {"1.2.3.4, 144.12.54.87, 10.0.0.2"},
+		"X-Forwarded-For": {"144.12.54.87"},
+	}}
+	if actual, err := e.FromRequestE(r); actual != "144.12.54.87" || err != nil {
+		t.Errorf("Agreeing headers: expected %s but get %s (%v)", "144.12.54.87", actual, err)
+	}
+
+	r.Header.Set("X-Forwarded-For", "119.14.55.11")
+	if _, err := e.FromRequestE(r); err != ErrSpoofingDetected {
+		t.Errorf("Contradicting headers: expected %v but get %v", ErrSpoofingDetected, err)
+	}
+}
+
+func TestWithHeader(t *testing.T) {
+	e := New(WithHeader(XProxyUserIPHeader, HeaderSingle))
+
+	r := &http.Request{RemoteAddr: "10.0.0.1:80", Header: http.Header{}}
+	r.Header.Set("X-ProxyUser-Ip", "144.12.54.87")
+	if actual := e.FromRequest(r); actual != "144.12.54.87" {
+		t.Errorf("X-ProxyUser-Ip: expected %s but get %s", "144.12.54.87", actual)
+	}
+	if actual := FromRequest(r); actual != "10.0.0.1" {
+		t.Errorf("Default: expected %s but get %s", "10.0.0.1", actual)
+	}
+
+	r.Header.Set("X-Forwarded-For", "119.14.55.11")
+	if actual := e.FromRequest(r); actual != "119.14.55.11" {
+		t.Errorf("Lower precedence: expected %s but get %s", "119.14.55.11", actual)
+	}
+	if len(defaultHeaderChain) != 3 {
+		t.Errorf("Default chain modified: %v", defaultHeaderChain)
+	}
+}
+
+func TestXForwardedHeader(t *testing.T) {
+	testData := []struct {
+		name      string
+		extractor *Extractor
+		header    http.Header
+		expected  string
+	}{
+		{name: "Only header", extractor: New(WithHeader(XForwardedHeader, HeaderForwarded)), header: http.Header{"X-Forwarded": {"for=144.12.54.87"}}, expected: "144.12.54.87"},
+		{name: "Several elements", extractor: New(WithHeader(XForwardedHeader, HeaderForwarded)), header: http.Header{"X-Forwarded": {`for=10.0.0.3;proto=https, for="[2a00:1450::1]:4711"`}}, expected: "2a00:1450::1"},
+		{name: "Lower precedence than X-Forwarded-For", extractor: New(WithHeader(XForwardedHeader, HeaderForwarded)), header: http.Header{"X-Forwarded": {"for=144.12.54.87"}, "X-Forwarded-For": {"119.14.55.11"}}, expected: "119.14.55.11"},
+		{name: "Lower precedence than Forwarded", extractor: New(WithHeader(XForwardedHeader, HeaderForwarded)), header: http.Header{"X-Forwarded": {"for=144.12.54.87"}, "Forwarded": {"for=119.14.55.11"}}, expected: "119.14.55.11"},
+		{name: "Lower precedence than X-Real-IP", extractor: New(WithHeader(XForwardedHeader, HeaderForwarded)), header: http.Header{"X-Forwarded": {"for=144.12.54.87"}, "X-Real-Ip": {"119.14.55.11"}}, expected: "119.14.55.11"},
+		{name: "WithHeaders", extractor: New(WithHeaders("x-forwarded")), header: http.Header{"X-Forwarded": {"for=10.0.0.3, for=144.12.54.87"}}, expected: "144.12.54.87"},
+		{name: "Not read by default", extractor: New(), header: http.Header{"X-Forwarded": {"for=144.12.54.87"}}, expected: "10.0.0.1"},
+	}
+
+	for _, v := range testData {
+		r := &http.Request{RemoteAddr: "10.0.0.1:80", Header: v.header}
+		if actual := v.extractor.FromRequest(r); actual != v.expected {
+			t.Errorf("%s: expected %s but get %s", v.name, v.expected, actual)
+		}
+	}
+}
+
+func TestCDNHeaders(t *testing.T) {
+	e := New(WithHeaderChain(CDNHeaders()))
+
+	testData := []struct {
+		name     string
+		header   http.Header
+		expected string
+	}{
+		{name: "Cf-Connecting-Ip", header: http.Header{"Cf-Connecting-Ip": {"119.14.55.11"}, "Fastly-Client-Ip": {"13.182.55.11"}}, expected: "119.14.55.11"},
+		{name: "Private CDN header", header: http.Header{"Cf-Connecting-Ip": {"10.0.0.3"}, "True-Client-Ip": {"192.168.0.1"}, "Fastly-Client-Ip": {"13.182.55.11"}}, expected: "13.182.55.11"},
+		{name: "X-Forwarded-For", header: http.Header{"Fastly-Client-Ip": {"10.0.0.3"}, "X-Forwarded-For": {"144.12.54.87"}}, expected: "144.12.54.87"},
+		{name: "X-Real-IP", header: http.Header{"Fastly-Client-Ip": {"10.0.0.3"}, "X-Real-Ip": {"144.12.54.87"}}, expected: "144.12.54.87"},
+		{name: "X-Client-Ip ignored", header: http.Header{"X-Client-Ip": {"144.12.54.87"}}, expected: "10.0.0.1"},
+	}
+
+	for _, v := range testData {
+		r := &http.Request{RemoteAddr: "10.0.0.1:80", Header: v.header}
+		if actual := e.FromRequest(r); actual != v.expected {
+			t.Errorf("%s: expected %s but get %s", v.name, v.expected, actual)
+		}
+	}
+
+	cdn := Options{Headers: CDNHeaderNames()}
+	for _, v := range testData {
+		r := &http.Request{RemoteAddr: "10.0.0.1:80", Header: v.header}
+		if actual := FromRequestWithOptions(r, cdn); actual != v.expected {
+			t.Errorf("%s with options: expected %s but get %s", v.name, v.expected, actual)
+		}
+	}
+
+	e = New(WithHeaderChain(CDNHeaders()), WithHeader(XClientIPHeader, HeaderSingle))
+	r := &http.Request{RemoteAddr: "10.0.0.1:80", Header: http.Header{"X-Client-Ip": {"144.12.54.87"}}}
+	if actual := e.FromRequest(r); actual != "144.12.54.87" {
+		t.Errorf("X-Client-Ip: expected %s but get %s", "144.12.54.87", actual)
+	}
+}
+
+func TestFromRequestWithOptions(t *testing.T) {
+	cloudflare := Options{Headers: []string{CFConnectingIPHeader, TrueClientIPHeader, "X-Forwarded-For"}}
+
+	testData := []struct {
+		name     string
+		opts     Options
+		header   http.Header
+		expected string
+	}{
+		{
+			name:     "CF-Connecting-IP first",
+			opts:     cloudflare,
+			header:   http.Header{"Cf-Connecting-Ip": {"119.14.55.11"}, "True-Client-Ip": {"13.182.55.11"}, "X-Forwarded-For": {"144.12.54.87"}},
+			expected: "119.14.55.11",
+		}, {
+			name:     "True-Client-IP",
+			opts:     cloudflare,
+			header:   http.Header{"True-Client-Ip": {"13.182.55.11"}, "X-Forwarded-For": {"144.12.54.87"}},
+			expected: "13.182.55.11",
+		}, {
+			name:     "X-Forwarded-For list",
+			opts:     cloudflare,
+			header:   http.Header{"X-Forwarded-For": {"10.0.0.2, 144.12.54.87"}, "X-Real-Ip": {"1.2.3.4"}},
+			expected: "144.12.54.87",
+		}, {
+			name:     "Default",
+			opts:     Options{},
+			header:   http.Header{"Cf-Connecting-Ip": {"119.14.55.11"}, "X-Forwarded-For": {"144.12.54.87"}},
+			expected: "144.12.54.87",
+		},
+	}
+
+	for _, v := range testData {
+		r := &http.Request{RemoteAddr: "10.0.0.1:80", Header: v.header}
+		if actual := FromRequestWithOptions(r, v.opts); actual != v.expected {
+			t.Errorf("%s: expected %s but get %s", v.name, v.expected, actual)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package realip
+
+import (
+	"net/http"
+	"strings"
+)
+
+var xForwardedHostHeader = http.CanonicalHeaderKey("X-Forwarded-Host")
+
+// HostFromRequest returns the host originally requested by the client, as
+// recorded by the closest proxy: the host parameter of the rightmost
+// Forwarded element, or else the rightmost X-Forwarded-Host entry, so a
+// client can't override it by sending its own. With trusted proxies, the
+// headers are only honored when the peer is one of them. It falls back to
+// the Host of the request.
+func (e *Extractor) HostFromRequest(r *http.Request) string {
+	if in := requestInput(r); !e.trustedSet || e.isTrusted(remoteIP(in)) {
+		if host := e.forwardedHost(in); host != "" {
+			return host
+		}
+	}
+
+	return r.Host
+}
+
+// forwardedHost returns the host of the rightmost Forwarded element, or else
+// the rightmost X-Forwarded-Host entry, the ones appended by the closest
+// proxy, or an empty string if neither is set.
+func (e *Extractor) forwardedHost(in input) string {
+	if lines := e.values(in, forwardedHeader); len(lines) > 0 {
+		elements := e.parseForwarded(lines[len(lines)-1])
+		if n := len(elements); n > 0 && elements[n-1].Host != "" {
+			return elements[n-1].Host
+		}
+	}
+
+	// X-Forwarded-Host is a list when several proxies appended to it
+	if lines := e.values(in, xForwardedHostHeader); len(lines) > 0 {
+		line := lines[len(lines)-1]
+		return strings.TrimSpace(line[strings.LastIndexByte(line, ',')+1:])
+	}
+
+	return ""
+}
+
+// HostFromRequest returns the host originally requested by the client, as
+// recorded by the closest proxy in the Forwarded or X-Forwarded-Host header,
+// falling back to the Host of the request.
+func HostFromRequest(r *http.Request) string {
+	return defaultExtractor.HostFromRequest(r)
+}
@@ -0,0 +1,76 @@
+package realip
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestHostFromRequest(t *testing.T) {
+	testData := []struct {
+		name     string
+		request  *http.Request
+		expected string
+	}{
+		{
+			name:     "No header",
+			request:  &http.Request{Host: "internal:8080", Header: http.Header{}},
+			expected: "internal:8080",
+		}, {
+			name: "Has Forwarded",
+			request: &http.Request{Host: "internal:8080", Header: http.Header{
+				"Forwarded": {`for=144.12.54.87;host="example.com";proto=https`},
+			}},
+			expected: "example.com",
+		}, {
+			name: "Has X-Forwarded-Host",
+			request: &http.Request{Host: "internal:8080", Header: http.Header{
+				"X-Forwarded-Host": {"example.com"},
+			}},
+			expected: "example.com",
+		}, {
+			name: "Has X-Forwarded-Host multiple hosts",
+			request: &http.Request{Host: "internal:8080", Header: http.Header{
+				"X-Forwarded-Host": {"evil.example, example.com"},
+			}},
+			expected: "example.com",
+		}, {
+			name: "Forwarded takes precedence",
+			request: &http.Request{Host: "internal:8080", Header: http.Header{
+				"Forwarded":        {"for=144.12.54.87, host=example.org"},
+				"X-Forwarded-Host": {"example.com"},
+			}},
+			expected: "example.org",
+		},
+	}
+
+	for _, v := range testData {
+		if actual := HostFromRequest(v.request); actual != v.expected {
+			t.Errorf("%s: expected %s but get %s", v.name, v.expected, actual)
+		}
+	}
+}
+
+func TestHostFromRequestTrusted(t *testing.T) {
+	e := NewSecure()
+
+	testData := []struct {
+		name       string
+		remoteAddr string
+		header     http.Header
+		expected   string
+	}{
+		{name: "Untrusted peer X-Forwarded-Host", remoteAddr: "144.12.54.87:443", header: http.Header{"X-Forwarded-Host": {"evil.example"}}, expected: "internal:8080"},
+		{name: "Untrusted peer Forwarded", remoteAddr: "144.12.54.87:443", header: http.Header{"Forwarded": {"for=1.2.3.4;host=evil.example"}}, expected: "internal:8080"},
+		{name: "Trusted peer", remoteAddr: "10.0.0.1:443", header: http.Header{"X-Forwarded-Host": {"example.com"}}, expected: "example.com"},
+		{name: "Trusted peer multiple hosts", remoteAddr: "10.0.0.1:443", header: http.Header{"X-Forwarded-Host": {"evil.example, real.example"}}, expected: "real.example"},
+		{name: "Trusted peer multiple lines", remoteAddr: "10.0.0.1:443", header: http.Header{"X-Forwarded-Host": {"evil.example", "real.example"}}, expected: "real.example"},
+		{name: "Trusted peer Forwarded", remoteAddr: "10.0.0.1:443", header: http.Header{"Forwarded": {"host=evil.example, for=1.2.3.4;host=real.example"}}, expected: "real.example"},
+	}
+
+	for _, v := range testData {
+		r := &http.Request{Host: "internal:8080", RemoteAddr: v.remoteAddr, Header: v.header}
+		if actual := e.HostFromRequest(r); actual != v.expected {
+			t.Errorf("%s: expected %s but get %s", v.name, v.expected, actual)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package realip
+
+import (
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+)
+
+// FromRequestHostPort returns client's real public IP address from http
+// request headers, like FromRequest, and the source port recorded with it,
+// which helps tell apart clients sharing a NAT address. The port is taken from
+// the forwarding header entry the address was found in, e.g.
+// for="[2001:db8::1]:4711" in Forwarded, or from RemoteAddr when the address
+// is the peer's. It is empty when none was recorded.
+func (e *Extractor) FromRequestHostPort(r *http.Request) (ip string, port string) {
+	resolved, _ := e.resolve(r)
+	resolved = e.accept(resolved)
+	addr, err := parseAddress(resolved)
+	if err != nil {
+		return e.finish(resolved), ""
+	}
+
+	return e.finish(resolved), e.portOf(requestInput(r), addr)
+}
+
+// portOf returns the port recorded with addr in the forwarding headers of the
+// header chain, or in RemoteAddr.
+func (e *Extractor) portOf(in input, addr netip.Addr) string {
+	if e.hasHeaders(in) {
+		for _, spec := range e.headerChain {
+			if port := e.headerPort(in, spec, addr); port != "" {
+				return port
+			}
+		}
+	}
+
+	if ip, port, ok := NormalizeRemoteAddr(in.remoteAddr); ok && port != 0 && sameAddress(ip, addr) {
+		return strconv.Itoa(port)
+	}
+
+	return ""
+}
+
+// headerPort returns the port of the first entry of the header described by
+// spec holding addr with a port.
+func (e *Extractor) headerPort(in input, spec HeaderSpec, addr netip.Addr) string {
+	var port string
+	match := func(entry string) bool {
+		if ip, p, ok := NormalizeRemoteAddr(entry); ok && p != 0 && sameAddress(ip, addr) {
+			port = strconv.Itoa(p)
+		}
+		return port != ""
+	}
+
+	for _, line := range e.values(in, spec.Name) {
+		switch spec.Kind {
+		case HeaderForwarded:
+			e.eachFor(line, func(node string) bool {
+				return match(unquote(node))
+			})
+		case HeaderList:
+			for _, entry := range strings.FieldsFunc(line, isListSeparator) {
+				if match(entry) {
+					break
+				}
+			}
+		default:
+			match(line)
+		}
+		if port != "" {
+			return port
+		}
+	}
+
+	return ""
+}
+
+// isListSeparator reports whether c separates the addresses of a list
+// header.
+func isListSeparator(c rune) bool {
+	return strings.ContainsRune(listSeparators, c)
+}
+
+// sameAddress reports whether ip is a valid address equal to addr.
+func sameAddress(ip string, addr netip.Addr) bool {
+	a, err := parseAddress(ip)
+	return err == nil && a == addr
+}
+
+// FromRequestHostPort returns client's real public IP address from http
+// request headers and the source port recorded with it, if any.
+func FromRequestHostPort(r *http.Request) (ip string, port string) {
+	return defaultExtractor.FromRequestHostPort(r)
+}
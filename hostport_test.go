@@ -0,0 +1,57 @@
+package realip
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestFromRequestHostPort(t *testing.T) {
+	testData := []struct {
+		name       string
+		remoteAddr string
+		header     http.Header
+		ip         string
+		port       string
+	}{
+		{name: "RemoteAddr", remoteAddr: "144.12.54.87:51234", header: http.Header{}, ip: "144.12.54.87", port: "51234"},
+		{name: "RemoteAddr IPv6", remoteAddr: "[2a00:1450::1]:443", header: http.Header{}, ip: "2a00:1450::1", port: "443"},
+		{name: "RemoteAddr without port", remoteAddr: "144.12.54.87", header: http.Header{}, ip: "144.12.54.87", port: ""},
+		{
+			name:       "Forwarded IPv6",
+			remoteAddr: "10.0.0.1:8080",
+			header:     http.Header{"Forwarded": {`for="[2a00:1450::1]:4711";proto=https`}},
+			ip:         "2a00:1450::1",
+			port:       "4711",
+		}, {
+			name:       "Forwarded IPv4",
+			remoteAddr: "10.0.0.1:8080",
+			header:     http.Header{"Forwarded": {`for=10.0.0.2, for="144.12.54.87:4711"`}},
+			ip:         "144.12.54.87",
+			port:       "4711",
+		}, {
+			name:       "X-Forwarded-For without port",
+			remoteAddr: "10.0.0.1:8080",
+			header:     http.Header{"X-Forwarded-For": {"144.12.54.87"}},
+			ip:         "144.12.54.87",
+			port:       "",
+		}, {
+			name:       "X-Real-IP",
+			remoteAddr: "10.0.0.1:8080",
+			header:     http.Header{"X-Real-Ip": {"144.12.54.87:1234"}},
+			ip:         "144.12.54.87",
+			port:       "1234",
+		},
+	}
+
+	for _, v := range testData {
+		r := &http.Request{RemoteAddr: v.remoteAddr, Header: v.header}
+		if ip, port := FromRequestHostPort(r); ip != v.ip || port != v.port {
+			t.Errorf("%s: expected (%s, %s) but get (%s, %s)", v.name, v.ip, v.port, ip, port)
+		}
+	}
+
+	r := &http.Request{RemoteAddr: "10.0.0.1:8080", Header: http.Header{"X-Forwarded-For": {"144.12.54.87:5678"}}}
+	if ip, port := New(WithStripAllPorts()).FromRequestHostPort(r); ip != "144.12.54.87" || port != "5678" {
+		t.Errorf("X-Forwarded-For with port: expected (%s, %s) but get (%s, %s)", "144.12.54.87", "5678", ip, port)
+	}
+}
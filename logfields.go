@@ -0,0 +1,25 @@
+package realip
+
+import "net/http"
+
+// LogFields returns the proxy derived fields commonly logged for a request,
+// ready to be merged into structured logs:
+//
+//   - remote_ip: the client's address, as returned by FromRequest
+//   - proto: the scheme the client used, as returned by SchemeFromRequest
+//   - host: the host the client requested, as returned by HostFromRequest
+//   - hops: the number of addresses in the forwarding chain
+func (e *Extractor) LogFields(r *http.Request) map[string]any {
+	return map[string]any{
+		"remote_ip": e.FromRequest(r),
+		"proto":     e.SchemeFromRequest(r),
+		"host":      e.HostFromRequest(r),
+		"hops":      len(e.ChainFromRequest(r)),
+	}
+}
+
+// LogFields returns the proxy derived fields commonly logged for a request,
+// ready to be merged into structured logs: remote_ip, proto, host and hops.
+func LogFields(r *http.Request) map[string]any {
+	return defaultExtractor.LogFields(r)
+}
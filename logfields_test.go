@@ -0,0 +1,60 @@
+package realip
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestLogFields(t *testing.T) {
+	r := &http.Request{
+		Host:       "backend.internal:8080",
+		RemoteAddr: "10.0.0.2:40000",
+		Header: http.Header{
+			"X-Forwarded-For":   {"144.12.54.87, 10.0.0.1"},
+			"X-Forwarded-Proto": {"https"},
+			"X-Forwarded-Host":  {"example.com"},
+		},
+	}
+	expected := map[string]any{
+		"remote_ip": "144.12.54.87",
+		"proto":     "https",
+		"host":      "example.com",
+		"hops":      2,
+	}
+
+	if actual := LogFields(r); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %v but get %v", expected, actual)
+	}
+
+	direct := &http.Request{Host: "example.com", RemoteAddr: "144.12.54.87:40000", Header: http.Header{}}
+	expected = map[string]any{
+		"remote_ip": "144.12.54.87",
+		"proto":     "http",
+		"host":      "example.com",
+		"hops":      0,
+	}
+
+	if actual := LogFields(direct); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("direct: expected %v but get %v", expected, actual)
+	}
+
+	spoofed := &http.Request{Host: "example.com", RemoteAddr: "144.12.54.87:40000", Header: http.Header{
+		"X-Forwarded-Proto": {"<script>"},
+		"X-Forwarded-Host":  {"evil.example"},
+	}}
+	expected = map[string]any{
+		"remote_ip": "144.12.54.87",
+		"proto":     "http",
+		"host":      "example.com",
+		"hops":      0,
+	}
+
+	if actual := NewSecure().LogFields(spoofed); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("spoofed: expected %v but get %v", expected, actual)
+	}
+	expected["host"] = "evil.example"
+	if actual := LogFields(spoofed); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("spoofed proto: expected %v but get %v", expected, actual)
+	}
+}
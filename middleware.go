@@ -0,0 +1,32 @@
+package realip
+
+import (
+	"context"
+	"net/http"
+)
+
+// contextKey is the key of the client address in a request context.
+type contextKey struct{}
+
+// Middleware returns a handler resolving the client address of every request
+// once, with the Extractor, and storing it in the request context for next,
+// where FromContext retrieves it.
+func (e *Extractor) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := context.WithValue(r.Context(), contextKey{}, e.FromRequest(r))
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// Middleware returns a handler resolving the client address of every request
+// once, like FromRequest, and storing it in the request context for next.
+func Middleware(next http.Handler) http.Handler {
+	return defaultExtractor.Middleware(next)
+}
+
+// FromContext returns the client address stored by Middleware. ok is false
+// if the middleware did not handle the request.
+func FromContext(ctx context.Context) (ip string, ok bool) {
+	ip, ok = ctx.Value(contextKey{}).(string)
+	return ip, ok
+}
@@ -0,0 +1,36 @@
+package realip
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMiddleware(t *testing.T) {
+	var ip string
+	var ok bool
+	handler := Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ip, ok = FromContext(r.Context())
+	}))
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.RemoteAddr = "10.0.0.1:8080"
+	r.Header.Set("X-Forwarded-For", "144.12.54.87")
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+	if ip != "144.12.54.87" || !ok {
+		t.Errorf("Forwarded: expected (%s, true) but get (%s, %t)", "144.12.54.87", ip, ok)
+	}
+
+	r = httptest.NewRequest(http.MethodGet, "/", nil)
+	r.RemoteAddr = "144.12.54.87:8080"
+	r.Header = nil
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+	if ip != "144.12.54.87" || !ok {
+		t.Errorf("Nil header: expected (%s, true) but get (%s, %t)", "144.12.54.87", ip, ok)
+	}
+
+	if ip, ok := FromContext(context.Background()); ip != "" || ok {
+		t.Errorf("No middleware: expected (\"\", false) but get (%s, %t)", ip, ok)
+	}
+}
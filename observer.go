@@ -0,0 +1,26 @@
+package realip
+
+// Observer is notified of the outcome of every resolution of an Extractor,
+// e.g. to export counters of the sources client addresses come from.
+type Observer interface {
+	// OnResolve is called once per resolved request with the source of the
+	// client address, or zero if none was found. fellBack reports that the
+	// request carried forwarding headers, but none of them provided the
+	// address, so the peer of the connection was used, or nothing, as when
+	// the chain is empty, all private or ignored because the peer is not a
+	// trusted proxy.
+	OnResolve(source Source, fellBack bool)
+}
+
+// observe notifies the observer that r was resolved to ip for reason. It is
+// only called when an observer is set, so resolutions without one don't pay
+// for deriving the source.
+func (e *Extractor) observe(in input, ip string, reason Reason) {
+	if ip == "" {
+		e.observer.OnResolve(0, e.hasHeaders(in))
+		return
+	}
+
+	source := e.source(in, ip, reason).Source
+	e.observer.OnResolve(source, source == SourceRemoteAddr && e.hasHeaders(in))
+}
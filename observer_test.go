@@ -0,0 +1,61 @@
+package realip
+
+import (
+	"net"
+	"net/http"
+	"testing"
+)
+
+type outcome struct {
+	source   Source
+	fellBack bool
+}
+
+type recordingObserver []outcome
+
+func (o *recordingObserver) OnResolve(source Source, fellBack bool) {
+	*o = append(*o, outcome{source, fellBack})
+}
+
+func TestWithObserver(t *testing.T) {
+	_, proxies, _ := net.ParseCIDR("10.0.0.0/8")
+
+	testData := []struct {
+		name       string
+		opts       []Option
+		remoteAddr string
+		header     http.Header
+		expected   outcome
+	}{
+		{name: "Direct connection", remoteAddr: "144.12.54.87:8080", header: http.Header{}, expected: outcome{SourceRemoteAddr, false}},
+		{name: "X-Forwarded-For", remoteAddr: "10.0.0.1:8080", header: http.Header{"X-Forwarded-For": {"144.12.54.87"}}, expected: outcome{SourceXForwardedFor, false}},
+		{name: "Forwarded", remoteAddr: "10.0.0.1:8080", header: http.Header{"Forwarded": {"for=144.12.54.87"}}, expected: outcome{SourceForwarded, false}},
+		{name: "X-Real-IP", remoteAddr: "10.0.0.1:8080", header: http.Header{"X-Real-Ip": {"144.12.54.87"}}, expected: outcome{SourceXRealIP, false}},
+		{name: "All private", remoteAddr: "10.0.0.1:8080", header: http.Header{"X-Forwarded-For": {"10.0.0.2"}}, expected: outcome{0, true}},
+		{name: "All private to remote address", opts: []Option{WhenAllPrivate(ReturnRemoteAddr)}, remoteAddr: "10.0.0.1:8080", header: http.Header{"X-Forwarded-For": {"10.0.0.2"}}, expected: outcome{SourceRemoteAddr, true}},
+		{name: "Untrusted peer", opts: []Option{WithTrustedProxies(proxies)}, remoteAddr: "119.14.55.11:8080", header: http.Header{"X-Forwarded-For": {"144.12.54.87"}}, expected: outcome{SourceRemoteAddr, true}},
+		{name: "Trusted proxy", opts: []Option{WithTrustedProxies(proxies)}, remoteAddr: "10.0.0.1:8080", header: http.Header{"X-Forwarded-For": {"1.2.3.4, 144.12.54.87"}}, expected: outcome{SourceXForwardedFor, false}},
+	}
+
+	for _, v := range testData {
+		var observer recordingObserver
+		e := New(append(v.opts, WithObserver(&observer))...)
+		e.FromRequest(&http.Request{RemoteAddr: v.remoteAddr, Header: v.header})
+
+		if len(observer) != 1 {
+			t.Errorf("%s: expected 1 call but get %d", v.name, len(observer))
+			continue
+		}
+		if observer[0] != v.expected {
+			t.Errorf("%s: expected %+v but get %+v", v.name, v.expected, observer[0])
+		}
+	}
+}
+
+func TestWithoutObserverAllocations(t *testing.T) {
+	r := &http.Request{RemoteAddr: "10.0.0.1:8080", Header: http.Header{"X-Forwarded-For": {"10.0.0.2, 144.12.54.87"}}}
+	e := New()
+	if allocs := testing.AllocsPerRun(100, func() { e.FromRequest(r) }); allocs != 0 {
+		t.Errorf("expected no allocations but get %.0f", allocs)
+	}
+}
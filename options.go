@@ -0,0 +1,643 @@
+package realip
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"time"
+)
+
+// Option configures an Extractor.
+type Option func(*Extractor)
+
+// WithDenyHeaders makes the Extractor ignore the named headers entirely, as
+// if the request never carried them. This is useful for headers that the
+// infrastructure in front of the application never sets, so their presence
+// can only mean the client is trying to spoof its address.
+func WithDenyHeaders(names ...string) Option {
+	return func(e *Extractor) {
+		if e.denied == nil {
+			e.denied = make(map[string]bool, len(names))
+		}
+		for _, name := range names {
+			e.denied[http.CanonicalHeaderKey(name)] = true
+		}
+	}
+}
+
+// WithoutXRealIP makes the Extractor ignore X-Real-IP, for setups where the
+// client can set it. When the forwarding headers hold no public address, the
+// result is then empty instead of the value of X-Real-IP.
+func WithoutXRealIP() Option {
+	return WithDenyHeaders(xRealIpHeader)
+}
+
+// WithNonRoutableRanges makes the Extractor also skip special purpose
+// addresses that are not private but can never be the source of a real
+// client request, such as multicast or the deprecated 6to4 relay anycast
+// block 192.88.99.0/24.
+func WithNonRoutableRanges() Option {
+	return func(e *Extractor) {
+		e.nonRoutable = true
+	}
+}
+
+// WithSpecialUseRanges makes the Extractor also skip the special-use
+// addresses reported by IsSpecialUse, such as multicast or TEST-NET
+// documentation addresses, like private ones.
+func WithSpecialUseRanges() Option {
+	return func(e *Extractor) {
+		e.specialUse = true
+	}
+}
+
+// WithTrustedPredicate makes the Extractor also skip, like private ones, the
+// addresses for which internal returns true, for definitions of internal
+// networks that can't be expressed as CIDR blocks, e.g. public ranges an IPAM
+// system knows are leased to internal tenants. It is called for every valid
+// address of the forwarding headers considered, and must be safe for
+// concurrent use.
+func WithTrustedPredicate(internal func(net.IP) bool) Option {
+	return func(e *Extractor) {
+		e.internalPredicate = internal
+	}
+}
+
+// WithRejectProxyOwnIP makes the Extractor skip any candidate equal to one of
+// the given proxy addresses. This guards against misconfigurations where the
+// proxy's own address, rather than the client's, would be returned.
+func WithRejectProxyOwnIP(proxyIPs []net.IP) Option {
+	return func(e *Extractor) {
+		for _, ip := range proxyIPs {
+			if addr, ok := netip.AddrFromSlice(ip); ok {
+				e.proxyIPs = append(e.proxyIPs, addr.Unmap())
+			}
+		}
+	}
+}
+
+// WithResultTransform makes the Extractor pass every resolved address through
+// fn before returning it. fn is never called when no address was resolved.
+func WithResultTransform(fn func(ip string) string) Option {
+	return func(e *Extractor) {
+		e.transform = fn
+	}
+}
+
+// WithStrictRFC7239 makes the Extractor reject for parameters of the
+// Forwarded header that do not follow RFC 7239, such as IPv6 addresses that
+// are not bracketed and quoted, instead of leniently accepting them.
+// FromRequestE reports them with ErrMalformedForwarded.
+func WithStrictRFC7239() Option {
+	return func(e *Extractor) {
+		e.strictRFC7239 = true
+	}
+}
+
+// WithPreferredClientRanges makes the Extractor return the first address of
+// the forwarding headers that lies within one of the given ranges, such as
+// the networks of known customers, before falling back to the usual
+// selection of the first public address.
+func WithPreferredClientRanges(ranges []*net.IPNet) Option {
+	return func(e *Extractor) {
+		e.preferred = append(e.preferred, prefixesFromIPNets(ranges)...)
+	}
+}
+
+// WithTrustedProxies makes the Extractor only consider the forwarding headers
+// when the request comes from one of the given proxies, and walk them from
+// the right, skipping trusted proxies, so a client can't spoof its address
+// by sending its own headers. An empty list trusts no proxy, so the headers
+// are always ignored and every request resolves to its peer.
+func WithTrustedProxies(proxies ...*net.IPNet) Option {
+	return func(e *Extractor) {
+		e.trusted = append(e.trusted, prefixesFromIPNets(proxies)...)
+		e.trustedSet = true
+	}
+}
+
+// WithTrustedProxyStrings parses the given CIDR blocks or bare addresses, as
+// read from a configuration file, e.g. 10.0.0.0/8 or 10.0.0.5, and returns an
+// Option trusting them as WithTrustedProxies does. A bare address is a single
+// host, a /32 or /128 network, and surrounding spaces are ignored. An error
+// wrapping ErrInvalidRange is returned for the first entry that does not
+// parse.
+func WithTrustedProxyStrings(proxies ...string) (Option, error) {
+	prefixes, err := parseHostRanges(proxies)
+	if err != nil {
+		return nil, err
+	}
+
+	return withTrustedPrefixes(prefixes), nil
+}
+
+// WithPrivateIPv4Only makes the Extractor only treat the IPv4 portion of the
+// private CIDR blocks as private. IPv6 loopback, unique local and link local
+// addresses are then accepted as client addresses.
+func WithPrivateIPv4Only() Option {
+	return func(e *Extractor) {
+		e.private = filterPrefixes(e.private, netip.Addr.Is4)
+	}
+}
+
+// WithPrivateIPv6Only makes the Extractor only treat the IPv6 portion of the
+// private CIDR blocks as private. IPv4 loopback, private and link local
+// addresses are then accepted as client addresses.
+func WithPrivateIPv6Only() Option {
+	return func(e *Extractor) {
+		e.private = filterPrefixes(e.private, netip.Addr.Is6)
+	}
+}
+
+// WithPrivateRanges replaces the CIDR blocks the Extractor treats as private
+// by a copy of ranges, e.g. DefaultPrivateRanges with an internal range that
+// uses public addresses added, or a default range removed.
+func WithPrivateRanges(ranges PrivateRanges) Option {
+	prefixes := prefixesFromIPNets(ranges)
+
+	return func(e *Extractor) {
+		// Capped, so the Extractors sharing the Option never share appends
+		e.private = prefixes[:len(prefixes):len(prefixes)]
+	}
+}
+
+// WithExtraPrivateRanges parses the given CIDR blocks, e.g. 198.51.100.0/24,
+// and returns an Option treating them as private in addition to the default
+// private blocks. An error wrapping ErrInvalidRange is returned for the first
+// block that does not parse.
+func WithExtraPrivateRanges(ranges ...string) (Option, error) {
+	prefixes, err := parseRanges(ranges)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(e *Extractor) {
+		// Copy, so the default private blocks are never modified
+		e.private = append(e.private[:len(e.private):len(e.private)], prefixes...)
+	}, nil
+}
+
+// filterPrefixes returns a new slice holding the prefixes whose address
+// satisfies keep.
+func filterPrefixes(prefixes []netip.Prefix, keep func(netip.Addr) bool) []netip.Prefix {
+	filtered := make([]netip.Prefix, 0, len(prefixes))
+	for _, prefix := range prefixes {
+		if keep(prefix.Addr()) {
+			filtered = append(filtered, prefix)
+		}
+	}
+
+	return filtered
+}
+
+// WithLenientParsing makes the Extractor tolerate common mistakes of
+// misconfigured clients and proxies in the forwarding headers, such as a
+// CIDR prefix length after an address, e.g. 203.0.113.5/32.
+func WithLenientParsing() Option {
+	return func(e *Extractor) {
+		e.lenientParsing = true
+	}
+}
+
+// WithTrustedHops makes the Extractor treat the n rightmost addresses of a
+// forwarding header as added by trusted proxies, and return the address
+// before them. This suits deployments with a fixed number of proxies, public
+// or private, in front of the application. When combined with
+// WithTrustedProxies, the peer must still be a trusted proxy.
+//
+// The peer of the connection is not one of the n addresses, unlike the n of
+// FromRequestTrustedCount, which counts it: WithTrustedHops(n) matches
+// FromRequestTrustedCount with n+1.
+func WithTrustedHops(n int) Option {
+	return func(e *Extractor) {
+		e.trustedHops = n
+	}
+}
+
+// WithPublicOnly makes the Extractor never return an address that is not
+// public. The fallbacks to X-Real-IP and to the remote address then resolve
+// to an empty string when they are private, and the limited broadcast
+// address 255.255.255.255 is always skipped.
+func WithPublicOnly() Option {
+	return func(e *Extractor) {
+		e.publicOnly = true
+	}
+}
+
+// WithConnectionCache makes the Extractor remember the last resolution made
+// on each connection, and reuse it without parsing the headers again when
+// the next request on that connection carries the same forwarding headers
+// and remote address. The cache must be installed on the server with
+// ConnContext; requests from connections without it are resolved as usual.
+func WithConnectionCache() Option {
+	return func(e *Extractor) {
+		e.connectionCache = true
+	}
+}
+
+// WithForceIPv6Form makes the Extractor return IPv4 addresses in their
+// IPv4-mapped IPv6 form, e.g. ::ffff:203.0.113.5, for uniform storage of all
+// addresses as IPv6.
+func WithForceIPv6Form() Option {
+	return func(e *Extractor) {
+		e.forceIPv6 = true
+	}
+}
+
+// WithAuditSink makes the Extractor report requests whose forwarding headers
+// were rejected to sink, for forwarding to logging or alerting systems. sink
+// is called synchronously and should not block. Requests served from the
+// connection cache don't emit events again.
+func WithAuditSink(sink func(AuditEvent)) Option {
+	return func(e *Extractor) {
+		e.auditSink = sink
+	}
+}
+
+// WithObserver makes the Extractor notify observer of the outcome of every
+// resolution, for metrics. observer is called synchronously, must be safe
+// for concurrent use and should not block. Requests served from the
+// connection cache are observed too.
+func WithObserver(observer Observer) Option {
+	return func(e *Extractor) {
+		e.observer = observer
+	}
+}
+
+// WithValidation makes the Extractor treat forwarding headers that can't
+// have been produced by a well behaved proxy as tampering, instead of making
+// the best of them. Such requests resolve to an empty string, and
+// FromRequestE reports them with ErrSpoofingDetected.
+//
+// Currently a single value header of the header chain, such as X-Real-IP or
+// the header set with WithFallbackHeader, holding a comma separated list is
+// rejected.
+func WithValidation() Option {
+	return func(e *Extractor) {
+		e.validation = true
+	}
+}
+
+// WithAggregateToPrefix makes the Extractor return the network address of
+// the prefix containing the client address instead of the address itself,
+// e.g. 203.0.113.0 for 203.0.113.5 with v4Bits 24, so log entries aggregate
+// per network. A length out of range for a family, such as -1, leaves the
+// addresses of that family untouched.
+func WithAggregateToPrefix(v4Bits, v6Bits int) Option {
+	return func(e *Extractor) {
+		e.aggregate = true
+		e.aggregateV4Bits = v4Bits
+		e.aggregateV6Bits = v6Bits
+	}
+}
+
+// WithForwardConfirmedRDNS sets the verifier ResolveVerified checks resolved
+// addresses with, typically a forward confirmed reverse DNS lookup used to
+// verify well known crawlers. The package performs no DNS lookups itself.
+func WithForwardConfirmedRDNS(verify func(ctx context.Context, ip string) (bool, error)) Option {
+	return func(e *Extractor) {
+		e.verifier = verify
+	}
+}
+
+// WithLenientForwardedSeparators makes the Extractor accept Forwarded headers
+// from non-compliant proxies that separate the parameters of an element with
+// commas instead of semicolons, e.g. for=192.0.2.1, proto=https. A parameter
+// repeated within an element then starts the next element.
+func WithLenientForwardedSeparators() Option {
+	return func(e *Extractor) {
+		e.lenientForwardedSeparators = true
+	}
+}
+
+// WithLenientForwardedFor makes the Extractor accept Forwarded headers from
+// non-compliant proxies that list several nodes in a single for parameter,
+// e.g. for=203.0.113.9, 198.51.100.1. A bare token following a for
+// parameter, without a parameter name, is then a node of the chain instead
+// of being dropped. RFC 7239 parsing remains the default.
+func WithLenientForwardedFor() Option {
+	return func(e *Extractor) {
+		e.lenientForwardedFor = true
+	}
+}
+
+// HostResolver looks up the addresses of a host. *net.Resolver implements it.
+type HostResolver interface {
+	LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error)
+}
+
+// WithTrustedProxyHostnames resolves the given proxy hostnames with resolver,
+// or net.DefaultResolver if it is nil, and returns an Option trusting every
+// address found as a single host network, like WithTrustedProxies.
+//
+// The hostnames are resolved once, when WithTrustedProxyHostnames is called.
+// Later DNS changes are not picked up unless it is called again and a new
+// Extractor is created. An error is returned if any name fails to resolve.
+func WithTrustedProxyHostnames(ctx context.Context, names []string, resolver HostResolver) (Option, error) {
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+
+	var prefixes []netip.Prefix
+	for _, name := range names {
+		addrs, err := resolver.LookupIPAddr(ctx, name)
+		if err != nil {
+			return nil, fmt.Errorf("resolving trusted proxy %s: %w", name, err)
+		}
+		for _, addr := range addrs {
+			if ip, ok := netip.AddrFromSlice(addr.IP); ok {
+				ip = ip.Unmap()
+				prefixes = append(prefixes, netip.PrefixFrom(ip, ip.BitLen()))
+			}
+		}
+	}
+
+	return func(e *Extractor) {
+		e.trusted = append(e.trusted, prefixes...)
+		e.trustedSet = true
+	}, nil
+}
+
+// WithMaxResolveTime bounds the time pluggable hooks, such as the verifier
+// of WithForwardConfirmedRDNS, may take. Hooks get a context with that
+// deadline, and once it expires the address resolved from the headers is
+// returned without the hook's result, so a slow external lookup can't stall
+// request handling. A hook that ignores its context keeps running in the
+// background until it returns.
+func WithMaxResolveTime(d time.Duration) Option {
+	return func(e *Extractor) {
+		e.maxResolveTime = d
+	}
+}
+
+// XFFOrder is the order in which proxies record addresses in the
+// X-Forwarded-For header.
+type XFFOrder int
+
+const (
+	// OldestFirst is the standard order: each proxy appends the address it
+	// received the request from, so the client is leftmost and the closest
+	// proxy rightmost.
+	OldestFirst XFFOrder = iota
+	// NewestFirst is used by proxies that prepend the address they received
+	// the request from, so the closest proxy is leftmost and the client
+	// rightmost.
+	NewestFirst
+)
+
+// WithXFFOrder sets the order of the X-Forwarded-For header, so the trust
+// walk starts at the end closest to the server. The default is OldestFirst.
+// With NewestFirst, the header is reversed before it is interpreted, and
+// ChainFromRequest returns its addresses from the client to the closest proxy.
+func WithXFFOrder(order XFFOrder) Option {
+	return func(e *Extractor) {
+		e.xffOrder = order
+	}
+}
+
+// Policy selects which public address of the list and Forwarded headers an
+// Extractor without trusted proxies returns.
+type Policy int
+
+const (
+	// PolicyLeftmost returns the leftmost public address, the one the client
+	// claims. It is only safe when every proxy in front of the application
+	// overwrites the forwarding headers sent by clients, as a client can
+	// otherwise put any address there. It is the default.
+	PolicyLeftmost Policy = iota
+	// PolicyRightmostNonPrivate walks the headers from the right and returns
+	// the first address that is not private. It is safe when every proxy in
+	// front of the application has a private address, as the rightmost
+	// public address is then the one the outermost proxy received the
+	// request from, and forged entries are left of it. Behind proxies with
+	// public addresses, such as a CDN, configure them with WithTrustedProxies
+	// instead, as their addresses would be returned.
+	PolicyRightmostNonPrivate
+)
+
+// WithPolicy sets the policy selecting the client address among the public
+// addresses of the forwarding headers. The headers of the header chain are
+// still tried in order of precedence. The policy is ignored with trusted
+// proxies or hops, whose headers are always walked from the right.
+func WithPolicy(policy Policy) Option {
+	return func(e *Extractor) {
+		e.policy = policy
+	}
+}
+
+// AllPrivateMode selects the result of an Extractor without trusted proxies
+// when the forwarding headers hold no public address and no single address
+// header, such as X-Real-IP, provides one either.
+type AllPrivateMode int
+
+const (
+	// ReturnEmpty returns an empty string. It is the default.
+	ReturnEmpty AllPrivateMode = iota
+	// ReturnLeftmost returns the leftmost valid address of the list and
+	// Forwarded headers, e.g. the calling service in a fully internal
+	// deployment.
+	ReturnLeftmost
+	// ReturnRemoteAddr returns the address of the peer of the connection.
+	ReturnRemoteAddr
+)
+
+// WhenAllPrivate sets the result of requests whose forwarding headers only
+// hold private addresses, for east-west traffic whose private addresses are
+// exactly what should be logged. It is ignored with trusted proxies or hops,
+// which return the rightmost untrusted address whether it is private or not.
+func WhenAllPrivate(mode AllPrivateMode) Option {
+	return func(e *Extractor) {
+		e.allPrivate = mode
+	}
+}
+
+// DefaultMaxChainLength is the maximum number of entries of a forwarding
+// header an Extractor considers, unless set with WithMaxChainLength.
+const DefaultMaxChainLength = 50
+
+// WithMaxChainLength sets the maximum number of entries of each list and
+// Forwarded header the Extractor considers, capping the work done for
+// headers with thousands of entries sent by an attacker. Entries past the
+// limit are ignored, so the next header of the header chain is used if none
+// of the first ones yields an address. Walks from the right, as done with
+// trusted proxies, consider the entries closest to the server. A limit of
+// zero or less removes the cap.
+func WithMaxChainLength(n int) Option {
+	return func(e *Extractor) {
+		e.maxChainLength = n
+	}
+}
+
+// WithCanonicalize makes ChainFromRequest and AppendChain return clean,
+// comparable chains for audit logs: every address is returned in canonical
+// form, e.g. 203.0.113.9 for ::ffff:203.0.113.9 and 2001:db8::1 for
+// 2001:0db8:0:0::1, without its zone, and repeated addresses are dropped,
+// keeping the first one seen.
+func WithCanonicalize() Option {
+	return func(e *Extractor) {
+		e.canonicalize = true
+	}
+}
+
+// WithStripAllPorts guarantees the Extractor never returns a port, so results
+// can be used as keys consistently. Port numbers and brackets are removed from
+// every address, including results of a result transform. The ports of the
+// forwarding headers, e.g. 203.0.113.5:8080 in X-Forwarded-For or
+// [2001:db8::1]:4711 in X-Real-IP, are always removed.
+func WithStripAllPorts() Option {
+	return func(e *Extractor) {
+		e.stripPorts = true
+	}
+}
+
+// WithHeaderChain replaces the forwarding headers the Extractor takes into
+// account, and their order of precedence, by the given chain, e.g. a vendor
+// header such as CF-Connecting-IP before X-Forwarded-For. Headers are tried in
+// order: list and Forwarded headers yield their first public address, or the
+// client address in trusted mode, and a single address header yields its
+// value as is. Headers missing from the chain are ignored.
+//
+// The default chain is every address of X-Forwarded-For, then of Forwarded,
+// then X-Real-IP.
+func WithHeaderChain(chain []HeaderSpec) Option {
+	specs := make([]HeaderSpec, len(chain))
+	for i, spec := range chain {
+		spec.Name = http.CanonicalHeaderKey(spec.Name)
+		specs[i] = spec
+	}
+
+	return func(e *Extractor) {
+		// Capped, so the Extractors sharing the Option never share appends
+		e.headerChain = specs[:len(specs):len(specs)]
+	}
+}
+
+// WithClientRanges makes the Extractor only return addresses within the given
+// client ranges, treating every other address as a proxy. It suits networks
+// with a well defined client address space, where listing the clients is
+// easier than listing the proxies.
+//
+// The peer is returned if it lies within the client ranges. Otherwise the
+// forwarding headers are walked from the closest proxy, following the
+// configured X-Forwarded-For order, and the first address within the client
+// ranges is returned. No address is returned if there is none.
+func WithClientRanges(ranges []*net.IPNet) Option {
+	return func(e *Extractor) {
+		e.clients = append(e.clients, prefixesFromIPNets(ranges)...)
+	}
+}
+
+// WithAllowPublicTrust makes Validate and NewStrict accept trusted proxy
+// ranges overlapping public address space, e.g. for a CDN whose proxies have
+// public addresses.
+func WithAllowPublicTrust() Option {
+	return func(e *Extractor) {
+		e.allowPublicTrust = true
+	}
+}
+
+// WithHeader adds the named header, parsed according to kind, to the
+// forwarding headers the Extractor takes into account, after the ones
+// already configured. It is meant for vendor and legacy headers, such as
+// XProxyUserIPHeader.
+func WithHeader(name string, kind HeaderKind) Option {
+	spec := HeaderSpec{Name: http.CanonicalHeaderKey(name), Kind: kind}
+
+	return func(e *Extractor) {
+		// Copy, so the default chain is never modified
+		e.headerChain = append(e.headerChain[:len(e.headerChain):len(e.headerChain)], spec)
+	}
+}
+
+// WithFallbackHeader replaces X-Real-IP, the single address header used when
+// the list and Forwarded headers yield no address, by the named header, e.g.
+// a custom header set by nginx. An empty name disables the fallback, so such
+// requests resolve to an empty string, and to ErrNoValidIP with FromRequestE
+// and FromRequestIP. It applies to the header chain configured by the options
+// before it. With WithValidation, the named header holding a list is rejected
+// as X-Real-IP would be.
+func WithFallbackHeader(name string) Option {
+	if name != "" {
+		name = http.CanonicalHeaderKey(name)
+	}
+
+	return func(e *Extractor) {
+		chain := make([]HeaderSpec, 0, len(e.headerChain)+1)
+		for _, spec := range e.headerChain {
+			if spec.Name != e.fallbackHeader {
+				chain = append(chain, spec)
+			}
+		}
+		if name != "" {
+			chain = append(chain, HeaderSpec{Name: name, Kind: HeaderSingle})
+		}
+		e.headerChain = chain
+		e.fallbackHeader = name
+	}
+}
+
+// WithHeaders replaces the forwarding headers the Extractor takes into
+// account by the named ones, in order of precedence, e.g.
+// CFConnectingIPHeader before X-Forwarded-For behind Cloudflare.
+// X-Forwarded-For is read as a list, Forwarded and X-Forwarded as RFC 7239
+// headers, the CDN headers of CDNHeaders as lists whose last address is used,
+// and any other header as holding a single address, which is used as is. Use
+// WithHeaderChain for other combinations.
+func WithHeaders(names ...string) Option {
+	chain := make([]HeaderSpec, len(names))
+	for i, name := range names {
+		chain[i] = headerSpec(name)
+	}
+
+	return WithHeaderChain(chain)
+}
+
+// WithTrustBoundary makes the Extractor treat the rightmost trusted proxy of a
+// forwarding header as the trust boundary, for chains where a misbehaving
+// intermediate appends addresses after a trusted proxy such as a CDN, e.g.
+// client, cdn, garbage. Addresses to the right of the boundary are ignored,
+// and the walk for the client resumes to its left, skipping trusted proxies.
+// Without a trusted address in the header, the header is walked as usual.
+//
+// The boundary is found by value, so a client can plant one by writing the
+// address of a trusted proxy into its own header, whenever its request
+// reaches a trusted proxy without crossing the real boundary. With a CDN at
+// 13.182.0.1 in front of a load balancer, a client connecting to the load
+// balancer directly with X-Forwarded-For: 6.6.6.6, 13.182.0.1 arrives as
+// 6.6.6.6, 13.182.0.1, 119.14.55.11. Its real address 119.14.55.11 is taken
+// for garbage to the right of the boundary, and it resolves to the forged
+// 6.6.6.6. Only use this option when every request goes through the
+// boundary, e.g. the load balancer only accepts connections from the CDN.
+func WithTrustBoundary() Option {
+	return func(e *Extractor) {
+		e.trustBoundary = true
+	}
+}
+
+// VerifyChainTerminus makes the Extractor check that the last hop of every
+// list and Forwarded header, the address closest to the server, was written
+// by the peer of the connection. Otherwise the headers are not trusted and
+// the request resolves to the peer's address, so a client cannot send a
+// forged chain straight to the server.
+//
+// With trusted proxies, a chain relayed by a trusted peer is always accepted,
+// as the peer wrote its last hop, e.g. the address of the client it served.
+// Without them, the check is strict: every proxy, up to the one connecting to
+// the server, must record its own address, as the last hop must be the peer.
+func VerifyChainTerminus() Option {
+	return func(e *Extractor) {
+		e.verifyTerminus = true
+	}
+}
+
+// WithResultIPOnly is a safety net for callers that must never receive a
+// port: FromRequestE returns ErrPortInResult, and FromRequest an empty
+// string, if the result carries a port, e.g. one appended by a result
+// transform. Use WithStripAllPorts to remove ports instead.
+func WithResultIPOnly() Option {
+	return func(e *Extractor) {
+		e.resultIPOnly = true
+	}
+}
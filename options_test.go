@@ -0,0 +1,816 @@
+package realip
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestWithDenyHeaders(t *testing.T) {
+	r := &http.Request{
+		RemoteAddr: "144.12.54.87:8080",
+		Header: http.Header{
+			"Forwarded": {"for=119.14.55.11"},
+		},
+	}
+
+	if actual := New().FromRequest(r); actual != "119.14.55.11" {
+		t.Errorf("without deny: expected %s but get %s", "119.14.55.11", actual)
+	}
+
+	if actual := New(WithDenyHeaders("forwarded")).FromRequest(r); actual != "144.12.54.87" {
+		t.Errorf("with deny: expected %s but get %s", "144.12.54.87", actual)
+	}
+}
+
+func TestWithoutXRealIP(t *testing.T) {
+	testData := []struct {
+		name      string
+		extractor *Extractor
+		header    http.Header
+		expected  string
+		err       error
+	}{
+		{name: "Fallback", extractor: New(), header: http.Header{"X-Forwarded-For": {"10.0.0.2"}, "X-Real-Ip": {"144.12.54.87"}}, expected: "144.12.54.87"},
+		{name: "Invalid fallback", extractor: New(), header: http.Header{"X-Forwarded-For": {"10.0.0.2"}, "X-Real-Ip": {"not-an-ip"}}, err: ErrNoValidIP},
+		{name: "Disabled fallback", extractor: New(WithoutXRealIP()), header: http.Header{"X-Forwarded-For": {"10.0.0.2"}, "X-Real-Ip": {"144.12.54.87"}}, err: ErrNoValidIP},
+		{name: "Public address", extractor: New(WithoutXRealIP()), header: http.Header{"X-Forwarded-For": {"10.0.0.2, 119.14.55.11"}, "X-Real-Ip": {"144.12.54.87"}}, expected: "119.14.55.11"},
+	}
+
+	for _, v := range testData {
+		r := &http.Request{RemoteAddr: "10.0.0.1:8080", Header: v.header}
+		if actual := v.extractor.FromRequest(r); actual != v.expected {
+			t.Errorf("%s: expected %s but get %s", v.name, v.expected, actual)
+		}
+		if actual, err := v.extractor.FromRequestE(r); actual != v.expected || !errors.Is(err, v.err) {
+			t.Errorf("%s: expected %s (%v) but get %s (%v)", v.name, v.expected, v.err, actual, err)
+		}
+	}
+}
+
+func TestWithNonRoutableRanges(t *testing.T) {
+	r := &http.Request{
+		Header: http.Header{
+			"X-Forwarded-For": {"192.88.99.1, 144.12.54.87"},
+		},
+	}
+
+	if actual := New().FromRequest(r); actual != "192.88.99.1" {
+		t.Errorf("default: expected %s but get %s", "192.88.99.1", actual)
+	}
+
+	if actual := New(WithNonRoutableRanges()).FromRequest(r); actual != "144.12.54.87" {
+		t.Errorf("non-routable: expected %s but get %s", "144.12.54.87", actual)
+	}
+}
+
+func TestWithSpecialUseRanges(t *testing.T) {
+	testData := map[string]string{
+		"224.0.0.1, 144.12.54.87":   "144.12.54.87",
+		"192.0.2.7, 144.12.54.87":   "144.12.54.87",
+		"240.0.0.1, 144.12.54.87":   "144.12.54.87",
+		"0.1.2.3, 144.12.54.87":     "144.12.54.87",
+		"ff02::1, 2a00:1450::1":     "2a00:1450::1",
+		"::ffff:224.0.0.1, 1.1.1.1": "1.1.1.1",
+		"203.0.113.9":               "",
+	}
+
+	for xff, expected := range testData {
+		r := &http.Request{RemoteAddr: "10.0.0.1:8080", Header: http.Header{"X-Forwarded-For": {xff}}}
+		if actual := New(WithSpecialUseRanges()).FromRequest(r); actual != expected {
+			t.Errorf("%s: expected %s but get %s", xff, expected, actual)
+		}
+	}
+
+	r := &http.Request{RemoteAddr: "10.0.0.1:8080", Header: http.Header{"X-Forwarded-For": {"192.0.2.7, 144.12.54.87"}}}
+	if actual := New().FromRequest(r); actual != "192.0.2.7" {
+		t.Errorf("default: expected %s but get %s", "192.0.2.7", actual)
+	}
+}
+
+func TestWithTrustedPredicate(t *testing.T) {
+	_, leased, _ := net.ParseCIDR("198.51.100.0/24")
+	var calls int
+	e := New(WithTrustedPredicate(func(ip net.IP) bool {
+		calls++
+		return leased.Contains(ip)
+	}))
+
+	testData := map[string]string{
+		"198.51.100.7, 144.12.54.87":           "144.12.54.87",
+		"10.0.0.2, 198.51.100.7, 144.12.54.87": "144.12.54.87",
+		"198.51.100.7, 198.51.100.8":           "",
+		"144.12.54.87, 198.51.100.7":           "144.12.54.87",
+	}
+
+	for xff, expected := range testData {
+		r := &http.Request{RemoteAddr: "10.0.0.1:8080", Header: http.Header{"X-Forwarded-For": {xff}}}
+		if actual := e.FromRequest(r); actual != expected {
+			t.Errorf("%s: expected %s but get %s", xff, expected, actual)
+		}
+	}
+
+	calls = 0
+	r := &http.Request{RemoteAddr: "10.0.0.1:8080", Header: http.Header{"X-Forwarded-For": {"198.51.100.7, 198.51.100.8, 144.12.54.87"}}}
+	e.FromRequest(r)
+	if calls != 3 {
+		t.Errorf("expected %d calls but get %d", 3, calls)
+	}
+}
+
+func TestWithRejectProxyOwnIP(t *testing.T) {
+	e := New(WithRejectProxyOwnIP([]net.IP{net.ParseIP("144.12.54.87")}))
+
+	testData := []struct {
+		name     string
+		request  *http.Request
+		expected string
+	}{
+		{
+			name:     "RemoteAddr is the proxy",
+			request:  &http.Request{RemoteAddr: "144.12.54.87:8080", Header: http.Header{}},
+			expected: "",
+		}, {
+			name: "X-Forwarded-For only contains the proxy",
+			request: &http.Request{Header: http.Header{
+				"X-Forwarded-For": {"144.12.54.87"},
+			}},
+			expected: "",
+		}, {
+			name: "X-Forwarded-For contains the proxy and the client",
+			request: &http.Request{Header: http.Header{
+				"X-Forwarded-For": {"144.12.54.87, 119.14.55.11"},
+			}},
+			expected: "119.14.55.11",
+		},
+	}
+
+	for _, v := range testData {
+		if actual := e.FromRequest(v.request); actual != v.expected {
+			t.Errorf("%s: expected %s but get %s", v.name, v.expected, actual)
+		}
+	}
+}
+
+func TestWithResultTransform(t *testing.T) {
+	calls := 0
+	e := New(WithResultTransform(func(ip string) string {
+		calls++
+		return strings.ToUpper(ip)
+	}))
+
+	r := &http.Request{RemoteAddr: "[2001:db8::abcd]:443", Header: http.Header{}}
+	if actual := e.FromRequest(r); actual != "2001:DB8::ABCD" {
+		t.Errorf("expected %s but get %s", "2001:DB8::ABCD", actual)
+	}
+
+	r = &http.Request{Header: http.Header{"X-Forwarded-For": {"10.0.0.1"}}}
+	if actual := e.FromRequest(r); actual != "" {
+		t.Errorf("expected empty result but get %s", actual)
+	}
+
+	if calls != 1 {
+		t.Errorf("expected transform to be called once but get %d", calls)
+	}
+}
+
+func TestWithStrictRFC7239(t *testing.T) {
+	lenient := New()
+	strict := New(WithStrictRFC7239())
+
+	r := &http.Request{Header: http.Header{"Forwarded": {"for=2a00:1450::1"}}}
+	if actual, err := lenient.FromRequestE(r); actual != "2a00:1450::1" || err != nil {
+		t.Errorf("lenient: expected %s but get %s (%v)", "2a00:1450::1", actual, err)
+	}
+	if actual, err := strict.FromRequestE(r); !errors.Is(err, ErrMalformedForwarded) {
+		t.Errorf("strict: expected %v but get %s (%v)", ErrMalformedForwarded, actual, err)
+	}
+	if actual := strict.FromRequest(r); actual != "" {
+		t.Errorf("strict: expected empty result but get %s", actual)
+	}
+
+	r = &http.Request{Header: http.Header{"Forwarded": {`for="[2a00:1450::1]"`}}}
+	if actual, err := strict.FromRequestE(r); actual != "2a00:1450::1" || err != nil {
+		t.Errorf("strict bracketed: expected %s but get %s (%v)", "2a00:1450::1", actual, err)
+	}
+}
+
+func TestWithPreferredClientRanges(t *testing.T) {
+	_, customer, _ := net.ParseCIDR("119.14.0.0/16")
+	r := &http.Request{
+		Header: http.Header{
+			"X-Forwarded-For": {"144.12.54.87, 119.14.55.11, 10.0.0.1"},
+		},
+	}
+
+	if actual := New().FromRequest(r); actual != "144.12.54.87" {
+		t.Errorf("default: expected %s but get %s", "144.12.54.87", actual)
+	}
+
+	e := New(WithPreferredClientRanges([]*net.IPNet{customer}))
+	if actual := e.FromRequest(r); actual != "119.14.55.11" {
+		t.Errorf("preferred: expected %s but get %s", "119.14.55.11", actual)
+	}
+
+	r.Header.Set("X-Forwarded-For", "144.12.54.87, 10.0.0.1")
+	if actual := e.FromRequest(r); actual != "144.12.54.87" {
+		t.Errorf("preferred fallback: expected %s but get %s", "144.12.54.87", actual)
+	}
+}
+
+func TestWithPrivateIPv4OrIPv6Only(t *testing.T) {
+	testData := []struct {
+		name      string
+		extractor *Extractor
+		expected  string
+	}{
+		{name: "Default", extractor: New(), expected: ""},
+		{name: "IPv4 only", extractor: New(WithPrivateIPv4Only()), expected: "fc00::1"},
+		{name: "IPv6 only", extractor: New(WithPrivateIPv6Only()), expected: "10.0.0.1"},
+	}
+
+	r := &http.Request{Header: http.Header{"X-Forwarded-For": {"10.0.0.1, fc00::1"}}}
+	for _, v := range testData {
+		if actual := v.extractor.FromRequest(r); actual != v.expected {
+			t.Errorf("%s: expected %s but get %s", v.name, v.expected, actual)
+		}
+	}
+}
+
+func TestWithExtraPrivateRanges(t *testing.T) {
+	opt, err := WithExtraPrivateRanges("13.182.0.0/16")
+	if err != nil {
+		t.Fatalf("expected no error but get %v", err)
+	}
+	e := New(opt)
+
+	r := &http.Request{
+		RemoteAddr: "10.0.0.1:8080",
+		Header:     http.Header{"X-Forwarded-For": {"13.182.0.1, 144.12.54.87"}},
+	}
+	if actual := e.FromRequest(r); actual != "144.12.54.87" {
+		t.Errorf("Extra private range: expected %s but get %s", "144.12.54.87", actual)
+	}
+	if actual := FromRequest(r); actual != "13.182.0.1" {
+		t.Errorf("Default ranges modified: expected %s but get %s", "13.182.0.1", actual)
+	}
+
+	for _, invalid := range []string{"13.182.0.0/33", "not-a-range", "13.182.0.1"} {
+		if opt, err := WithExtraPrivateRanges("10.0.0.0/8", invalid); opt != nil || !errors.Is(err, ErrInvalidRange) {
+			t.Errorf("%s: expected %v but get %v", invalid, ErrInvalidRange, err)
+		}
+	}
+}
+
+func TestWithTrustedProxyStrings(t *testing.T) {
+	opt, err := WithTrustedProxyStrings("10.0.0.0/8", " 172.16.0.5", "fd00::/8 ", "2a00:1450::1", "::ffff:192.168.0.0/112")
+	if err != nil {
+		t.Fatalf("expected no error but get %v", err)
+	}
+	e := New(opt)
+
+	testData := []struct {
+		name       string
+		remoteAddr string
+		header     string
+		expected   string
+	}{
+		{name: "CIDR block", remoteAddr: "10.1.2.3:8080", header: "144.12.54.87, 10.0.0.1", expected: "144.12.54.87"},
+		{name: "Bare IPv4", remoteAddr: "172.16.0.5:8080", header: "144.12.54.87, 172.16.0.5", expected: "144.12.54.87"},
+		{name: "Bare IPv4 neighbour", remoteAddr: "172.16.0.6:8080", header: "144.12.54.87", expected: "172.16.0.6"},
+		{name: "Bare IPv6", remoteAddr: "[2a00:1450::1]:443", header: "144.12.54.87, fd00::1", expected: "144.12.54.87"},
+		{name: "Bare IPv6 neighbour", remoteAddr: "[2a00:1450::2]:443", header: "144.12.54.87", expected: "2a00:1450::2"},
+		{name: "IPv4-mapped block", remoteAddr: "192.168.1.2:8080", header: "144.12.54.87", expected: "144.12.54.87"},
+		{name: "IPv4-mapped block mapped peer", remoteAddr: "[::ffff:192.168.1.2]:8080", header: "144.12.54.87", expected: "144.12.54.87"},
+		{name: "IPv4-mapped block neighbour", remoteAddr: "192.169.0.1:8080", header: "144.12.54.87", expected: "192.169.0.1"},
+	}
+
+	for _, v := range testData {
+		r := &http.Request{RemoteAddr: v.remoteAddr, Header: http.Header{"X-Forwarded-For": {v.header}}}
+		if actual := e.FromRequest(r); actual != v.expected {
+			t.Errorf("%s: expected %s but get %s", v.name, v.expected, actual)
+		}
+	}
+
+	for _, invalid := range []string{"10.0.0.0/33", "not-a-range", "10.0.0.256", "", "::ffff:0:0/95"} {
+		opt, err := WithTrustedProxyStrings("10.0.0.0/8", invalid)
+		if opt != nil || !errors.Is(err, ErrInvalidRange) {
+			t.Errorf("%q: expected %v but get %v", invalid, ErrInvalidRange, err)
+		}
+		if err != nil && !strings.Contains(err.Error(), fmt.Sprintf("%q", invalid)) {
+			t.Errorf("%q: expected the error to name the entry but get %v", invalid, err)
+		}
+	}
+}
+
+func TestWithPrivateRanges(t *testing.T) {
+	ranges := DefaultPrivateRanges()
+	_, internal, _ := net.ParseCIDR("13.182.0.0/16")
+	ranges = append(ranges[1:], internal) // drop 127.0.0.0/8
+	e := New(WithPrivateRanges(ranges))
+
+	// Modifying the set afterwards does not affect the Extractor
+	ranges[0] = internal
+
+	r := &http.Request{
+		RemoteAddr: "10.0.0.1:8080",
+		Header:     http.Header{"X-Forwarded-For": {"13.182.0.1, 10.0.0.2, 144.12.54.87"}},
+	}
+	if actual := e.FromRequest(r); actual != "144.12.54.87" {
+		t.Errorf("Added range: expected %s but get %s", "144.12.54.87", actual)
+	}
+
+	r.Header.Set("X-Forwarded-For", "127.0.0.1")
+	if actual := e.FromRequest(r); actual != "127.0.0.1" {
+		t.Errorf("Removed range: expected %s but get %s", "127.0.0.1", actual)
+	}
+
+	if defaults := DefaultPrivateRanges(); !defaults.Contains(net.ParseIP("127.0.0.1")) || defaults.Contains(net.ParseIP("13.182.0.1")) {
+		t.Errorf("DefaultPrivateRanges modified: %v", defaults)
+	}
+	if !DefaultPrivateRanges().Contains(net.ParseIP("::ffff:10.0.0.1")) {
+		t.Errorf("Contains: expected IPv4-mapped addresses to match IPv4 ranges")
+	}
+}
+
+func TestExtractorPrivateRanges(t *testing.T) {
+	extra, _ := WithExtraPrivateRanges("198.51.100.0/24")
+	testData := []struct {
+		name      string
+		extractor *Extractor
+		expected  []string
+	}{
+		{name: "Default", extractor: New(), expected: rangeStrings(DefaultPrivateRanges())},
+		{name: "Extra", extractor: New(extra), expected: append(rangeStrings(DefaultPrivateRanges()), "198.51.100.0/24")},
+		{name: "IPv4 only", extractor: New(WithPrivateIPv4Only()), expected: []string{"127.0.0.0/8", "10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16", "169.254.0.0/16", "100.64.0.0/10", "198.18.0.0/15"}},
+	}
+
+	for _, v := range testData {
+		if actual := rangeStrings(v.extractor.PrivateRanges()); strings.Join(actual, " ") != strings.Join(v.expected, " ") {
+			t.Errorf("%s: expected %v but get %v", v.name, v.expected, actual)
+		}
+	}
+
+	// Modifying the copy does not affect the Extractor
+	e := New()
+	ranges := e.PrivateRanges()
+	ranges[1].IP[0] = 11
+	ranges[2] = nil
+	if r := (&http.Request{RemoteAddr: "10.0.0.1:8080", Header: http.Header{"X-Forwarded-For": {"10.0.0.2, 172.16.0.1"}}}); e.FromRequest(r) != "" {
+		t.Errorf("PrivateRanges: expected a copy but the Extractor was modified")
+	}
+}
+
+// rangeStrings returns the CIDR notation of ranges.
+func rangeStrings(ranges PrivateRanges) []string {
+	s := make([]string, len(ranges))
+	for i, n := range ranges {
+		s[i] = n.String()
+	}
+
+	return s
+}
+
+func TestParseCidrBlocksPanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("expected a panic for an invalid hardcoded block")
+		}
+	}()
+
+	parseCidrBlocks([]string{"10.0.0.0/8", "10.0.0.0/99"})
+}
+
+func TestWithLenientParsing(t *testing.T) {
+	r := &http.Request{Header: http.Header{"X-Forwarded-For": {"203.0.113.5/32"}}}
+
+	if actual := New().FromRequest(r); actual != "" {
+		t.Errorf("default: expected empty result but get %s", actual)
+	}
+
+	if actual := New(WithLenientParsing()).FromRequest(r); actual != "203.0.113.5" {
+		t.Errorf("lenient: expected %s but get %s", "203.0.113.5", actual)
+	}
+}
+
+func TestBroadcastAddresses(t *testing.T) {
+	testData := []struct {
+		name      string
+		extractor *Extractor
+		xff       string
+		expected  string
+	}{
+		{name: "Default limited broadcast", extractor: New(), xff: "255.255.255.255, 144.12.54.87", expected: "255.255.255.255"},
+		{name: "Non-routable limited broadcast", extractor: New(WithNonRoutableRanges()), xff: "255.255.255.255, 144.12.54.87", expected: "144.12.54.87"},
+		{name: "Public only limited broadcast", extractor: New(WithPublicOnly()), xff: "255.255.255.255, 144.12.54.87", expected: "144.12.54.87"},
+		{name: "Non-routable this host", extractor: New(WithNonRoutableRanges()), xff: "0.1.2.3, 144.12.54.87", expected: "144.12.54.87"},
+
+		// A directed broadcast address can't be told apart from a host
+		// address without knowing the network it belongs to
+		{name: "Non-routable directed broadcast", extractor: New(WithNonRoutableRanges()), xff: "144.12.54.255", expected: "144.12.54.255"},
+		{name: "Public only directed broadcast", extractor: New(WithPublicOnly()), xff: "144.12.54.255", expected: "144.12.54.255"},
+	}
+
+	for _, v := range testData {
+		r := &http.Request{Header: http.Header{"X-Forwarded-For": {v.xff}}}
+		if actual := v.extractor.FromRequest(r); actual != v.expected {
+			t.Errorf("%s: expected %s but get %s", v.name, v.expected, actual)
+		}
+	}
+}
+
+func TestWithPublicOnly(t *testing.T) {
+	e := New(WithPublicOnly())
+
+	r := &http.Request{RemoteAddr: "10.0.0.1:8080", Header: http.Header{}}
+	if actual := e.FromRequest(r); actual != "" {
+		t.Errorf("private remote address: expected empty result but get %s", actual)
+	}
+
+	r = &http.Request{Header: http.Header{"X-Real-Ip": {"192.168.0.1"}}}
+	if actual := e.FromRequest(r); actual != "" {
+		t.Errorf("private X-Real-IP: expected empty result but get %s", actual)
+	}
+}
+
+func TestWithForceIPv6Form(t *testing.T) {
+	e := New(WithForceIPv6Form())
+
+	testData := map[string]string{
+		"203.0.113.5:8080":     "::ffff:203.0.113.5",
+		"[::ffff:203.0.113.5]": "::ffff:203.0.113.5",
+		"[2001:db8::1]:443":    "2001:db8::1",
+	}
+
+	for remoteAddr, expected := range testData {
+		r := &http.Request{RemoteAddr: remoteAddr, Header: http.Header{}}
+		if actual := e.FromRequest(r); actual != expected {
+			t.Errorf("%s: expected %s but get %s", remoteAddr, expected, actual)
+		}
+		if actual, err := e.FromRequestE(r); actual != expected || err != nil {
+			t.Errorf("%s: expected %s but get %s (%v)", remoteAddr, expected, actual, err)
+		}
+	}
+}
+
+func TestWithAggregateToPrefix(t *testing.T) {
+	testData := []struct {
+		name       string
+		extractor  *Extractor
+		remoteAddr string
+		expected   string
+	}{
+		{name: "IPv4 /24", extractor: New(WithAggregateToPrefix(24, 48)), remoteAddr: "203.0.113.5:80", expected: "203.0.113.0"},
+		{name: "IPv4 /16", extractor: New(WithAggregateToPrefix(16, 48)), remoteAddr: "203.0.113.5:80", expected: "203.0.0.0"},
+		{name: "IPv4-mapped /24", extractor: New(WithAggregateToPrefix(24, 48)), remoteAddr: "[::ffff:203.0.113.5]:80", expected: "203.0.113.0"},
+		{name: "IPv6 /48", extractor: New(WithAggregateToPrefix(24, 48)), remoteAddr: "[2001:db8:1234:5678::1]:443", expected: "2001:db8:1234::"},
+		{name: "IPv6 /64", extractor: New(WithAggregateToPrefix(24, 64)), remoteAddr: "[2001:db8:1234:5678::1]:443", expected: "2001:db8:1234:5678::"},
+		{name: "IPv6 untouched", extractor: New(WithAggregateToPrefix(24, -1)), remoteAddr: "[2001:db8:1234:5678::1]:443", expected: "2001:db8:1234:5678::1"},
+	}
+
+	for _, v := range testData {
+		r := &http.Request{RemoteAddr: v.remoteAddr, Header: http.Header{}}
+		if actual := v.extractor.FromRequest(r); actual != v.expected {
+			t.Errorf("%s: expected %s but get %s", v.name, v.expected, actual)
+		}
+	}
+}
+
+func TestWithStripAllPorts(t *testing.T) {
+	e := New(WithStripAllPorts())
+
+	testData := []struct {
+		name     string
+		request  *http.Request
+		expected string
+	}{
+		{
+			name:     "RemoteAddr",
+			request:  &http.Request{RemoteAddr: "1.2.3.4:5678", Header: http.Header{}},
+			expected: "1.2.3.4",
+		}, {
+			name: "X-Forwarded-For IPv4",
+			request: &http.Request{RemoteAddr: "10.0.0.1:80", Header: http.Header{
+				"X-Forwarded-For": {"144.12.54.87:5678, 10.0.0.2"},
+			}},
+			expected: "144.12.54.87",
+		}, {
+			name: "X-Forwarded-For IPv6",
+			request: &http.Request{RemoteAddr: "10.0.0.1:80", Header: http.Header{
+				"X-Forwarded-For": {"[2a00:1450::1]:443"},
+			}},
+			expected: "2a00:1450::1",
+		}, {
+			name: "Forwarded IPv6",
+			request: &http.Request{RemoteAddr: "10.0.0.1:80", Header: http.Header{
+				"Forwarded": {`for="[2a00:1450::1]:4711"`},
+			}},
+			expected: "2a00:1450::1",
+		}, {
+			name: "Forwarded IPv4",
+			request: &http.Request{RemoteAddr: "10.0.0.1:80", Header: http.Header{
+				"Forwarded": {`for="144.12.54.87:4711"`},
+			}},
+			expected: "144.12.54.87",
+		}, {
+			name: "X-Real-IP IPv6",
+			request: &http.Request{RemoteAddr: "10.0.0.1:80", Header: http.Header{
+				"X-Real-Ip": {"[2a00:1450::1]:443"},
+			}},
+			expected: "2a00:1450::1",
+		},
+	}
+
+	for _, v := range testData {
+		if actual := e.FromRequest(v.request); actual != v.expected {
+			t.Errorf("%s: expected %s but get %s", v.name, v.expected, actual)
+		}
+	}
+
+	r := &http.Request{RemoteAddr: "10.0.0.1:80", Header: http.Header{"X-Real-Ip": {"[2a00:1450::1]:443"}}}
+	if actual := FromRequest(r); actual != "2a00:1450::1" {
+		t.Errorf("Default: expected the X-Real-IP port to be removed but get %s", actual)
+	}
+}
+
+func TestWithResultIPOnly(t *testing.T) {
+	testData := []struct {
+		name      string
+		extractor *Extractor
+		header    http.Header
+		expected  string
+		err       error
+	}{
+		{
+			name:      "IPv6 X-Real-IP with port",
+			extractor: New(WithResultIPOnly()),
+			header:    http.Header{"X-Real-Ip": {"[2a00:1450::1]:443"}},
+			expected:  "2a00:1450::1",
+		}, {
+			name:      "Transform adding a port",
+			extractor: New(WithResultIPOnly(), WithResultTransform(func(ip string) string { return ip + ":80" })),
+			header:    http.Header{"X-Forwarded-For": {"144.12.54.87"}},
+			err:       ErrPortInResult,
+		}, {
+			name:      "Bare address",
+			extractor: New(WithResultIPOnly()),
+			header:    http.Header{"X-Real-Ip": {"144.12.54.87:443"}},
+			expected:  "144.12.54.87",
+		}, {
+			name:      "Garbage",
+			extractor: New(WithResultIPOnly()),
+			header:    http.Header{"X-Real-Ip": {"garbage"}},
+			err:       ErrNoValidIP,
+		}, {
+			name:      "Stripped ports",
+			extractor: New(WithResultIPOnly(), WithStripAllPorts()),
+			header:    http.Header{"X-Real-Ip": {"[2a00:1450::1]:443"}},
+			expected:  "2a00:1450::1",
+		},
+	}
+
+	for _, v := range testData {
+		r := &http.Request{RemoteAddr: "10.0.0.1:8080", Header: v.header}
+		if actual, err := v.extractor.FromRequestE(r); actual != v.expected || !errors.Is(err, v.err) {
+			t.Errorf("%s: expected %s (%v) but get %s (%v)", v.name, v.expected, v.err, actual, err)
+		}
+		if v.err == ErrPortInResult {
+			if actual := v.extractor.FromRequest(r); actual != "" {
+				t.Errorf("%s: FromRequest expected empty result but get %s", v.name, actual)
+			}
+		}
+	}
+}
+
+func TestWithPolicy(t *testing.T) {
+	_, trusted, _ := net.ParseCIDR("10.0.0.0/8")
+
+	testData := []struct {
+		name      string
+		extractor *Extractor
+		request   *http.Request
+		expected  string
+		reason    Reason
+	}{
+		{
+			name:      "Leftmost",
+			extractor: New(WithPolicy(PolicyLeftmost)),
+			request: &http.Request{RemoteAddr: "10.0.0.1:8080", Header: http.Header{
+				"X-Forwarded-For": {"1.2.3.4, 144.12.54.87, 10.0.0.2"},
+			}},
+			expected: "1.2.3.4",
+			reason:   ReasonFirstPublicXFF,
+		}, {
+			name:      "Rightmost non private",
+			extractor: New(WithPolicy(PolicyRightmostNonPrivate)),
+			request: &http.Request{RemoteAddr: "10.0.0.1:8080", Header: http.Header{
+				"X-Forwarded-For": {"1.2.3.4, 144.12.54.87", "10.0.0.2"},
+			}},
+			expected: "144.12.54.87",
+			reason:   ReasonRightmostNonPrivate,
+		}, {
+			name:      "Rightmost non private Forwarded",
+			extractor: New(WithPolicy(PolicyRightmostNonPrivate)),
+			request: &http.Request{RemoteAddr: "10.0.0.1:8080", Header: http.Header{
+				"Forwarded": {"for=1.2.3.4, for=144.12.54.87, for=10.0.0.2"},
+			}},
+			expected: "144.12.54.87",
+			reason:   ReasonRightmostNonPrivate,
+		}, {
+			name:      "Rightmost non private X-Real-IP fallback",
+			extractor: New(WithPolicy(PolicyRightmostNonPrivate)),
+			request: &http.Request{RemoteAddr: "10.0.0.1:8080", Header: http.Header{
+				"X-Forwarded-For": {"10.0.0.3, 10.0.0.2"},
+				"X-Real-Ip":       {"144.12.54.87"},
+			}},
+			expected: "144.12.54.87",
+			reason:   ReasonXRealIPFallback,
+		}, {
+			name:      "Ignored with trusted proxies",
+			extractor: New(WithPolicy(PolicyRightmostNonPrivate), WithTrustedProxies(trusted)),
+			request: &http.Request{RemoteAddr: "10.0.0.1:8080", Header: http.Header{
+				"X-Forwarded-For": {"1.2.3.4, 144.12.54.87, 192.168.0.1, 10.0.0.2"},
+			}},
+			expected: "192.168.0.1",
+			reason:   ReasonRightmostUntrusted,
+		},
+	}
+
+	for _, v := range testData {
+		ip, reason := v.extractor.ResolveWithReason(v.request)
+		if ip != v.expected {
+			t.Errorf("%s: expected %s but get %s", v.name, v.expected, ip)
+		}
+		if reason != v.reason {
+			t.Errorf("%s: expected %s but get %s", v.name, v.reason, reason)
+		}
+	}
+}
+
+func TestWhenAllPrivate(t *testing.T) {
+	allPrivate := &http.Request{RemoteAddr: "10.0.0.1:8080", Header: http.Header{
+		"X-Forwarded-For": {"unknown, 192.168.0.7, 10.0.0.3"},
+	}}
+	public := &http.Request{RemoteAddr: "10.0.0.1:8080", Header: http.Header{
+		"X-Forwarded-For": {"192.168.0.7, 144.12.54.87"},
+	}}
+	xRealIP := &http.Request{RemoteAddr: "10.0.0.1:8080", Header: http.Header{
+		"X-Forwarded-For": {"192.168.0.7"},
+		"X-Real-Ip":       {"10.0.0.9"},
+	}}
+
+	testData := []struct {
+		name      string
+		extractor *Extractor
+		request   *http.Request
+		expected  string
+	}{
+		{name: "Default", extractor: New(), request: allPrivate, expected: ""},
+		{name: "Empty", extractor: New(WhenAllPrivate(ReturnEmpty)), request: allPrivate, expected: ""},
+		{name: "Leftmost", extractor: New(WhenAllPrivate(ReturnLeftmost)), request: allPrivate, expected: "192.168.0.7"},
+		{name: "Remote address", extractor: New(WhenAllPrivate(ReturnRemoteAddr)), request: allPrivate, expected: "10.0.0.1"},
+		{name: "Leftmost with public address", extractor: New(WhenAllPrivate(ReturnLeftmost)), request: public, expected: "144.12.54.87"},
+		{name: "Remote address with public address", extractor: New(WhenAllPrivate(ReturnRemoteAddr)), request: public, expected: "144.12.54.87"},
+		{name: "Leftmost with X-Real-IP", extractor: New(WhenAllPrivate(ReturnLeftmost)), request: xRealIP, expected: "10.0.0.9"},
+		{name: "Remote address with X-Real-IP", extractor: New(WhenAllPrivate(ReturnRemoteAddr)), request: xRealIP, expected: "10.0.0.9"},
+	}
+
+	for _, v := range testData {
+		if actual := v.extractor.FromRequest(v.request); actual != v.expected {
+			t.Errorf("%s: expected %s but get %s", v.name, v.expected, actual)
+		}
+	}
+
+	if _, reason := New(WhenAllPrivate(ReturnLeftmost)).ResolveWithReason(allPrivate); reason != ReasonLeftmostPrivate {
+		t.Errorf("reason: expected %s but get %s", ReasonLeftmostPrivate, reason)
+	}
+}
+
+// longChain returns n copies of address joined as a list header value.
+func longChain(address string, n int) string {
+	return strings.TrimSuffix(strings.Repeat(address+", ", n), ", ")
+}
+
+func TestWithMaxChainLength(t *testing.T) {
+	var calls int
+	skipAll := WithTrustedPredicate(func(net.IP) bool {
+		calls++
+		return true
+	})
+
+	testData := []struct {
+		name      string
+		extractor *Extractor
+		header    string
+		calls     int
+	}{
+		{name: "Default X-Forwarded-For", extractor: New(skipAll), header: "X-Forwarded-For", calls: DefaultMaxChainLength},
+		{name: "Default Forwarded", extractor: New(skipAll, WithHeaders(forwardedHeader)), header: "Forwarded", calls: DefaultMaxChainLength},
+		{name: "Custom", extractor: New(skipAll, WithMaxChainLength(10)), header: "X-Forwarded-For", calls: 10},
+		{name: "Unlimited", extractor: New(skipAll, WithMaxChainLength(0)), header: "X-Forwarded-For", calls: 1000},
+	}
+
+	for _, v := range testData {
+		value := longChain("144.12.54.87", 1000)
+		if v.header == "Forwarded" {
+			value = longChain("for=144.12.54.87", 1000)
+		}
+		r := &http.Request{RemoteAddr: "10.0.0.1:8080", Header: http.Header{v.header: {value}}}
+
+		calls = 0
+		if actual := v.extractor.FromRequest(r); actual != "" {
+			t.Errorf("%s: expected no address but get %s", v.name, actual)
+		}
+		if calls != v.calls {
+			t.Errorf("%s: expected %d entries processed but get %d", v.name, v.calls, calls)
+		}
+	}
+
+	r := &http.Request{RemoteAddr: "10.0.0.1:8080", Header: http.Header{
+		"X-Forwarded-For": {longChain("10.0.0.2", 60) + ", 144.12.54.87"},
+		"Forwarded":       {"for=119.14.55.11"},
+	}}
+	if actual := New().FromRequest(r); actual != "119.14.55.11" {
+		t.Errorf("fallback: expected %s but get %s", "119.14.55.11", actual)
+	}
+	if actual := New(WithMaxChainLength(0)).FromRequest(r); actual != "144.12.54.87" {
+		t.Errorf("unlimited: expected %s but get %s", "144.12.54.87", actual)
+	}
+
+	_, trusted, _ := net.ParseCIDR("10.0.0.0/8")
+	r = &http.Request{RemoteAddr: "10.0.0.1:8080", Header: http.Header{
+		"X-Forwarded-For": {longChain("1.2.3.4", 60) + ", 144.12.54.87, 10.0.0.2"},
+		"Forwarded":       {longChain("for=1.2.3.4", 60) + ", for=144.12.54.87, for=10.0.0.2"},
+	}}
+	if actual := New(WithTrustedProxies(trusted)).FromRequest(r); actual != "144.12.54.87" {
+		t.Errorf("trusted: expected %s but get %s", "144.12.54.87", actual)
+	}
+
+	r = &http.Request{RemoteAddr: "10.0.0.1:8080", Header: http.Header{
+		"Forwarded": {"for=1.2.3.4, for=5.6.7.8, for=10.0.0.4", "for=10.0.0.2"},
+	}}
+	e := New(WithTrustedProxies(trusted), WithHeaders(forwardedHeader), WithMaxChainLength(3))
+	if actual := e.FromRequest(r); actual != "5.6.7.8" {
+		t.Errorf("trusted lines: expected %s but get %s", "5.6.7.8", actual)
+	}
+}
+
+func BenchmarkMaxChainLength(b *testing.B) {
+	r := &http.Request{RemoteAddr: "10.0.0.1:8080", Header: http.Header{
+		"X-Forwarded-For": {longChain("10.0.0.2", 5000)},
+	}}
+
+	for name, e := range map[string]*Extractor{"Default": New(), "Unlimited": New(WithMaxChainLength(0))} {
+		b.Run(name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if ip := e.FromRequest(r); ip != "" {
+					b.Fatalf("expected no address but get %s", ip)
+				}
+			}
+		})
+	}
+}
+
+func TestWithFallbackHeader(t *testing.T) {
+	custom := New(WithFallbackHeader("X-Original-Client-Ip"))
+	disabled := New(WithFallbackHeader(""))
+
+	testData := []struct {
+		name      string
+		extractor *Extractor
+		header    http.Header
+		expected  string
+	}{
+		{name: "Custom header", extractor: custom, header: http.Header{"X-Forwarded-For": {"10.0.0.2"}, "X-Original-Client-Ip": {"144.12.54.87"}}, expected: "144.12.54.87"},
+		{name: "Custom header with port", extractor: custom, header: http.Header{"X-Original-Client-Ip": {"144.12.54.87:4711"}}, expected: "144.12.54.87"},
+		{name: "Custom header ignores X-Real-IP", extractor: custom, header: http.Header{"X-Forwarded-For": {"10.0.0.2"}, "X-Real-Ip": {"144.12.54.87"}}, expected: ""},
+		{name: "Custom header lower precedence", extractor: custom, header: http.Header{"X-Forwarded-For": {"119.14.55.11"}, "X-Original-Client-Ip": {"144.12.54.87"}}, expected: "119.14.55.11"},
+		{name: "Disabled", extractor: disabled, header: http.Header{"X-Forwarded-For": {"10.0.0.2"}, "X-Real-Ip": {"144.12.54.87"}}, expected: ""},
+		{name: "Disabled only header", extractor: disabled, header: http.Header{"X-Real-Ip": {"144.12.54.87"}}, expected: "10.0.0.1"},
+		{name: "Disabled X-Forwarded-For", extractor: disabled, header: http.Header{"X-Forwarded-For": {"144.12.54.87"}}, expected: "144.12.54.87"},
+	}
+
+	for _, v := range testData {
+		r := &http.Request{RemoteAddr: "10.0.0.1:8080", Header: v.header}
+		if actual := v.extractor.FromRequest(r); actual != v.expected {
+			t.Errorf("%s: expected %s but get %s", v.name, v.expected, actual)
+		}
+	}
+
+	r := &http.Request{RemoteAddr: "10.0.0.1:8080", Header: http.Header{"X-Forwarded-For": {"10.0.0.2"}, "X-Real-Ip": {"144.12.54.87"}}}
+	if _, err := disabled.FromRequestIP(r); !errors.Is(err, ErrNoValidIP) {
+		t.Errorf("Disabled IP: expected %v but get %v", ErrNoValidIP, err)
+	}
+
+	r = &http.Request{RemoteAddr: "10.0.0.1:8080", Header: http.Header{"X-Original-Client-Ip": {"144.12.54.87"}}}
+	if _, reason := custom.ResolveWithReason(r); reason != ReasonXRealIPFallback {
+		t.Errorf("Reason: expected %s but get %s", ReasonXRealIPFallback, reason)
+	}
+	if len(defaultHeaderChain) != 3 || defaultHeaderChain[2].Name != xRealIpHeader {
+		t.Errorf("Default chain modified: %v", defaultHeaderChain)
+	}
+}
@@ -0,0 +1,94 @@
+package realip
+
+import (
+	"net/netip"
+	"strings"
+)
+
+// AppendForwarded appends an RFC 7239 element for clientIP, and proto if it
+// is not empty, to the existing value of a Forwarded header, for services
+// that proxy requests onward. IPv6 addresses are bracketed and quoted, e.g.
+// for="[2001:db8::1]";proto=https. A clientIP that is not an IP address is
+// written as an obfuscated identifier if it is one, or as unknown.
+func AppendForwarded(existing string, clientIP string, proto string) string {
+	element := "for=" + forwardedNode(clientIP)
+	if proto != "" {
+		element += ";proto=" + forwardedValue(proto)
+	}
+
+	return appendListElement(existing, element)
+}
+
+// AppendXForwardedFor appends clientIP to the existing value of an
+// X-Forwarded-For header, for services that proxy requests onward, the same
+// way httputil.ReverseProxy does: entries are separated by a comma and a
+// space, and an empty existing value yields clientIP alone. A port is removed
+// from clientIP, e.g. from [2001:db8::1]:443, and existing is returned
+// unchanged if clientIP is not a single IP address, so it can't add hops.
+func AppendXForwardedFor(existing string, clientIP string) string {
+	addr, err := parseAddress(stripPort(strings.TrimSpace(clientIP)))
+	if err != nil {
+		return existing
+	}
+
+	return appendListElement(existing, addr.String())
+}
+
+// forwardedNode formats ip as the node of a for parameter.
+func forwardedNode(ip string) string {
+	addr, err := netip.ParseAddr(ip)
+	switch {
+	case err == nil && addr.Is6() && !addr.Is4In6():
+		return `"[` + addr.String() + `]"`
+	case err == nil:
+		return addr.Unmap().String()
+	case isObfuscatedNode(ip):
+		return ip
+	}
+
+	return "unknown"
+}
+
+// forwardedValue formats value as a token, or as a quoted-string if it
+// contains characters a token can't hold.
+func forwardedValue(value string) string {
+	for i := 0; i < len(value); i++ {
+		if !isTokenChar(value[i]) {
+			return quote(value)
+		}
+	}
+
+	return value
+}
+
+// quote formats s as a quoted-string.
+func quote(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		if s[i] == '"' || s[i] == '\\' {
+			b.WriteByte('\\')
+		}
+		b.WriteByte(s[i])
+	}
+	b.WriteByte('"')
+
+	return b.String()
+}
+
+// isTokenChar reports whether c may appear in an RFC 7230 token.
+func isTokenChar(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' ||
+		strings.IndexByte("!#$%&'*+-.^_`|~", c) >= 0
+}
+
+// appendListElement appends element to the existing value of a comma
+// separated list header, ignoring trailing separators and whitespace.
+func appendListElement(existing, element string) string {
+	existing = strings.TrimRight(existing, ", \t")
+	if existing == "" {
+		return element
+	}
+
+	return existing + ", " + element
+}
@@ -0,0 +1,62 @@
+package realip
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestAppendForwarded(t *testing.T) {
+	testData := []struct {
+		existing string
+		clientIP string
+		proto    string
+		expected string
+	}{
+		{existing: "", clientIP: "192.0.2.60", proto: "https", expected: "for=192.0.2.60;proto=https"},
+		{existing: "for=192.0.2.43", clientIP: "2001:db8:cafe::17", proto: "", expected: `for=192.0.2.43, for="[2001:db8:cafe::17]"`},
+		{existing: "for=192.0.2.43, ", clientIP: "::ffff:192.0.2.60", proto: "http", expected: "for=192.0.2.43, for=192.0.2.60;proto=http"},
+		{existing: "", clientIP: "_hidden", proto: `we"ird`, expected: `for=_hidden;proto="we\"ird"`},
+		{existing: "", clientIP: "garbage", proto: "", expected: "for=unknown"},
+	}
+
+	for _, v := range testData {
+		actual := AppendForwarded(v.existing, v.clientIP, v.proto)
+		if actual != v.expected {
+			t.Errorf("%q + %s: expected %s but get %s", v.existing, v.clientIP, v.expected, actual)
+		}
+		if !isStrictForwardedNode(forwardedNode(v.clientIP)) {
+			t.Errorf("%s: expected a strict node but get %s", v.clientIP, forwardedNode(v.clientIP))
+		}
+	}
+
+	// The parser reads back what was written
+	header := AppendForwarded(AppendForwarded("", "144.12.54.87", "https"), "2a00:1450::1", "")
+	r := &http.Request{Header: http.Header{"Forwarded": {header}}}
+	if actual := ChainFromRequest(r); len(actual) != 2 || actual[0] != "144.12.54.87" || actual[1] != "2a00:1450::1" {
+		t.Errorf("%s: expected [144.12.54.87 2a00:1450::1] but get %v", header, actual)
+	}
+}
+
+func TestAppendXForwardedFor(t *testing.T) {
+	testData := []struct {
+		existing string
+		clientIP string
+		expected string
+	}{
+		{existing: "", clientIP: "144.12.54.87", expected: "144.12.54.87"},
+		{existing: "  ", clientIP: "144.12.54.87", expected: "144.12.54.87"},
+		{existing: "1.2.3.4", clientIP: "144.12.54.87", expected: "1.2.3.4, 144.12.54.87"},
+		{existing: "1.2.3.4, 10.0.0.1,", clientIP: " 2a00:1450::1 ", expected: "1.2.3.4, 10.0.0.1, 2a00:1450::1"},
+		{existing: "1.2.3.4", clientIP: "[2a00::1]:443", expected: "1.2.3.4, 2a00::1"},
+		{existing: "1.2.3.4", clientIP: "144.12.54.87:8080", expected: "1.2.3.4, 144.12.54.87"},
+		{existing: "1.2.3.4", clientIP: "5.6.7.8, 6.6.6.6", expected: "1.2.3.4"},
+		{existing: "1.2.3.4,", clientIP: "garbage", expected: "1.2.3.4,"},
+		{existing: "", clientIP: "", expected: ""},
+	}
+
+	for _, v := range testData {
+		if actual := AppendXForwardedFor(v.existing, v.clientIP); actual != v.expected {
+			t.Errorf("%q + %s: expected %s but get %s", v.existing, v.clientIP, v.expected, actual)
+		}
+	}
+}
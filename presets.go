@@ -0,0 +1,102 @@
+package realip
+
+import (
+	"net"
+	"net/netip"
+)
+
+// ReverseProxyExtractor returns an Extractor for applications behind a Go
+// httputil.ReverseProxy, or any proxy with the same behavior, running in
+// proxyRanges.
+//
+// httputil.ReverseProxy appends the address of its own peer to
+// X-Forwarded-For, so the rightmost entry is the client as observed by the
+// proxy and everything to its left is whatever the client sent. The
+// forwarding headers are ignored unless the request comes from proxyRanges,
+// and the rightmost entry outside of proxyRanges is returned.
+func ReverseProxyExtractor(proxyRanges []*net.IPNet) *Extractor {
+	return New(WithTrustedProxies(proxyRanges...))
+}
+
+// ApacheModRemoteIPExtractor returns an Extractor resolving X-Forwarded-For
+// like Apache mod_remoteip configured with RemoteIPTrustedProxy and
+// RemoteIPInternalProxy.
+//
+// The header is only processed when the request comes from one of the
+// proxies, and is consumed from the right for as long as the address that
+// presented the entry is a proxy. Internal proxies are trusted to present
+// any address, while trusted proxies may only present public ones: a private
+// address presented by a trusted proxy is rejected, and the trusted proxy
+// itself is the client. Forwarded and X-Real-IP are ignored.
+func ApacheModRemoteIPExtractor(trustedProxies, internalProxies []*net.IPNet) *Extractor {
+	return New(
+		WithTrustedProxies(trustedProxies...),
+		WithTrustedProxies(internalProxies...),
+		withInternalProxies(prefixesFromIPNets(internalProxies)),
+		WithDenyHeaders(forwardedHeader, xRealIpHeader),
+	)
+}
+
+// caddyPrivateRanges are the ranges of Caddy's private_ranges shorthand for
+// trusted_proxies.
+var caddyPrivateRanges = parseCidrBlocks([]string{
+	"192.168.0.0/16",
+	"172.16.0.0/12",
+	"10.0.0.0/8",
+	"127.0.0.1/8",
+	"fd00::/8",
+	"::1/128",
+})
+
+// CaddyExtractor returns an Extractor resolving the client address like
+// Caddy's client_ip with trusted_proxies and trusted_proxies_strict, for
+// applications behind Caddy.
+//
+// X-Forwarded-For is only processed when the request comes from one of the
+// trusted ranges, and the rightmost entry that is not trusted is the client.
+// When trusted is empty, the ranges of Caddy's private_ranges shorthand are
+// trusted. Forwarded and X-Real-IP are ignored, as Caddy does by default.
+func CaddyExtractor(trusted []*net.IPNet) *Extractor {
+	ranges := prefixesFromIPNets(trusted)
+	if len(ranges) == 0 {
+		ranges = caddyPrivateRanges
+	}
+
+	return New(
+		withTrustedPrefixes(ranges),
+		WithHeaderChain([]HeaderSpec{{Name: xForwardedForHeader, Kind: HeaderList}}),
+	)
+}
+
+// VarnishExtractor returns an Extractor for applications behind Varnish
+// running in trusted.
+//
+// The builtin VCL of Varnish appends client.ip, the address of its own peer,
+// to X-Forwarded-For, or sets the header to it when the request has none, and
+// configurations replacing the header with client.ip are common too. Either
+// way the rightmost entry is the client as observed by Varnish, so the header
+// is only processed when the request comes from trusted, and the rightmost
+// entry outside of trusted is the client. Varnish passes Forwarded and
+// X-Real-IP through untouched, so they are ignored. A nil or empty trusted
+// trusts no proxy, so every request resolves to its peer.
+func VarnishExtractor(trusted []*net.IPNet) *Extractor {
+	return New(
+		WithTrustedProxies(trusted...),
+		WithHeaderChain([]HeaderSpec{{Name: xForwardedForHeader, Kind: HeaderList}}),
+	)
+}
+
+// withTrustedPrefixes trusts the given ranges, like WithTrustedProxies.
+func withTrustedPrefixes(ranges []netip.Prefix) Option {
+	return func(e *Extractor) {
+		e.trusted = append(e.trusted, ranges...)
+		e.trustedSet = true
+	}
+}
+
+// withInternalProxies sets the internal proxies of mod_remoteip semantics.
+func withInternalProxies(internal []netip.Prefix) Option {
+	return func(e *Extractor) {
+		e.internal = append(make([]netip.Prefix, 0, len(internal)), internal...)
+	}
+}
@@ -0,0 +1,191 @@
+package realip
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"testing"
+)
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+func TestReverseProxyExtractor(t *testing.T) {
+	_, proxies, _ := net.ParseCIDR("10.0.0.0/8")
+	e := ReverseProxyExtractor([]*net.IPNet{proxies})
+
+	// Capture the request a ReverseProxy at 10.0.0.2 forwards for a client
+	// at 144.12.54.87 that sent a spoofed X-Forwarded-For
+	var forwarded *http.Request
+	target, _ := url.Parse("http://backend.internal")
+	proxy := httputil.NewSingleHostReverseProxy(target)
+	proxy.Transport = roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		forwarded = r
+		return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody, Header: http.Header{}}, nil
+	})
+
+	inbound := httptest.NewRequest(http.MethodGet, "http://example.com/", nil)
+	inbound.RemoteAddr = "144.12.54.87:51234"
+	inbound.Header.Set("X-Forwarded-For", "1.2.3.4")
+	proxy.ServeHTTP(httptest.NewRecorder(), inbound)
+
+	if forwarded == nil {
+		t.Fatal("the proxy did not forward the request")
+	}
+	if actual := forwarded.Header.Get("X-Forwarded-For"); actual != "1.2.3.4, 144.12.54.87" {
+		t.Fatalf("unexpected X-Forwarded-For %q", actual)
+	}
+
+	forwarded.RemoteAddr = "10.0.0.2:40000"
+	if actual := e.FromRequest(forwarded); actual != "144.12.54.87" {
+		t.Errorf("behind proxy: expected %s but get %s", "144.12.54.87", actual)
+	}
+
+	forwarded.RemoteAddr = "119.14.55.11:40000"
+	if actual := e.FromRequest(forwarded); actual != "119.14.55.11" {
+		t.Errorf("bypassing proxy: expected %s but get %s", "119.14.55.11", actual)
+	}
+}
+
+func TestApacheModRemoteIPExtractor(t *testing.T) {
+	// RemoteIPTrustedProxy 13.182.55.0/24 and RemoteIPInternalProxy 10.0.2.0/24
+	_, trusted, _ := net.ParseCIDR("13.182.55.0/24")
+	_, internal, _ := net.ParseCIDR("10.0.2.0/24")
+	e := ApacheModRemoteIPExtractor([]*net.IPNet{trusted}, []*net.IPNet{internal})
+
+	testData := []struct {
+		name          string
+		remoteAddr    string
+		xForwardedFor string
+		expected      string
+	}{
+		{name: "Internal proxy presents a private client", remoteAddr: "10.0.2.1:80", xForwardedFor: "192.168.1.5", expected: "192.168.1.5"},
+		{name: "Internal proxy presents a public client", remoteAddr: "10.0.2.1:80", xForwardedFor: "144.12.54.87", expected: "144.12.54.87"},
+		{name: "Trusted proxy presents a public client", remoteAddr: "13.182.55.11:80", xForwardedFor: "144.12.54.87", expected: "144.12.54.87"},
+		{name: "Trusted proxy presents a private client", remoteAddr: "13.182.55.11:80", xForwardedFor: "192.168.1.5", expected: "13.182.55.11"},
+		{name: "Trusted proxy behind internal proxy", remoteAddr: "10.0.2.1:80", xForwardedFor: "144.12.54.87, 13.182.55.11", expected: "144.12.54.87"},
+		{name: "Private client of trusted proxy behind internal proxy", remoteAddr: "10.0.2.1:80", xForwardedFor: "192.168.1.5, 13.182.55.11", expected: "13.182.55.11"},
+		{name: "Spoofed entries left of the client", remoteAddr: "10.0.2.1:80", xForwardedFor: "1.2.3.4, 144.12.54.87, 13.182.55.11", expected: "144.12.54.87"},
+		{name: "Untrusted peer", remoteAddr: "119.14.55.11:80", xForwardedFor: "144.12.54.87", expected: "119.14.55.11"},
+	}
+
+	for _, v := range testData {
+		r := &http.Request{RemoteAddr: v.remoteAddr, Header: http.Header{
+			"X-Forwarded-For": {v.xForwardedFor},
+			"Forwarded":       {"for=1.2.3.4"},
+		}}
+		if actual := e.FromRequest(r); actual != v.expected {
+			t.Errorf("%s: expected %s but get %s", v.name, v.expected, actual)
+		}
+	}
+}
+
+func TestCaddyExtractor(t *testing.T) {
+	_, cdn, _ := net.ParseCIDR("13.182.0.0/16")
+
+	testData := []struct {
+		name      string
+		extractor *Extractor
+		request   *http.Request
+		expected  string
+	}{
+		{
+			name:      "Private ranges trusted by default",
+			extractor: CaddyExtractor(nil),
+			request: &http.Request{RemoteAddr: "10.0.0.1:443", Header: http.Header{
+				"X-Forwarded-For": {"1.2.3.4, 144.12.54.87, 192.168.1.5"},
+			}},
+			expected: "144.12.54.87",
+		}, {
+			name:      "Untrusted peer",
+			extractor: CaddyExtractor(nil),
+			request: &http.Request{RemoteAddr: "119.14.55.11:443", Header: http.Header{
+				"X-Forwarded-For": {"144.12.54.87"},
+			}},
+			expected: "119.14.55.11",
+		}, {
+			name:      "Every entry trusted",
+			extractor: CaddyExtractor(nil),
+			request: &http.Request{RemoteAddr: "10.0.0.1:443", Header: http.Header{
+				"X-Forwarded-For": {"10.0.0.3, 10.0.0.2"},
+			}},
+			expected: "10.0.0.3",
+		}, {
+			name:      "Custom trusted ranges",
+			extractor: CaddyExtractor([]*net.IPNet{cdn}),
+			request: &http.Request{RemoteAddr: "13.182.55.11:443", Header: http.Header{
+				"X-Forwarded-For": {"144.12.54.87, 13.182.1.1"},
+			}},
+			expected: "144.12.54.87",
+		}, {
+			name:      "Custom ranges replace private ranges",
+			extractor: CaddyExtractor([]*net.IPNet{cdn}),
+			request: &http.Request{RemoteAddr: "10.0.0.1:443", Header: http.Header{
+				"X-Forwarded-For": {"144.12.54.87"},
+			}},
+			expected: "10.0.0.1",
+		}, {
+			name:      "Forwarded and X-Real-IP ignored",
+			extractor: CaddyExtractor(nil),
+			request: &http.Request{RemoteAddr: "10.0.0.1:443", Header: http.Header{
+				"Forwarded": {"for=144.12.54.87"},
+				"X-Real-Ip": {"119.14.55.11"},
+			}},
+			expected: "10.0.0.1",
+		},
+	}
+
+	for _, v := range testData {
+		if actual := v.extractor.FromRequest(v.request); actual != v.expected {
+			t.Errorf("%s: expected %s but get %s", v.name, v.expected, actual)
+		}
+	}
+}
+
+func TestVarnishExtractor(t *testing.T) {
+	_, varnish, _ := net.ParseCIDR("10.0.1.0/24")
+	e := VarnishExtractor([]*net.IPNet{varnish})
+
+	testData := []struct {
+		name     string
+		request  *http.Request
+		expected string
+	}{
+		{
+			name: "Appended client.ip",
+			request: &http.Request{RemoteAddr: "10.0.1.5:6081", Header: http.Header{
+				"X-Forwarded-For": {"1.2.3.4, 144.12.54.87"},
+				"Forwarded":       {"for=6.6.6.6"},
+				"X-Real-Ip":       {"6.6.6.6"},
+			}},
+			expected: "144.12.54.87",
+		}, {
+			name: "Varnish tiers",
+			request: &http.Request{RemoteAddr: "10.0.1.5:6081", Header: http.Header{
+				"X-Forwarded-For": {"144.12.54.87, 10.0.1.6"},
+			}},
+			expected: "144.12.54.87",
+		}, {
+			name: "Bypassing Varnish",
+			request: &http.Request{RemoteAddr: "119.14.55.11:51234", Header: http.Header{
+				"X-Forwarded-For": {"144.12.54.87"},
+			}},
+			expected: "119.14.55.11",
+		},
+	}
+
+	for _, v := range testData {
+		if actual := e.FromRequest(v.request); actual != v.expected {
+			t.Errorf("%s: expected %s but get %s", v.name, v.expected, actual)
+		}
+	}
+	r := &http.Request{RemoteAddr: "10.0.1.5:6081", Header: http.Header{"X-Forwarded-For": {"144.12.54.87"}}}
+	if actual := VarnishExtractor(nil).FromRequest(r); actual != "10.0.1.5" {
+		t.Errorf("No trusted set: expected %s but get %s", "10.0.1.5", actual)
+	}
+}
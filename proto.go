@@ -0,0 +1,70 @@
+package realip
+
+import (
+	"net/http"
+	"strings"
+)
+
+var xForwardedProtoHeader = http.CanonicalHeaderKey("X-Forwarded-Proto")
+
+// SchemeFromRequest returns the scheme the client used, "https" or "http", to
+// build redirects and decide whether cookies must be secure behind TLS
+// terminating proxies. It is the scheme recorded by the closest proxy: the
+// proto parameter of the rightmost Forwarded element, or else the rightmost
+// X-Forwarded-Proto entry, so a client can't override it by sending its own.
+// With trusted proxies, the headers are only honored when the peer is one of
+// them. It defaults to "http", or "https" when the request itself came over
+// TLS. Any proto other than https, e.g. from a misconfigured proxy, is
+// reported as "http".
+func (e *Extractor) SchemeFromRequest(r *http.Request) string {
+	if e.proto(r) == "https" {
+		return "https"
+	}
+
+	return "http"
+}
+
+// proto returns the scheme the client used, as recorded by the closest proxy
+// in the Forwarded or X-Forwarded-Proto header, falling back to the scheme of
+// the request itself. With trusted proxies, the headers of a request whose
+// peer is not trusted are ignored.
+func (e *Extractor) proto(r *http.Request) string {
+	if in := requestInput(r); !e.trustedSet || e.isTrusted(remoteIP(in)) {
+		if proto := e.forwardedProto(in); proto != "" {
+			return proto
+		}
+	}
+
+	if r.TLS != nil {
+		return "https"
+	}
+
+	return "http"
+}
+
+// forwardedProto returns the lowercased proto of the rightmost Forwarded
+// element, or else the rightmost X-Forwarded-Proto entry, the ones appended
+// by the closest proxy, or an empty string if neither is set.
+func (e *Extractor) forwardedProto(in input) string {
+	if lines := e.values(in, forwardedHeader); len(lines) > 0 {
+		elements := e.parseForwarded(lines[len(lines)-1])
+		if n := len(elements); n > 0 && elements[n-1].Proto != "" {
+			return strings.ToLower(elements[n-1].Proto)
+		}
+	}
+
+	if lines := e.values(in, xForwardedProtoHeader); len(lines) > 0 {
+		line := lines[len(lines)-1]
+		if proto := strings.TrimSpace(line[strings.LastIndexByte(line, ',')+1:]); proto != "" {
+			return strings.ToLower(proto)
+		}
+	}
+
+	return ""
+}
+
+// SchemeFromRequest returns the scheme the client used, "https" or "http",
+// taken from the Forwarded and X-Forwarded-Proto headers.
+func SchemeFromRequest(r *http.Request) string {
+	return defaultExtractor.SchemeFromRequest(r)
+}
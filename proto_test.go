@@ -0,0 +1,69 @@
+package realip
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"testing"
+)
+
+func TestSchemeFromRequest(t *testing.T) {
+	testData := []struct {
+		name     string
+		header   http.Header
+		tls      bool
+		expected string
+	}{
+		{name: "No header", header: http.Header{}, expected: "http"},
+		{name: "No header over TLS", header: http.Header{}, tls: true, expected: "https"},
+		{name: "Forwarded", header: http.Header{"Forwarded": {"for=144.12.54.87;proto=https"}}, expected: "https"},
+		{name: "Forwarded upper case", header: http.Header{"Forwarded": {"for=144.12.54.87;proto=HTTPS"}}, expected: "https"},
+		{name: "Forwarded http", header: http.Header{"Forwarded": {"for=144.12.54.87;proto=http"}}, tls: true, expected: "http"},
+		{name: "Forwarded second element", header: http.Header{"Forwarded": {"for=144.12.54.87, for=10.0.0.2;proto=https"}}, expected: "https"},
+		{name: "Forwarded over X-Forwarded-Proto", header: http.Header{"Forwarded": {"proto=http"}, "X-Forwarded-Proto": {"https"}}, expected: "http"},
+		{name: "X-Forwarded-Proto", header: http.Header{"X-Forwarded-Proto": {"https"}}, expected: "https"},
+		{name: "X-Forwarded-Proto list", header: http.Header{"X-Forwarded-Proto": {" http , https "}}, expected: "https"},
+		{name: "X-Forwarded-Proto http", header: http.Header{"X-Forwarded-Proto": {"http"}}, expected: "http"},
+		{name: "Forwarded without proto", header: http.Header{"Forwarded": {"for=144.12.54.87"}, "X-Forwarded-Proto": {"https"}}, expected: "https"},
+		{name: "Other proto", header: http.Header{"X-Forwarded-Proto": {"gopher"}}, expected: "http"},
+	}
+
+	for _, v := range testData {
+		r := &http.Request{RemoteAddr: "10.0.0.1:8080", Header: v.header}
+		if v.tls {
+			r.TLS = &tls.ConnectionState{}
+		}
+		if actual := SchemeFromRequest(r); actual != v.expected {
+			t.Errorf("%s: expected %s but get %s", v.name, v.expected, actual)
+		}
+	}
+}
+
+func TestSchemeFromRequestSpoofing(t *testing.T) {
+	_, proxies, _ := net.ParseCIDR("10.0.0.0/8")
+	trusted := New(WithTrustedProxies(proxies))
+
+	testData := []struct {
+		name       string
+		extractor  *Extractor
+		remoteAddr string
+		header     http.Header
+		expected   string
+	}{
+		{name: "Untrusted peer X-Forwarded-Proto", extractor: trusted, remoteAddr: "8.8.8.8:443", header: http.Header{"X-Forwarded-Proto": {"https"}}, expected: "http"},
+		{name: "Untrusted peer Forwarded", extractor: trusted, remoteAddr: "8.8.8.8:443", header: http.Header{"Forwarded": {"for=8.8.8.8;proto=https"}}, expected: "http"},
+		{name: "Trusted peer", extractor: trusted, remoteAddr: "10.0.0.1:8080", header: http.Header{"X-Forwarded-Proto": {"https"}}, expected: "https"},
+		{name: "Client X-Forwarded-Proto entry", extractor: trusted, remoteAddr: "10.0.0.1:8080", header: http.Header{"X-Forwarded-Proto": {"https, http"}}, expected: "http"},
+		{name: "Client X-Forwarded-Proto line", extractor: trusted, remoteAddr: "10.0.0.1:8080", header: http.Header{"X-Forwarded-Proto": {"https", "http"}}, expected: "http"},
+		{name: "Client Forwarded element", extractor: trusted, remoteAddr: "10.0.0.1:8080", header: http.Header{"Forwarded": {"for=1.2.3.4;proto=https, for=144.12.54.87;proto=http"}}, expected: "http"},
+		{name: "Client Forwarded line", extractor: trusted, remoteAddr: "10.0.0.1:8080", header: http.Header{"Forwarded": {"proto=https", "for=144.12.54.87;proto=http"}}, expected: "http"},
+		{name: "Client proto without a proxy proto", extractor: New(), remoteAddr: "10.0.0.1:8080", header: http.Header{"Forwarded": {"proto=https, for=144.12.54.87"}}, expected: "http"},
+	}
+
+	for _, v := range testData {
+		r := &http.Request{RemoteAddr: v.remoteAddr, Header: v.header}
+		if actual := v.extractor.SchemeFromRequest(r); actual != v.expected {
+			t.Errorf("%s: expected %s but get %s", v.name, v.expected, actual)
+		}
+	}
+}
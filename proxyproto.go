@@ -0,0 +1,170 @@
+package realip
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/netip"
+	"strconv"
+	"strings"
+)
+
+// proxyV1MaxLength is the maximum length of a PROXY protocol v1 line,
+// including the CRLF.
+const proxyV1MaxLength = 107
+
+// FromProxyProtocolV1 parses a PROXY protocol v1 header line, such as
+// "PROXY TCP4 198.51.100.5 10.0.0.2 56324 443", as sent by HAProxy and L4
+// load balancers, and returns the source and destination addresses. The
+// trailing CRLF is optional.
+//
+// It returns ErrProxyProtocolUnknown for the "PROXY UNKNOWN" form, and
+// ErrMalformedProxyProtocol for any other line that does not follow the
+// specification.
+func FromProxyProtocolV1(line string) (src net.IP, dst net.IP, err error) {
+	if len(line) > proxyV1MaxLength {
+		return nil, nil, fmt.Errorf("%w: line too long", ErrMalformedProxyProtocol)
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+
+	fields := strings.Split(line, " ")
+	if len(fields) < 2 || fields[0] != "PROXY" {
+		return nil, nil, fmt.Errorf("%w: missing PROXY signature", ErrMalformedProxyProtocol)
+	}
+
+	var is4 bool
+	switch fields[1] {
+	case "TCP4":
+		is4 = true
+	case "TCP6":
+	case "UNKNOWN":
+		return nil, nil, ErrProxyProtocolUnknown
+	default:
+		return nil, nil, fmt.Errorf("%w: unknown protocol %q", ErrMalformedProxyProtocol, fields[1])
+	}
+	if len(fields) != 6 {
+		return nil, nil, fmt.Errorf("%w: expected 6 fields but get %d", ErrMalformedProxyProtocol, len(fields))
+	}
+
+	srcAddr, err := proxyV1Address(fields[2], is4)
+	if err != nil {
+		return nil, nil, err
+	}
+	dstAddr, err := proxyV1Address(fields[3], is4)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, port := range fields[4:] {
+		if !isProxyV1Port(port) {
+			return nil, nil, fmt.Errorf("%w: invalid port %q", ErrMalformedProxyProtocol, port)
+		}
+	}
+
+	return srcAddr.AsSlice(), dstAddr.AsSlice(), nil
+}
+
+// proxyV1Address parses an address of a PROXY protocol v1 line, which must
+// be of the family of the protocol.
+func proxyV1Address(field string, is4 bool) (netip.Addr, error) {
+	addr, err := netip.ParseAddr(field)
+	if err != nil || addr.Zone() != "" || addr.Is4() != is4 {
+		return netip.Addr{}, fmt.Errorf("%w: invalid address %q", ErrMalformedProxyProtocol, field)
+	}
+
+	return addr, nil
+}
+
+// isProxyV1Port reports whether field is a decimal port number without
+// leading zeros.
+func isProxyV1Port(field string) bool {
+	port, err := strconv.Atoi(field)
+	if err != nil || port < 0 || port > 65535 {
+		return false
+	}
+
+	return strconv.Itoa(port) == field
+}
+
+// proxyV2Signature starts every PROXY protocol v2 header.
+var proxyV2Signature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+
+// Commands, address families and address block lengths of the PROXY
+// protocol v2.
+const (
+	proxyV2Local = 0x20
+	proxyV2Proxy = 0x21
+
+	proxyV2Unspec = 0x0
+	proxyV2Inet   = 0x1
+	proxyV2Inet6  = 0x2
+	proxyV2Unix   = 0x3
+
+	proxyV2InetLength  = 12
+	proxyV2Inet6Length = 36
+)
+
+// ReadProxyProtocolV2 reads a binary PROXY protocol v2 header, as sent by
+// HAProxy and modern load balancers, from r and returns the source and
+// destination addresses and ports. The whole header is consumed, including
+// the TLVs following the addresses, so r is left at the start of the
+// proxied data.
+//
+// It returns ErrProxyProtocolLocal for the LOCAL command, and
+// ErrProxyProtocolUnknown for the unspecified and unix address families,
+// which carry no IP address. Any other header that does not follow the
+// specification, including a truncated one, yields
+// ErrMalformedProxyProtocol.
+func ReadProxyProtocolV2(r io.Reader) (src, dst net.IP, srcPort, dstPort uint16, err error) {
+	var header [16]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, nil, 0, 0, fmt.Errorf("%w: truncated header: %v", ErrMalformedProxyProtocol, err)
+	}
+	if !bytes.Equal(header[:12], proxyV2Signature) {
+		return nil, nil, 0, 0, fmt.Errorf("%w: missing PROXY v2 signature", ErrMalformedProxyProtocol)
+	}
+
+	block := make([]byte, binary.BigEndian.Uint16(header[14:]))
+	if _, err := io.ReadFull(r, block); err != nil {
+		return nil, nil, 0, 0, fmt.Errorf("%w: truncated address block: %v", ErrMalformedProxyProtocol, err)
+	}
+
+	switch header[12] {
+	case proxyV2Local:
+		return nil, nil, 0, 0, ErrProxyProtocolLocal
+	case proxyV2Proxy:
+	default:
+		return nil, nil, 0, 0, fmt.Errorf("%w: unknown version and command %#x", ErrMalformedProxyProtocol, header[12])
+	}
+
+	return proxyV2Addresses(header[13]>>4, block)
+}
+
+// proxyV2Addresses decodes the addresses and ports of the address block of
+// a PROXY protocol v2 header of the given address family.
+func proxyV2Addresses(family byte, block []byte) (src, dst net.IP, srcPort, dstPort uint16, err error) {
+	size := net.IPv4len
+	switch family {
+	case proxyV2Inet:
+		if len(block) < proxyV2InetLength {
+			return nil, nil, 0, 0, fmt.Errorf("%w: short IPv4 address block", ErrMalformedProxyProtocol)
+		}
+	case proxyV2Inet6:
+		if len(block) < proxyV2Inet6Length {
+			return nil, nil, 0, 0, fmt.Errorf("%w: short IPv6 address block", ErrMalformedProxyProtocol)
+		}
+		size = net.IPv6len
+	case proxyV2Unspec, proxyV2Unix:
+		return nil, nil, 0, 0, ErrProxyProtocolUnknown
+	default:
+		return nil, nil, 0, 0, fmt.Errorf("%w: unknown address family %#x", ErrMalformedProxyProtocol, family)
+	}
+
+	src = append(net.IP(nil), block[:size]...)
+	dst = append(net.IP(nil), block[size:2*size]...)
+	srcPort = binary.BigEndian.Uint16(block[2*size:])
+	dstPort = binary.BigEndian.Uint16(block[2*size+2:])
+
+	return src, dst, srcPort, dstPort, nil
+}
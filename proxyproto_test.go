@@ -0,0 +1,124 @@
+package realip
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net"
+	"testing"
+)
+
+func TestFromProxyProtocolV1(t *testing.T) {
+	testData := []struct {
+		name string
+		line string
+		src  string
+		dst  string
+		err  error
+	}{
+		{name: "TCP4", line: "PROXY TCP4 198.51.100.5 10.0.0.2 56324 443", src: "198.51.100.5", dst: "10.0.0.2"},
+		{name: "CRLF", line: "PROXY TCP4 198.51.100.5 10.0.0.2 56324 443\r\n", src: "198.51.100.5", dst: "10.0.0.2"},
+		{name: "TCP6", line: "PROXY TCP6 2a00:1450::1 fd00::2 56324 443\r\n", src: "2a00:1450::1", dst: "fd00::2"},
+		{name: "UNKNOWN", line: "PROXY UNKNOWN\r\n", err: ErrProxyProtocolUnknown},
+		{name: "UNKNOWN with addresses", line: "PROXY UNKNOWN ffff:f...f:ffff ffff:f...f:ffff 65535 65535\r\n", err: ErrProxyProtocolUnknown},
+		{name: "Unknown protocol", line: "PROXY UDP4 198.51.100.5 10.0.0.2 56324 443", err: ErrMalformedProxyProtocol},
+		{name: "Missing signature", line: "TCP4 198.51.100.5 10.0.0.2 56324 443", err: ErrMalformedProxyProtocol},
+		{name: "Empty", line: "", err: ErrMalformedProxyProtocol},
+		{name: "Missing port", line: "PROXY TCP4 198.51.100.5 10.0.0.2 56324", err: ErrMalformedProxyProtocol},
+		{name: "Double space", line: "PROXY TCP4  198.51.100.5 10.0.0.2 56324 443", err: ErrMalformedProxyProtocol},
+		{name: "Family mismatch", line: "PROXY TCP4 2a00:1450::1 10.0.0.2 56324 443", err: ErrMalformedProxyProtocol},
+		{name: "IPv4 in TCP6", line: "PROXY TCP6 198.51.100.5 fd00::2 56324 443", err: ErrMalformedProxyProtocol},
+		{name: "Invalid address", line: "PROXY TCP4 198.51.100.256 10.0.0.2 56324 443", err: ErrMalformedProxyProtocol},
+		{name: "Port out of range", line: "PROXY TCP4 198.51.100.5 10.0.0.2 65536 443", err: ErrMalformedProxyProtocol},
+		{name: "Leading zero port", line: "PROXY TCP4 198.51.100.5 10.0.0.2 056324 443", err: ErrMalformedProxyProtocol},
+		{name: "Line feed only", line: "PROXY TCP4 198.51.100.5 10.0.0.2 56324 443\n", err: ErrMalformedProxyProtocol},
+		{name: "Too long", line: "PROXY TCP6 " + "2a00:1450:0000:0000:0000:0000:0000:0001 2a00:1450:0000:0000:0000:0000:0000:0002 65535 65535 trailing-data\r\n", err: ErrMalformedProxyProtocol},
+	}
+
+	for _, v := range testData {
+		src, dst, err := FromProxyProtocolV1(v.line)
+		if !errors.Is(err, v.err) {
+			t.Errorf("%s: expected %v but get %v", v.name, v.err, err)
+			continue
+		}
+		if v.err != nil {
+			if src != nil || dst != nil {
+				t.Errorf("%s: expected no address but get %s and %s", v.name, src, dst)
+			}
+			continue
+		}
+		if !src.Equal(net.ParseIP(v.src)) || !dst.Equal(net.ParseIP(v.dst)) {
+			t.Errorf("%s: expected %s and %s but get %s and %s", v.name, v.src, v.dst, src, dst)
+		}
+	}
+}
+
+// proxyV2Header returns a PROXY protocol v2 header of the given command and
+// family byte, followed by block.
+func proxyV2Header(command, family byte, block []byte) []byte {
+	header := append([]byte("\r\n\r\n\x00\r\nQUIT\n"), command, family, byte(len(block)>>8), byte(len(block)))
+	return append(header, block...)
+}
+
+func TestReadProxyProtocolV2(t *testing.T) {
+	inet := []byte{198, 51, 100, 5, 10, 0, 0, 2, 0xdc, 0x04, 0x01, 0xbb}
+	inet6 := append(append(append([]byte{}, net.ParseIP("2a00:1450::1")...), net.ParseIP("fd00::2")...), 0xdc, 0x04, 0x01, 0xbb)
+
+	testData := []struct {
+		name   string
+		header []byte
+		src    string
+		dst    string
+		err    error
+	}{
+		{name: "IPv4", header: proxyV2Header(0x21, 0x11, inet), src: "198.51.100.5", dst: "10.0.0.2"},
+		{name: "IPv6", header: proxyV2Header(0x21, 0x21, inet6), src: "2a00:1450::1", dst: "fd00::2"},
+		{name: "TLVs", header: proxyV2Header(0x21, 0x11, append(append([]byte{}, inet...), 0x04, 0x00, 0x01, 0xff)), src: "198.51.100.5", dst: "10.0.0.2"},
+		{name: "LOCAL", header: proxyV2Header(0x20, 0x00, nil), err: ErrProxyProtocolLocal},
+		{name: "LOCAL with addresses", header: proxyV2Header(0x20, 0x11, inet), err: ErrProxyProtocolLocal},
+		{name: "Unspecified family", header: proxyV2Header(0x21, 0x00, nil), err: ErrProxyProtocolUnknown},
+		{name: "Unix family", header: proxyV2Header(0x21, 0x31, make([]byte, 216)), err: ErrProxyProtocolUnknown},
+		{name: "Unknown family", header: proxyV2Header(0x21, 0x41, inet), err: ErrMalformedProxyProtocol},
+		{name: "Version 1", header: proxyV2Header(0x11, 0x11, inet), err: ErrMalformedProxyProtocol},
+		{name: "Unknown command", header: proxyV2Header(0x22, 0x11, inet), err: ErrMalformedProxyProtocol},
+		{name: "Bad signature", header: append([]byte("PROXY TCP4 198"), make([]byte, 16)...), err: ErrMalformedProxyProtocol},
+		{name: "Short IPv4 block", header: proxyV2Header(0x21, 0x11, inet[:8]), err: ErrMalformedProxyProtocol},
+		{name: "Short IPv6 block", header: proxyV2Header(0x21, 0x21, inet), err: ErrMalformedProxyProtocol},
+		{name: "Truncated header", header: proxyV2Header(0x21, 0x11, inet)[:10], err: ErrMalformedProxyProtocol},
+		{name: "Truncated block", header: proxyV2Header(0x21, 0x11, inet)[:20], err: ErrMalformedProxyProtocol},
+		{name: "Empty", header: nil, err: ErrMalformedProxyProtocol},
+	}
+
+	for _, v := range testData {
+		src, dst, srcPort, dstPort, err := ReadProxyProtocolV2(bytes.NewReader(v.header))
+		if !errors.Is(err, v.err) {
+			t.Errorf("%s: expected %v but get %v", v.name, v.err, err)
+			continue
+		}
+		if v.err != nil {
+			if src != nil || dst != nil {
+				t.Errorf("%s: expected no address but get %s and %s", v.name, src, dst)
+			}
+			continue
+		}
+		if !src.Equal(net.ParseIP(v.src)) || !dst.Equal(net.ParseIP(v.dst)) {
+			t.Errorf("%s: expected %s and %s but get %s and %s", v.name, v.src, v.dst, src, dst)
+		}
+		if srcPort != 56324 || dstPort != 443 {
+			t.Errorf("%s: expected ports %d and %d but get %d and %d", v.name, 56324, 443, srcPort, dstPort)
+		}
+	}
+}
+
+func TestReadProxyProtocolV2Consumed(t *testing.T) {
+	inet := []byte{198, 51, 100, 5, 10, 0, 0, 2, 0xdc, 0x04, 0x01, 0xbb, 0x04, 0x00, 0x01, 0xff}
+	r := bytes.NewReader(append(proxyV2Header(0x21, 0x11, inet), "GET / HTTP/1.1\r\n"...))
+	if _, _, _, _, err := ReadProxyProtocolV2(r); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	rest, _ := io.ReadAll(r)
+	if string(rest) != "GET / HTTP/1.1\r\n" {
+		t.Errorf("expected %q but get %q", "GET / HTTP/1.1\r\n", rest)
+	}
+}
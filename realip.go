@@ -1,9 +1,26 @@
+// Package realip resolves the real IP address of the client of an HTTP
+// request from its forwarding headers and remote address.
+//
+// The package level functions, such as FromRequest, use the default
+// configuration. Other configurations are built with New and functional
+// options, which can be combined:
+//
+//	extractor := realip.New(
+//		realip.WithTrustedProxies(proxies...),
+//		realip.WithHeaders("X-Forwarded-For", "Forwarded"),
+//		realip.WithoutXRealIP(),
+//	)
+//	ip := extractor.FromRequest(r)
+//
+// New without options behaves the same as the package level functions.
 package realip
 
 import (
 	"errors"
+	"fmt"
 	"net"
 	"net/http"
+	"net/netip"
 	"strings"
 )
 
@@ -17,99 +34,355 @@ var xRealIpHeader = http.CanonicalHeaderKey("X-Real-IP")
 // e.g. Forwarded: for=192.0.2.60;proto=https;by=203.0.113.43
 var forwardedHeader = http.CanonicalHeaderKey("Forwarded")
 
-var cidrs []*net.IPNet
+// uniqueLocalIPv6 is the IPv6 unique local address block (RFC 4193).
+const uniqueLocalIPv6 = "fc00::/7"
+
+// Private CIDR blocks, along with the benchmarking and IPv6 documentation
+// blocks, which are never a real client either. They are parsed at package
+// initialization, before defaultExtractor is created, and must not be
+// modified afterwards.
+var cidrs = parseCidrBlocks([]string{
+	"127.0.0.1/8",    // localhost
+	"10.0.0.0/8",     // 24-bit block
+	"172.16.0.0/12",  // 20-bit block
+	"192.168.0.0/16", // 16-bit block
+	"169.254.0.0/16", // link local address
+	"100.64.0.0/10",  // carrier-grade NAT shared address space
+	"198.18.0.0/15",  // benchmarking (RFC 2544)
+	"::1/128",        // localhost IPv6
+	uniqueLocalIPv6,  // unique local address IPv6
+	"fe80::/10",      // link local address IPv6
+	"2001:db8::/32",  // documentation IPv6 (RFC 3849)
+})
+
+// secureCidrs are the blocks NewSecure trusts as the application's own
+// proxies: loopback and the RFC 1918 ranges. The other private blocks are
+// not trusted, as addresses in shared, link local or documentation space may
+// belong to the network of a carrier or a neighbor rather than the
+// application's infrastructure.
+var secureCidrs = parseCidrBlocks([]string{
+	"127.0.0.1/8",    // localhost
+	"10.0.0.0/8",     // 24-bit block
+	"172.16.0.0/12",  // 20-bit block
+	"192.168.0.0/16", // 16-bit block
+	"::1/128",        // localhost IPv6
+})
+
+// nonRoutableCidrs are special purpose blocks that are not private but can
+// never be the source of a real client request. They are only skipped by an
+// Extractor created with WithNonRoutableRanges.
+var nonRoutableCidrs = parseCidrBlocks([]string{
+	"0.0.0.0/8",          // "this host on this network"
+	"192.0.0.0/24",       // IETF protocol assignments
+	"192.88.99.0/24",     // deprecated 6to4 relay anycast
+	"224.0.0.0/4",        // multicast
+	"255.255.255.255/32", // limited broadcast
+	"ff00::/8",           // multicast IPv6
+})
+
+// specialUseCidrs are special-use blocks reserved for documentation,
+// multicast or future use, which may look public but are never a real
+// client address. They are only skipped by an Extractor created with
+// WithSpecialUseRanges.
+var specialUseCidrs = parseCidrBlocks([]string{
+	"0.0.0.0/8",       // "this host on this network"
+	"192.0.2.0/24",    // TEST-NET-1
+	"198.51.100.0/24", // TEST-NET-2
+	"203.0.113.0/24",  // TEST-NET-3
+	"224.0.0.0/4",     // multicast
+	"240.0.0.0/4",     // reserved, including limited broadcast
+	"ff00::/8",        // multicast IPv6
+})
+
+// limitedBroadcast is never a valid source address. It is always skipped by
+// an Extractor created with WithPublicOnly.
+var limitedBroadcast = netip.AddrFrom4([4]byte{255, 255, 255, 255})
+
+// parseCidrBlocks parses a hardcoded list of CIDR blocks. It panics if any of
+// them is invalid, so a typo in the list can't go unnoticed.
+func parseCidrBlocks(blocks []string) []netip.Prefix {
+	prefixes, err := parseRanges(blocks)
+	if err != nil {
+		panic("realip: bad hardcoded CIDR block: " + err.Error())
+	}
+
+	return prefixes
+}
 
-func init() {
-	maxCidrBlocks := []string{
-		"127.0.0.1/8",    // localhost
-		"10.0.0.0/8",     // 24-bit block
-		"172.16.0.0/12",  // 20-bit block
-		"192.168.0.0/16", // 16-bit block
-		"169.254.0.0/16", // link local address
-		"::1/128",        // localhost IPv6
-		"fc00::/7",       // unique local address IPv6
-		"fe80::/10",      // link local address IPv6
+// parseRanges parses a list of CIDR blocks, returning an error wrapping
+// ErrInvalidRange for the first invalid one.
+func parseRanges(blocks []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, len(blocks))
+	for i, block := range blocks {
+		prefix, err := netip.ParsePrefix(block)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidRange, block)
+		}
+		prefixes[i] = prefix.Masked()
 	}
 
-	cidrs = make([]*net.IPNet, len(maxCidrBlocks))
-	for i, maxCidrBlock := range maxCidrBlocks {
-		_, cidr, _ := net.ParseCIDR(maxCidrBlock)
-		cidrs[i] = cidr
+	return prefixes, nil
+}
+
+// parseHostRanges parses a list of CIDR blocks and bare addresses, which
+// stand for single hosts, returning an error wrapping ErrInvalidRange for the
+// first invalid one. Surrounding spaces are ignored, and blocks of
+// IPv4-mapped IPv6 addresses, e.g. ::ffff:10.0.0.0/104, are unmapped to the
+// IPv4 block they cover, as addresses are unmapped before they are matched.
+func parseHostRanges(blocks []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, len(blocks))
+	for i, block := range blocks {
+		prefix, ok := parseHostRange(strings.TrimSpace(block))
+		if !ok {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidRange, block)
+		}
+		prefixes[i] = prefix
 	}
+
+	return prefixes, nil
+}
+
+// parseHostRange parses a CIDR block or a bare address for parseHostRanges.
+// Blocks of IPv4-mapped addresses shorter than /96 also cover addresses that
+// are not IPv4-mapped, and are rejected.
+func parseHostRange(block string) (netip.Prefix, bool) {
+	if ip, err := parseAddress(block); err == nil {
+		return netip.PrefixFrom(ip, ip.BitLen()), true
+	}
+	prefix, err := netip.ParsePrefix(block)
+	switch {
+	case err != nil:
+		return netip.Prefix{}, false
+	case !prefix.Addr().Is4In6():
+		return prefix.Masked(), true
+	case prefix.Bits() < 96:
+		return netip.Prefix{}, false
+	}
+
+	return netip.PrefixFrom(prefix.Addr().Unmap(), prefix.Bits()-96).Masked(), true
 }
 
-// isLocalAddress works by checking if the address is under private CIDR blocks.
-// List of private CIDR blocks can be seen on :
+// parseAddress parses address into a netip.Addr. IPv4-mapped IPv6 addresses
+// are unmapped so they are matched against the IPv4 blocks, the same as
+// net.IPNet.Contains does, and the zone of an IPv6 address, as in
+// fe80::1%eth0, is dropped. Addresses taken from forwarding headers lose
+// their zone with withoutZone too, so it is never returned.
+func parseAddress(address string) (netip.Addr, error) {
+	ipAddress, err := netip.ParseAddr(address)
+	if err != nil {
+		return netip.Addr{}, errors.New("address is not valid")
+	}
+
+	return ipAddress.WithZone("").Unmap(), nil
+}
+
+// prefixFromIPNet converts n to a netip.Prefix. IPv4 networks are always
+// converted to IPv4 prefixes, even when stored in 16-byte form.
+func prefixFromIPNet(n *net.IPNet) (netip.Prefix, bool) {
+	if n == nil {
+		return netip.Prefix{}, false
+	}
+
+	ip, ok := netip.AddrFromSlice(n.IP)
+	if !ok {
+		return netip.Prefix{}, false
+	}
+
+	ones, bits := n.Mask.Size()
+	if bits == 0 {
+		return netip.Prefix{}, false
+	}
+	if ip.Is4In6() && bits == 128 {
+		ones -= 96
+	}
+
+	return netip.PrefixFrom(ip.Unmap(), ones).Masked(), true
+}
+
+// prefixesFromIPNets converts networks to prefixes, skipping invalid entries.
+func prefixesFromIPNets(networks []*net.IPNet) []netip.Prefix {
+	prefixes := make([]netip.Prefix, 0, len(networks))
+	for _, n := range networks {
+		if prefix, ok := prefixFromIPNet(n); ok {
+			prefixes = append(prefixes, prefix)
+		}
+	}
+
+	return prefixes
+}
+
+// containsAddress reports whether any of the prefixes contains ip.
+func containsAddress(prefixes []netip.Prefix, ip netip.Addr) bool {
+	for i := range prefixes {
+		if prefixes[i].Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// PrivateRanges is a set of CIDR blocks whose addresses are never returned as
+// client addresses. An Extractor created with WithPrivateRanges carries its
+// own copy, so the set can be modified freely afterwards.
+type PrivateRanges []*net.IPNet
+
+// DefaultPrivateRanges returns a new copy of the CIDR blocks treated as
+// private by default: loopback, private, carrier-grade NAT, link local and
+// IPv6 unique local addresses, as well as the benchmarking block
+// 198.18.0.0/15 and the IPv6 documentation block 2001:db8::/32.
+func DefaultPrivateRanges() PrivateRanges {
+	return rangesFromPrefixes(cidrs)
+}
+
+// PrivateRanges returns a new copy of the CIDR blocks the Extractor treats as
+// private, e.g. to tell why an address was skipped. Blocks only skipped by
+// options such as WithNonRoutableRanges are not included.
+func (e *Extractor) PrivateRanges() PrivateRanges {
+	return rangesFromPrefixes(e.private)
+}
+
+// rangesFromPrefixes converts prefixes to a new PrivateRanges.
+func rangesFromPrefixes(prefixes []netip.Prefix) PrivateRanges {
+	ranges := make(PrivateRanges, len(prefixes))
+	for i, prefix := range prefixes {
+		ranges[i] = &net.IPNet{
+			IP:   prefix.Addr().AsSlice(),
+			Mask: net.CIDRMask(prefix.Bits(), prefix.Addr().BitLen()),
+		}
+	}
+
+	return ranges
+}
+
+// Contains reports whether ip lies within any of the ranges. IPv4-mapped IPv6
+// addresses are matched against the IPv4 ranges.
+func (p PrivateRanges) Contains(ip net.IP) bool {
+	for _, n := range p {
+		if n != nil && n.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// IsPrivate reports whether ip, an address in text form, lies within the
+// CIDR blocks treated as private by default, e.g. to check an address without
+// running the whole extraction. Zones are ignored and IPv4-mapped IPv6
+// addresses are matched as IPv4 addresses. It returns ErrInvalidIP if ip does
+// not parse. List of private CIDR blocks can be seen on :
 //
 // https://en.wikipedia.org/wiki/Private_network
 //
 // https://en.wikipedia.org/wiki/Link-local_address
-func isPrivateAddress(address string) (bool, error) {
-	ipAddress := net.ParseIP(address)
-	if ipAddress == nil {
-		return false, errors.New("address is not valid")
+func IsPrivate(ip string) (bool, error) {
+	ipAddress, err := parseAddress(ip)
+	if err != nil {
+		return false, fmt.Errorf("%w: %q", ErrInvalidIP, ip)
 	}
 
-	for i := range cidrs {
-		if cidrs[i].Contains(ipAddress) {
-			return true, nil
-		}
+	return containsAddress(cidrs, ipAddress), nil
+}
+
+// IsPrivateIP is like IsPrivate for a net.IP. Invalid addresses are never
+// private.
+func IsPrivateIP(ip net.IP) bool {
+	addr, ok := netip.AddrFromSlice(ip)
+	return ok && containsAddress(cidrs, addr.Unmap())
+}
+
+// IsUniqueLocalIPv6 reports whether ip is an IPv6 unique local address in
+// fc00::/7, as opposed to a global unicast address. IPv4 addresses, including
+// IPv4-mapped IPv6 ones, are never unique local.
+func IsUniqueLocalIPv6(ip net.IP) bool {
+	addr, ok := netip.AddrFromSlice(ip)
+	if !ok || addr.Is4() || addr.Is4In6() {
+		return false
 	}
 
-	return false, nil
+	return netip.MustParsePrefix(uniqueLocalIPv6).Contains(addr)
+}
+
+// IsSpecialUse reports whether ip lies within a special-use block that is
+// neither private nor a real client address: 0.0.0.0/8, the TEST-NET
+// documentation blocks, IPv4 and IPv6 multicast, and the reserved block
+// 240.0.0.0/4. IPv4-mapped IPv6 addresses are matched as IPv4 addresses.
+func IsSpecialUse(ip net.IP) bool {
+	addr, ok := netip.AddrFromSlice(ip)
+	return ok && containsAddress(specialUseCidrs, addr.Unmap())
 }
 
+// defaultExtractor backs the package level functions.
+var defaultExtractor = New()
+
 // FromRequest returns client's real public IP address from http request headers.
+//
+// It returns the first public address of the forwarding headers, whoever set
+// them, so any client can claim an arbitrary address by sending its own
+// X-Forwarded-For header. Use an Extractor created with NewSecure or
+// NewExtractor, which only trust headers relayed by known proxies, when the
+// result matters for security.
 func FromRequest(r *http.Request) string {
-	// Fetch header value
-	xRealIP := r.Header.Get(xRealIpHeader)
-	xForwardedFor := r.Header[xForwardedForHeader]
-	forwarded := r.Header.Get(forwardedHeader)
-
-	// If both empty, return IP from remote address
-	if xRealIP == "" && len(xForwardedFor) == 0 && forwarded == "" {
-		var remoteIP string
-
-		// If there are colon in remote address, remove the port number
-		// otherwise, return remote address as is
-		if strings.ContainsRune(r.RemoteAddr, ':') {
-			remoteIP, _, _ = net.SplitHostPort(r.RemoteAddr)
-		} else {
-			remoteIP = r.RemoteAddr
-		}
+	return defaultExtractor.FromRequest(r)
+}
 
-		return remoteIP
-	}
+// FromRequestE returns client's real public IP address from http request
+// headers, or ErrNoValidIP when no valid address could be determined.
+func FromRequestE(r *http.Request) (string, error) {
+	return defaultExtractor.FromRequestE(r)
+}
 
-	// Check list of IP in X-Forwarded-For and return the first global address
-	for _, a := range xForwardedFor {
-		for _, b := range strings.Split(a, ",") {
-			address := strings.TrimSpace(b)
-			isPrivate, err := isPrivateAddress(address)
-			if !isPrivate && err == nil {
-				return address
-			}
-		}
+// HasForwardingHeaders reports whether the request carries any of the
+// X-Forwarded-For, Forwarded or X-Real-IP headers.
+func HasForwardingHeaders(r *http.Request) bool {
+	return defaultExtractor.HasForwardingHeaders(r)
+}
+
+// ResolveDualStack returns the first public IPv4 and the first public IPv6
+// address of the client. Either of them is empty if the request does not
+// carry an address of that family.
+func ResolveDualStack(r *http.Request) (v4 string, v6 string) {
+	return defaultExtractor.ResolveDualStack(r)
+}
+
+// FromRequestNetip returns client's real public IP address from http request
+// headers as a netip.Addr. The boolean is false if no valid address was found.
+func FromRequestNetip(r *http.Request) (netip.Addr, bool) {
+	addr, err := netip.ParseAddr(FromRequest(r))
+	if err != nil {
+		return netip.Addr{}, false
 	}
 
-	// Check list of IPs in the new Forwarded header and return the first global address
-	for _, a := range strings.Split(forwarded, ";") {
-		for _, b := range strings.Split(a, ",") {
-			if strings.Contains(b, "for") {
-				c := strings.Split(b, "=")
-				if len(c) == 2 {
-					address := strings.TrimRight(strings.TrimLeft(strings.TrimSpace(c[1]), `"[`), `]"`)
-					isPrivate, err := isPrivateAddress(address)
-					if !isPrivate && err == nil {
-						return address
-					}
-				}
-			}
-		}
+	return addr, true
+}
+
+// Options configures FromRequestWithOptions.
+type Options struct {
+	// Headers are the forwarding headers to consult, in order of
+	// precedence, as with WithHeaders. The default headers, X-Forwarded-For,
+	// then Forwarded, then X-Real-IP, are used if it is empty. Behind a CDN,
+	// CDNHeaderNames lists the headers of CDNHeaders.
+	Headers []string
+}
+
+// FromRequestWithOptions returns client's real public IP address from the
+// http request headers listed in opts, in order. It creates an Extractor on
+// every call, so hot paths should rather reuse one created with
+// New(WithHeaders(...)).
+func FromRequestWithOptions(r *http.Request, opts Options) string {
+	if len(opts.Headers) == 0 {
+		return FromRequest(r)
 	}
 
-	// If nothing succeed, return X-Real-IP
-	return xRealIP
+	return New(WithHeaders(opts.Headers...)).FromRequest(r)
+}
+
+// FromRequestIP returns client's real public IP address from http request
+// headers as a net.IP, or ErrNoValidIP when no valid address could be
+// determined.
+func FromRequestIP(r *http.Request) (net.IP, error) {
+	return defaultExtractor.FromRequestIP(r)
 }
 
 // RealIP return client's real public IP address from http request headers.
@@ -1,9 +1,14 @@
 package realip
 
 import (
+	"errors"
 	"fmt"
+	"net"
 	"net/http"
+	"net/netip"
 	"testing"
+
+	"go.ajitem.com/realip/realiptest"
 )
 
 func TestIsPrivateAddr(t *testing.T) {
@@ -21,10 +26,29 @@ func TestIsPrivateAddr(t *testing.T) {
 		"172.32.0.0": false,
 
 		"147.12.56.11": false,
+
+		"100.63.255.255":  false,
+		"100.64.0.1":      true,
+		"100.127.255.255": true,
+		"100.128.0.0":     false,
+
+		"198.17.255.255": false,
+		"198.18.0.1":     true,
+		"198.19.255.255": true,
+		"198.20.0.1":     false,
+		"2001:db8::1":    true,
+		"2001:db9::1":    false,
+
+		"::ffff:10.0.0.1":     true,
+		"::ffff:147.12.56.11": false,
+
+		"fe80::1%eth0":      true,
+		"fe80::1%25":        true,
+		"2a00:1450::1%eth0": false,
 	}
 
 	for addr, isLocal := range testData {
-		isPrivate, err := isPrivateAddress(addr)
+		isPrivate, err := IsPrivate(addr)
 		if err != nil {
 			t.Errorf("fail processing %s: %v", addr, err)
 		}
@@ -41,6 +65,52 @@ func TestIsPrivateAddr(t *testing.T) {
 	}
 }
 
+func TestBenchmarkingAndDocumentationRanges(t *testing.T) {
+	testData := map[string]string{
+		"198.18.0.1, 144.12.54.87":     "144.12.54.87",
+		"198.19.255.255, 144.12.54.87": "144.12.54.87",
+		"198.20.0.1, 144.12.54.87":     "198.20.0.1",
+		"2001:db8::1, 2a00:1450::1":    "2a00:1450::1",
+	}
+
+	for xff, expected := range testData {
+		r := &http.Request{RemoteAddr: "10.0.0.1:8080", Header: http.Header{"X-Forwarded-For": {xff}}}
+		if actual := FromRequest(r); actual != expected {
+			t.Errorf("%s: expected %s but get %s", xff, expected, actual)
+		}
+	}
+}
+
+func TestIsPrivateErrors(t *testing.T) {
+	for _, addr := range []string{"", "not an address", "10.0.0.1:80", "10.0.0.0/8"} {
+		if _, err := IsPrivate(addr); !errors.Is(err, ErrInvalidIP) {
+			t.Errorf("%q: expected %v but get %v", addr, ErrInvalidIP, err)
+		}
+	}
+}
+
+func TestIsPrivateIP(t *testing.T) {
+	testData := map[string]bool{
+		"10.0.0.1":            true,
+		"::ffff:192.168.0.1":  true,
+		"fd00::1":             true,
+		"::1":                 true,
+		"147.12.56.11":        false,
+		"::ffff:147.12.56.11": false,
+		"2a00:1450::1":        false,
+	}
+
+	for addr, expected := range testData {
+		if actual := IsPrivateIP(net.ParseIP(addr)); actual != expected {
+			t.Errorf("%s: expected %t but get %t", addr, expected, actual)
+		}
+	}
+
+	if IsPrivateIP(nil) {
+		t.Errorf("nil: expected %t but get %t", false, true)
+	}
+}
+
 func TestRealIP(t *testing.T) {
 	// Create type and function for testing
 	type testIP struct {
@@ -50,23 +120,16 @@ func TestRealIP(t *testing.T) {
 	}
 
 	newRequest := func(remoteAddr, xRealIP string, forwarded bool, xForwardedFor ...string) *http.Request {
-		h := http.Header{}
+		headers := map[string][]string{}
 		if xRealIP != "" {
-			h.Set("X-Real-IP", xRealIP)
+			headers["X-Real-IP"] = []string{xRealIP}
 		}
 		if forwarded {
-			for _, address := range xForwardedFor {
-				h.Add("Forwarded", address)
-			}
+			headers["Forwarded"] = xForwardedFor
 		} else {
-			for _, address := range xForwardedFor {
-				h.Add("X-Forwarded-For", address)
-			}
-		}
-		return &http.Request{
-			RemoteAddr: remoteAddr,
-			Header:     h,
+			headers["X-Forwarded-For"] = xForwardedFor
 		}
+		return realiptest.NewRequest(remoteAddr, headers)
 	}
 
 	// Create test data
@@ -109,6 +172,10 @@ func TestRealIP(t *testing.T) {
 			name:     "Has X-Real-IP",
 			request:  newRequest("", "", false, publicAddr1),
 			expected: publicAddr1,
+		}, {
+			name:     "Has X-Real-IP with port",
+			request:  newRequest("", "203.0.113.5:12345", false),
+			expected: "203.0.113.5",
 		}, {
 			name:     "Has Forwarded",
 			request:  newRequest("", "", true, fmt.Sprintf("for=%s", publicAddr1)),
@@ -131,3 +198,239 @@ func TestRealIP(t *testing.T) {
 		}
 	}
 }
+
+func TestFromRequestNetip(t *testing.T) {
+	testData := []struct {
+		name     string
+		request  *http.Request
+		expected netip.Addr
+		ok       bool
+	}{
+		{
+			name:     "IPv4",
+			request:  &http.Request{RemoteAddr: "144.12.54.87:8080", Header: http.Header{}},
+			expected: netip.MustParseAddr("144.12.54.87"),
+			ok:       true,
+		}, {
+			name:     "IPv6",
+			request:  &http.Request{RemoteAddr: "[2001:4860::8888]:443", Header: http.Header{}},
+			expected: netip.MustParseAddr("2001:4860::8888"),
+			ok:       true,
+		}, {
+			name:     "Invalid",
+			request:  &http.Request{RemoteAddr: "", Header: http.Header{"X-Real-Ip": {"not-an-ip"}}},
+			expected: netip.Addr{},
+			ok:       false,
+		},
+	}
+
+	for _, v := range testData {
+		actual, ok := FromRequestNetip(v.request)
+		if ok != v.ok || actual != v.expected {
+			t.Errorf("%s: expected %v (%t) but get %v (%t)", v.name, v.expected, v.ok, actual, ok)
+		}
+	}
+}
+
+func TestIPv4MappedResult(t *testing.T) {
+	testData := []struct {
+		name    string
+		request *http.Request
+	}{
+		{name: "RemoteAddr", request: &http.Request{RemoteAddr: "[::ffff:144.12.54.87]:8080", Header: http.Header{}}},
+		{name: "X-Forwarded-For", request: &http.Request{RemoteAddr: "10.0.0.1:8080", Header: http.Header{"X-Forwarded-For": {"::ffff:144.12.54.87"}}}},
+		{name: "Forwarded", request: &http.Request{RemoteAddr: "10.0.0.1:8080", Header: http.Header{"Forwarded": {`for="[::ffff:144.12.54.87]"`}}}},
+		{name: "X-Real-IP", request: &http.Request{RemoteAddr: "10.0.0.1:8080", Header: http.Header{"X-Real-Ip": {"::ffff:144.12.54.87"}}}},
+	}
+
+	for _, v := range testData {
+		if actual := FromRequest(v.request); actual != "144.12.54.87" {
+			t.Errorf("%s: expected %s but get %s", v.name, "144.12.54.87", actual)
+		}
+		if actual, err := FromRequestE(v.request); actual != "144.12.54.87" || err != nil {
+			t.Errorf("%s: FromRequestE expected %s but get %s (%v)", v.name, "144.12.54.87", actual, err)
+		}
+	}
+}
+
+func TestFromRequestIP(t *testing.T) {
+	testData := []struct {
+		name     string
+		request  *http.Request
+		expected string
+		err      error
+	}{
+		{
+			name:     "IPv4",
+			request:  &http.Request{RemoteAddr: "144.12.54.87:8080", Header: http.Header{}},
+			expected: "144.12.54.87",
+		}, {
+			name:     "IPv6 header",
+			request:  &http.Request{RemoteAddr: "10.0.0.1:8080", Header: http.Header{"X-Forwarded-For": {"2001:4860::8888"}}},
+			expected: "2001:4860::8888",
+		}, {
+			name:     "X-Real-IP",
+			request:  &http.Request{RemoteAddr: "10.0.0.1:8080", Header: http.Header{"X-Real-Ip": {"144.12.54.87:1234"}}},
+			expected: "144.12.54.87",
+		}, {
+			name:     "Bracketed IPv6 X-Real-IP",
+			request:  &http.Request{RemoteAddr: "10.0.0.1:8080", Header: http.Header{"X-Real-Ip": {"[2a00:1450::1]:443"}}},
+			expected: "2a00:1450::1",
+		}, {
+			name:    "Garbage X-Real-IP",
+			request: &http.Request{RemoteAddr: "10.0.0.1:8080", Header: http.Header{"X-Real-Ip": {"not-an-ip"}}},
+			err:     ErrNoValidIP,
+		}, {
+			name:    "Unix socket",
+			request: &http.Request{RemoteAddr: "@", Header: http.Header{}},
+			err:     ErrUnixSocket,
+		}, {
+			name:    "Empty RemoteAddr",
+			request: &http.Request{RemoteAddr: "", Header: http.Header{}},
+			err:     ErrUnixSocket,
+		}, {
+			name:    "Stray colons",
+			request: &http.Request{RemoteAddr: "1:2:3", Header: http.Header{}},
+			err:     ErrInvalidRemoteAddr,
+		}, {
+			name:    "Unix socket with private header",
+			request: &http.Request{RemoteAddr: "@", Header: http.Header{"X-Forwarded-For": {"10.0.0.2"}}},
+			err:     ErrUnixSocket,
+		},
+	}
+
+	for _, v := range testData {
+		actual, err := FromRequestIP(v.request)
+		if errors.Is(err, ErrInvalidRemoteAddr) && !errors.Is(err, ErrNoValidIP) {
+			t.Errorf("%s: expected %v to wrap %v", v.name, err, ErrNoValidIP)
+		}
+		if v.err == ErrNoValidIP && errors.Is(err, ErrInvalidRemoteAddr) {
+			t.Errorf("%s: expected a valid remote address but get %v", v.name, err)
+		}
+		if !errors.Is(err, v.err) || (err == nil && !actual.Equal(net.ParseIP(v.expected))) || (err != nil && actual != nil) {
+			t.Errorf("%s: expected %s (%v) but get %s (%v)", v.name, v.expected, v.err, actual, err)
+		}
+		if ip := FromRequest(v.request); err == nil && ip != v.expected {
+			t.Errorf("%s: FromRequest expected %s but get %s", v.name, v.expected, ip)
+		}
+		if ip, e := FromRequestE(v.request); err == nil && (ip != v.expected || e != nil) {
+			t.Errorf("%s: FromRequestE expected %s but get %s (%v)", v.name, v.expected, ip, e)
+		}
+	}
+}
+
+func BenchmarkIsPrivate(b *testing.B) {
+	addresses := []string{"147.12.56.11", "192.168.1.1", "2001:4860::8888", "fe80::1"}
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		for _, addr := range addresses {
+			_, _ = IsPrivate(addr)
+		}
+	}
+}
+
+func TestPrefixFromIPNet(t *testing.T) {
+	testData := map[string]string{
+		"10.0.0.0/8":     "10.0.0.0/8",
+		"192.168.1.7/24": "192.168.1.0/24",
+		"2001:db8::/32":  "2001:db8::/32",
+	}
+
+	for cidr, expected := range testData {
+		_, n, _ := net.ParseCIDR(cidr)
+		if actual, ok := prefixFromIPNet(n); !ok || actual.String() != expected {
+			t.Errorf("%s: expected %s but get %s", cidr, expected, actual)
+		}
+	}
+
+	mapped := &net.IPNet{IP: net.ParseIP("10.0.0.0"), Mask: net.CIDRMask(104, 128)}
+	if actual, ok := prefixFromIPNet(mapped); !ok || actual.String() != "10.0.0.0/8" {
+		t.Errorf("16-byte IPv4: expected %s but get %s", "10.0.0.0/8", actual)
+	}
+
+	if _, ok := prefixFromIPNet(nil); ok {
+		t.Errorf("nil: expected not ok")
+	}
+}
+
+func TestIsSpecialUse(t *testing.T) {
+	testData := map[string]bool{
+		"0.0.0.0":          true,
+		"192.0.2.1":        true,
+		"198.51.100.1":     true,
+		"203.0.113.1":      true,
+		"224.0.0.1":        true,
+		"239.255.255.255":  true,
+		"240.0.0.1":        true,
+		"255.255.255.255":  true,
+		"ff02::1":          true,
+		"::ffff:224.0.0.1": true,
+		"144.12.54.87":     false,
+		"192.0.3.1":        false,
+		"10.0.0.1":         false,
+		"2a00:1450::1":     false,
+	}
+
+	for addr, expected := range testData {
+		if actual := IsSpecialUse(net.ParseIP(addr)); actual != expected {
+			t.Errorf("%s: expected %t but get %t", addr, expected, actual)
+		}
+	}
+
+	if IsSpecialUse(nil) {
+		t.Errorf("nil: expected false but get true")
+	}
+}
+
+func TestIsUniqueLocalIPv6(t *testing.T) {
+	testData := map[string]bool{
+		"fd00::1":         true,
+		"fc00::1":         true,
+		"2001:db8::1":     false,
+		"fe80::1":         false,
+		"::ffff:10.0.0.1": false,
+		"10.0.0.1":        false,
+	}
+
+	for addr, expected := range testData {
+		if actual := IsUniqueLocalIPv6(net.ParseIP(addr)); actual != expected {
+			t.Errorf("%s: expected %t but get %t", addr, expected, actual)
+		}
+	}
+
+	if IsUniqueLocalIPv6(nil) {
+		t.Errorf("nil: expected false but get true")
+	}
+}
+
+func benchmarkRequests() map[string]*http.Request {
+	return map[string]*http.Request{
+		"SingleIP": {RemoteAddr: "10.0.0.1:8080", Header: http.Header{
+			"X-Forwarded-For": {"144.12.54.87"},
+		}},
+		"FiveHops": {RemoteAddr: "10.0.0.1:8080", Header: http.Header{
+			"X-Forwarded-For": {"192.168.0.1, 10.0.0.5, 144.12.54.87, 10.0.0.3, 10.0.0.2"},
+		}},
+	}
+}
+
+func BenchmarkFromRequest(b *testing.B) {
+	for name, r := range benchmarkRequests() {
+		b.Run(name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if ip := FromRequest(r); ip != "144.12.54.87" {
+					b.Fatalf("expected %s but get %s", "144.12.54.87", ip)
+				}
+			}
+		})
+	}
+}
+
+func TestFromRequestAllocations(t *testing.T) {
+	r := benchmarkRequests()["SingleIP"]
+	if allocs := testing.AllocsPerRun(100, func() { _ = FromRequest(r) }); allocs != 0 {
+		t.Errorf("single IP: expected no allocation but get %v", allocs)
+	}
+}
@@ -0,0 +1,8 @@
+module go.ajitem.com/realip/realipchi
+
+go 1.18
+
+require (
+	github.com/go-chi/chi/v5 v5.0.8
+	go.ajitem.com/realip v0.0.0-20261014061242-fbf6fd597e66
+)
@@ -0,0 +1,43 @@
+// Package realipchi provides chi middleware storing the client address
+// resolved by realip in the request context.
+//
+// Chi's own middleware.RealIP rewrites RemoteAddr from True-Client-IP,
+// X-Real-IP or X-Forwarded-For whoever set them, so any client can choose
+// its address, and it can't be configured. The middleware of this package
+// follows the selection logic of realip instead, and with an Extractor
+// created with realip.NewExtractor or realip.NewSecure, only trusts the
+// headers of requests relayed by an allowlist of trusted proxies. RemoteAddr
+// is left untouched.
+package realipchi
+
+import (
+	"context"
+	"net/http"
+
+	"go.ajitem.com/realip"
+)
+
+// RealIPMiddleware resolves the client address of every request like
+// realip.FromRequest and stores it in the request context, where
+// RealIPFromContext retrieves it.
+func RealIPMiddleware(next http.Handler) http.Handler {
+	return realip.Middleware(next)
+}
+
+// ExtractorMiddleware returns a middleware like RealIPMiddleware, resolving
+// the client address with e, e.g. to honor its trusted proxies. A nil
+// Extractor resolves it like realip.FromRequest.
+func ExtractorMiddleware(e *realip.Extractor) func(http.Handler) http.Handler {
+	if e == nil {
+		return RealIPMiddleware
+	}
+
+	return e.Middleware
+}
+
+// RealIPFromContext returns the client address stored by the middleware, or
+// an empty string if the middleware did not handle the request.
+func RealIPFromContext(ctx context.Context) string {
+	ip, _ := realip.FromContext(ctx)
+	return ip
+}
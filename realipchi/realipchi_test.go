@@ -0,0 +1,48 @@
+package realipchi
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"go.ajitem.com/realip"
+)
+
+func TestMiddleware(t *testing.T) {
+	_, proxies, _ := net.ParseCIDR("10.0.0.0/8")
+
+	testData := []struct {
+		name       string
+		middleware func(http.Handler) http.Handler
+		expected   string
+	}{
+		{name: "Default", middleware: RealIPMiddleware, expected: "1.2.3.4"},
+		{name: "Nil extractor", middleware: ExtractorMiddleware(nil), expected: "1.2.3.4"},
+		{name: "Extractor", middleware: ExtractorMiddleware(realip.NewExtractor([]*net.IPNet{proxies})), expected: "144.12.54.87"},
+	}
+
+	for _, v := range testData {
+		var actual string
+		router := chi.NewRouter()
+		router.Use(v.middleware)
+		router.Get("/", func(w http.ResponseWriter, r *http.Request) {
+			actual = RealIPFromContext(r.Context())
+		})
+
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.RemoteAddr = "10.0.0.1:8080"
+		r.Header.Set("X-Forwarded-For", "1.2.3.4, 144.12.54.87")
+		router.ServeHTTP(httptest.NewRecorder(), r)
+
+		if actual != v.expected {
+			t.Errorf("%s: expected %s but get %s", v.name, v.expected, actual)
+		}
+	}
+
+	if actual := RealIPFromContext(context.Background()); actual != "" {
+		t.Errorf("No middleware: expected no address but get %s", actual)
+	}
+}
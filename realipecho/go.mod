@@ -0,0 +1,20 @@
+module go.ajitem.com/realip/realipecho
+
+go 1.18
+
+require (
+	github.com/labstack/echo/v4 v4.10.2
+	go.ajitem.com/realip v0.0.0-20261014061242-fbf6fd597e66
+)
+
+require (
+	github.com/labstack/gommon v0.4.0 // indirect
+	github.com/mattn/go-colorable v0.1.13 // indirect
+	github.com/mattn/go-isatty v0.0.17 // indirect
+	github.com/valyala/bytebufferpool v1.0.0 // indirect
+	github.com/valyala/fasttemplate v1.2.2 // indirect
+	golang.org/x/crypto v0.6.0 // indirect
+	golang.org/x/net v0.7.0 // indirect
+	golang.org/x/sys v0.5.0 // indirect
+	golang.org/x/text v0.7.0 // indirect
+)
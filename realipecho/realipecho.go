@@ -0,0 +1,51 @@
+// Package realipecho provides an Echo middleware setting the client address
+// resolved by realip into the Echo context. It lives in its own package so
+// that applications not using Echo don't depend on it.
+//
+// Echo's own Context.RealIP reads X-Forwarded-For and X-Real-IP from any
+// client unless an IPExtractor is configured, so its result can be spoofed.
+// The middleware follows the selection logic of realip instead, and with an
+// Extractor created with realip.NewExtractor or realip.NewSecure, only trusts
+// the headers of requests relayed by an allowlist of trusted proxies.
+package realipecho
+
+import (
+	"github.com/labstack/echo/v4"
+	"go.ajitem.com/realip"
+)
+
+// ContextKey is the key of the client address in the Echo context.
+const ContextKey = "real_ip"
+
+// Middleware returns an Echo middleware resolving the client address of
+// every request like realip.FromRequest, and setting it into the Echo context
+// under ContextKey, where RealIP retrieves it.
+func Middleware() echo.MiddlewareFunc {
+	return ExtractorMiddleware(nil)
+}
+
+// ExtractorMiddleware is like Middleware, but resolves the client address
+// with e. A nil Extractor resolves it like realip.FromRequest.
+func ExtractorMiddleware(e *realip.Extractor) echo.MiddlewareFunc {
+	resolve := realip.FromRequest
+	if e != nil {
+		resolve = e.FromRequest
+	}
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			c.Set(ContextKey, resolve(c.Request()))
+			return next(c)
+		}
+	}
+}
+
+// RealIP returns the client address set by the middleware, or resolves it
+// like realip.FromRequest if the middleware did not handle the request.
+func RealIP(c echo.Context) string {
+	if ip, ok := c.Get(ContextKey).(string); ok {
+		return ip
+	}
+
+	return realip.FromRequest(c.Request())
+}
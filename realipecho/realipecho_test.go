@@ -0,0 +1,54 @@
+package realipecho
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"go.ajitem.com/realip"
+)
+
+func TestMiddleware(t *testing.T) {
+	_, proxies, _ := net.ParseCIDR("10.0.0.0/8")
+
+	testData := []struct {
+		name       string
+		middleware echo.MiddlewareFunc
+		expected   string
+	}{
+		{name: "Default", middleware: Middleware(), expected: "1.2.3.4"},
+		{name: "Nil extractor", middleware: ExtractorMiddleware(nil), expected: "1.2.3.4"},
+		{name: "Extractor", middleware: ExtractorMiddleware(realip.NewExtractor([]*net.IPNet{proxies})), expected: "144.12.54.87"},
+	}
+
+	for _, v := range testData {
+		var actual string
+		e := echo.New()
+		e.Use(v.middleware)
+		e.GET("/", func(c echo.Context) error {
+			actual = RealIP(c)
+			return nil
+		})
+
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.RemoteAddr = "10.0.0.1:8080"
+		r.Header.Set("X-Forwarded-For", "1.2.3.4, 144.12.54.87")
+		e.ServeHTTP(httptest.NewRecorder(), r)
+
+		if actual != v.expected {
+			t.Errorf("%s: expected %s but get %s", v.name, v.expected, actual)
+		}
+	}
+}
+
+func TestRealIPWithoutMiddleware(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.RemoteAddr = "144.12.54.87:8080"
+	c := echo.New().NewContext(r, httptest.NewRecorder())
+
+	if actual := RealIP(c); actual != "144.12.54.87" {
+		t.Errorf("expected %s but get %s", "144.12.54.87", actual)
+	}
+}
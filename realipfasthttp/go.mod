@@ -0,0 +1,14 @@
+module go.ajitem.com/realip/realipfasthttp
+
+go 1.18
+
+require (
+	github.com/valyala/fasthttp v1.44.0
+	go.ajitem.com/realip v0.0.0-20261014061242-fbf6fd597e66
+)
+
+require (
+	github.com/andybalholm/brotli v1.0.4 // indirect
+	github.com/klauspost/compress v1.15.9 // indirect
+	github.com/valyala/bytebufferpool v1.0.0 // indirect
+)
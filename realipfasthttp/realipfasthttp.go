@@ -0,0 +1,44 @@
+// Package realipfasthttp resolves the client address of fasthttp requests,
+// which do not use http.Request, with the selection logic of realip. It
+// lives in its own package so that applications not using fasthttp don't
+// depend on it.
+package realipfasthttp
+
+import (
+	"github.com/valyala/fasthttp"
+	"go.ajitem.com/realip"
+)
+
+// FromFastHTTP returns the client address realip.FromRequest would return for
+// the equivalent http request.
+func FromFastHTTP(ctx *fasthttp.RequestCtx) string {
+	return ExtractorFromFastHTTP(nil, ctx)
+}
+
+// ExtractorFromFastHTTP is like FromFastHTTP, but resolves the client address
+// with e. A nil Extractor resolves it like realip.FromRequest.
+func ExtractorFromFastHTTP(e *realip.Extractor, ctx *fasthttp.RequestCtx) string {
+	fromHeaders := realip.FromHeaders
+	if e != nil {
+		fromHeaders = e.FromHeaders
+	}
+
+	return fromHeaders(nil, func(name string) []string {
+		return values(&ctx.Request.Header, name)
+	}, ctx.RemoteAddr().String())
+}
+
+// values returns every value of the named request header.
+func values(header *fasthttp.RequestHeader, name string) []string {
+	all := header.PeekAll(name)
+	if len(all) == 0 {
+		return nil
+	}
+
+	v := make([]string, len(all))
+	for i, value := range all {
+		v[i] = string(value)
+	}
+
+	return v
+}
@@ -0,0 +1,64 @@
+package realipfasthttp
+
+import (
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/valyala/fasthttp"
+	"go.ajitem.com/realip"
+)
+
+func TestFromFastHTTP(t *testing.T) {
+	testData := []struct {
+		name       string
+		remoteAddr *net.TCPAddr
+		header     http.Header
+	}{
+		{name: "Remote address", remoteAddr: &net.TCPAddr{IP: net.ParseIP("144.12.54.87"), Port: 8080}, header: http.Header{}},
+		{name: "IPv6 remote address", remoteAddr: &net.TCPAddr{IP: net.ParseIP("2a00:1450::1"), Port: 8080}, header: http.Header{}},
+		{name: "X-Forwarded-For", remoteAddr: &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 8080}, header: http.Header{
+			"X-Forwarded-For": {"10.0.0.3, 144.12.54.87"},
+		}},
+		{name: "Several lines", remoteAddr: &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 8080}, header: http.Header{
+			"X-Forwarded-For": {"10.0.0.3", "144.12.54.87"},
+		}},
+		{name: "IPv6 with port", remoteAddr: &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 8080}, header: http.Header{
+			"Forwarded": {`for="[2a00:1450::1]:4711"`},
+		}},
+		{name: "X-Real-IP with port", remoteAddr: &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 8080}, header: http.Header{
+			"X-Real-Ip": {"[2a00:1450::1]:4711"},
+		}},
+	}
+
+	for _, v := range testData {
+		var ctx fasthttp.RequestCtx
+		ctx.Init(&fasthttp.Request{}, v.remoteAddr, nil)
+		for name, lines := range v.header {
+			for _, line := range lines {
+				ctx.Request.Header.Add(name, line)
+			}
+		}
+
+		r := &http.Request{RemoteAddr: v.remoteAddr.String(), Header: v.header}
+		if expected, actual := realip.FromRequest(r), FromFastHTTP(&ctx); actual != expected {
+			t.Errorf("%s: expected %s but get %s", v.name, expected, actual)
+		}
+	}
+}
+
+func TestExtractorFromFastHTTP(t *testing.T) {
+	_, proxies, _ := net.ParseCIDR("10.0.0.0/8")
+	e := realip.NewExtractor([]*net.IPNet{proxies})
+
+	var ctx fasthttp.RequestCtx
+	ctx.Init(&fasthttp.Request{}, &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 8080}, nil)
+	ctx.Request.Header.Set("X-Forwarded-For", "1.2.3.4, 144.12.54.87")
+
+	if actual := ExtractorFromFastHTTP(e, &ctx); actual != "144.12.54.87" {
+		t.Errorf("expected %s but get %s", "144.12.54.87", actual)
+	}
+	if actual := ExtractorFromFastHTTP(nil, &ctx); actual != "1.2.3.4" {
+		t.Errorf("nil extractor: expected %s but get %s", "1.2.3.4", actual)
+	}
+}
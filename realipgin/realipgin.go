@@ -0,0 +1,45 @@
+// Package realipgin provides a Gin middleware setting the client address
+// resolved by realip into the Gin context. It lives in its own package so
+// that applications not using Gin don't depend on it.
+package realipgin
+
+import (
+	"github.com/gin-gonic/gin"
+	"go.ajitem.com/realip"
+)
+
+// ContextKey is the key of the client address in the Gin context.
+const ContextKey = "real_ip"
+
+// Middleware returns a Gin middleware resolving the client address of every
+// request like realip.FromRequest, and setting it into the Gin context under
+// ContextKey, where RealIP retrieves it.
+func Middleware() gin.HandlerFunc {
+	return ExtractorMiddleware(nil)
+}
+
+// ExtractorMiddleware is like Middleware, but resolves the client address
+// with e. A nil Extractor resolves it like realip.FromRequest.
+func ExtractorMiddleware(e *realip.Extractor) gin.HandlerFunc {
+	resolve := realip.FromRequest
+	if e != nil {
+		resolve = e.FromRequest
+	}
+
+	return func(c *gin.Context) {
+		c.Set(ContextKey, resolve(c.Request))
+		c.Next()
+	}
+}
+
+// RealIP returns the client address set by the middleware, or resolves it
+// like realip.FromRequest if the middleware did not handle the request.
+func RealIP(c *gin.Context) string {
+	if ip, ok := c.Get(ContextKey); ok {
+		if ip, ok := ip.(string); ok {
+			return ip
+		}
+	}
+
+	return realip.FromRequest(c.Request)
+}
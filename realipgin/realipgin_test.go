@@ -0,0 +1,59 @@
+package realipgin
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"go.ajitem.com/realip"
+)
+
+func TestMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	_, proxies, _ := net.ParseCIDR("10.0.0.0/8")
+
+	testData := []struct {
+		name       string
+		middleware gin.HandlerFunc
+		expected   string
+	}{
+		{name: "Default", middleware: Middleware(), expected: "1.2.3.4"},
+		{name: "Nil extractor", middleware: ExtractorMiddleware(nil), expected: "1.2.3.4"},
+		{name: "Extractor", middleware: ExtractorMiddleware(realip.NewExtractor([]*net.IPNet{proxies})), expected: "144.12.54.87"},
+	}
+
+	for _, v := range testData {
+		var actual string
+		router := gin.New()
+		router.Use(v.middleware)
+		router.GET("/", func(c *gin.Context) {
+			actual = RealIP(c)
+		})
+
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.RemoteAddr = "10.0.0.1:8080"
+		r.Header.Set("X-Forwarded-For", "1.2.3.4, 144.12.54.87")
+		router.ServeHTTP(httptest.NewRecorder(), r)
+
+		if actual != v.expected {
+			t.Errorf("%s: expected %s but get %s", v.name, v.expected, actual)
+		}
+		if v.name == "Default" && actual != realip.FromRequest(r) {
+			t.Errorf("%s: expected the result of FromRequest %s but get %s", v.name, realip.FromRequest(r), actual)
+		}
+	}
+}
+
+func TestRealIPWithoutMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+	c.Request.RemoteAddr = "144.12.54.87:8080"
+
+	if actual := RealIP(c); actual != "144.12.54.87" {
+		t.Errorf("expected %s but get %s", "144.12.54.87", actual)
+	}
+}
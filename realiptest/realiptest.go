@@ -0,0 +1,25 @@
+// Package realiptest provides helpers for testing code that depends on
+// realip, such as middleware resolving client addresses.
+package realiptest
+
+import (
+	"net/http"
+	"net/http/httptest"
+)
+
+// NewRequest returns a GET request for "/", as a server would receive it
+// from remoteAddr, e.g. "10.0.0.1:8080", carrying the given headers. Every
+// value of a header is added with http.Header.Add, in order, so a header
+// with several values is sent as several lines, and names are canonicalized,
+// e.g. X-Forwarded-For for x-forwarded-for.
+func NewRequest(remoteAddr string, headers map[string][]string) *http.Request {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.RemoteAddr = remoteAddr
+	for name, values := range headers {
+		for _, value := range values {
+			r.Header.Add(name, value)
+		}
+	}
+
+	return r
+}
@@ -0,0 +1,29 @@
+package realiptest
+
+import (
+	"reflect"
+	"testing"
+
+	"go.ajitem.com/realip"
+)
+
+func TestNewRequest(t *testing.T) {
+	r := NewRequest("10.0.0.1:8080", map[string][]string{
+		"x-forwarded-for": {"1.2.3.4", "144.12.54.87"},
+		"X-Real-Ip":       {"119.14.55.11"},
+	})
+
+	if r.RemoteAddr != "10.0.0.1:8080" {
+		t.Errorf("RemoteAddr: expected %s but get %s", "10.0.0.1:8080", r.RemoteAddr)
+	}
+	if actual := r.Header["X-Forwarded-For"]; !reflect.DeepEqual(actual, []string{"1.2.3.4", "144.12.54.87"}) {
+		t.Errorf("X-Forwarded-For: expected %v but get %v", []string{"1.2.3.4", "144.12.54.87"}, actual)
+	}
+	if actual := realip.FromRequest(r); actual != "1.2.3.4" {
+		t.Errorf("FromRequest: expected %s but get %s", "1.2.3.4", actual)
+	}
+
+	if r := NewRequest("", nil); r.RemoteAddr != "" || len(r.Header) != 0 {
+		t.Errorf("empty: expected no remote address and headers but get %q and %v", r.RemoteAddr, r.Header)
+	}
+}
@@ -0,0 +1,124 @@
+package realip
+
+import "net/http"
+
+// Reason identifies how the client address of a request was resolved.
+type Reason int
+
+const (
+	// ReasonNoCandidate means no usable client address was found.
+	ReasonNoCandidate Reason = iota
+
+	// ReasonRemoteAddr means the address of the peer of the connection was
+	// used, because the request carries no usable forwarding header or the
+	// peer is not a trusted proxy.
+	ReasonRemoteAddr
+
+	// ReasonFirstPublicXFF means the first public address of a list header,
+	// like X-Forwarded-For, was used.
+	ReasonFirstPublicXFF
+
+	// ReasonRightmostUntrusted means the forwarding headers were walked from
+	// the right, skipping trusted proxies or hops.
+	ReasonRightmostUntrusted
+
+	// ReasonForwardedFor means the first public for address of the
+	// Forwarded header was used.
+	ReasonForwardedFor
+
+	// ReasonXRealIPFallback means X-Real-IP, or the header set with
+	// WithFallbackHeader, was used because no list or Forwarded header
+	// yielded an address.
+	ReasonXRealIPFallback
+
+	// ReasonSingleHeader means another single address header of the header
+	// chain was used, like Cf-Connecting-Ip.
+	ReasonSingleHeader
+
+	// ReasonPreferredRange means an address within the preferred client
+	// ranges was used.
+	ReasonPreferredRange
+
+	// ReasonClientRange means an address within the client ranges was used.
+	ReasonClientRange
+
+	// ReasonRightmostNonPrivate means the rightmost public address of a list
+	// or Forwarded header was used, following PolicyRightmostNonPrivate.
+	ReasonRightmostNonPrivate
+
+	// ReasonLeftmostPrivate means the forwarding headers only held private
+	// addresses, and the leftmost one was used following ReturnLeftmost.
+	ReasonLeftmostPrivate
+)
+
+// String returns the name of the reason.
+func (r Reason) String() string {
+	switch r {
+	case ReasonNoCandidate:
+		return "no-candidate"
+	case ReasonRemoteAddr:
+		return "remote-addr"
+	case ReasonFirstPublicXFF:
+		return "first-public-xff"
+	case ReasonRightmostUntrusted:
+		return "rightmost-untrusted"
+	case ReasonForwardedFor:
+		return "forwarded-for"
+	case ReasonXRealIPFallback:
+		return "x-real-ip-fallback"
+	case ReasonSingleHeader:
+		return "single-header"
+	case ReasonPreferredRange:
+		return "preferred-range"
+	case ReasonClientRange:
+		return "client-range"
+	case ReasonRightmostNonPrivate:
+		return "rightmost-non-private"
+	case ReasonLeftmostPrivate:
+		return "leftmost-private"
+	default:
+		return "unknown"
+	}
+}
+
+// ResolveWithReason returns the client address FromRequest would return,
+// along with the reason it was chosen, for middleware to switch on. The
+// reason is ReasonNoCandidate whenever the address is empty.
+func (e *Extractor) ResolveWithReason(r *http.Request) (ip string, reason Reason) {
+	ip, reason, _ = e.resolveReason(r)
+	ip = e.finish(e.accept(ip))
+	if ip == "" || e.checkNoPort(ip) != nil {
+		return "", ReasonNoCandidate
+	}
+
+	return ip, reason
+}
+
+// singleReason returns the reason of a result read from the single address
+// header described by spec.
+func (e *Extractor) singleReason(spec HeaderSpec) Reason {
+	if spec.Name == e.fallbackHeader {
+		return ReasonXRealIPFallback
+	}
+
+	return ReasonSingleHeader
+}
+
+// walkReason returns the reason of a result found walking the list or
+// Forwarded header described by spec for a public address.
+func (e *Extractor) walkReason(spec HeaderSpec) Reason {
+	if e.policy == PolicyRightmostNonPrivate {
+		return ReasonRightmostNonPrivate
+	}
+	if spec.Kind == HeaderForwarded {
+		return ReasonForwardedFor
+	}
+
+	return ReasonFirstPublicXFF
+}
+
+// ResolveWithReason returns the client address FromRequest would return,
+// along with the reason it was chosen.
+func ResolveWithReason(r *http.Request) (ip string, reason Reason) {
+	return defaultExtractor.ResolveWithReason(r)
+}
@@ -0,0 +1,47 @@
+package realip
+
+import (
+	"net"
+	"net/http"
+	"testing"
+)
+
+func TestResolveWithReason(t *testing.T) {
+	_, proxies, _ := net.ParseCIDR("10.0.0.0/8")
+	_, preferred, _ := net.ParseCIDR("203.0.113.0/24")
+
+	plain := New()
+	trusted := New(WithTrustedProxies(proxies))
+
+	testData := []struct {
+		name       string
+		extractor  *Extractor
+		remoteAddr string
+		header     http.Header
+		expected   string
+		reason     Reason
+	}{
+		{name: "No headers", extractor: plain, remoteAddr: "144.12.54.87:80", header: http.Header{}, expected: "144.12.54.87", reason: ReasonRemoteAddr},
+		{name: "X-Forwarded-For", extractor: plain, remoteAddr: "10.0.0.1:80", header: http.Header{"X-Forwarded-For": {"192.168.1.1, 144.12.54.87"}}, expected: "144.12.54.87", reason: ReasonFirstPublicXFF},
+		{name: "Forwarded", extractor: plain, remoteAddr: "10.0.0.1:80", header: http.Header{"Forwarded": {"for=144.12.54.87"}}, expected: "144.12.54.87", reason: ReasonForwardedFor},
+		{name: "X-Real-IP", extractor: plain, remoteAddr: "10.0.0.1:80", header: http.Header{"X-Forwarded-For": {"192.168.1.1"}, "X-Real-Ip": {"144.12.54.87"}}, expected: "144.12.54.87", reason: ReasonXRealIPFallback},
+		{name: "Vendor header", extractor: New(WithHeader(CFConnectingIPHeader, HeaderSingle)), remoteAddr: "10.0.0.1:80", header: http.Header{"Cf-Connecting-Ip": {"144.12.54.87"}}, expected: "144.12.54.87", reason: ReasonSingleHeader},
+		{name: "No candidate", extractor: plain, remoteAddr: "10.0.0.1:80", header: http.Header{"X-Forwarded-For": {"192.168.1.1"}}, expected: "", reason: ReasonNoCandidate},
+		{name: "Preferred range", extractor: New(WithPreferredClientRanges([]*net.IPNet{preferred})), remoteAddr: "10.0.0.1:80", header: http.Header{"X-Forwarded-For": {"144.12.54.87, 203.0.113.7"}}, expected: "203.0.113.7", reason: ReasonPreferredRange},
+		{name: "Client range", extractor: New(WithClientRanges([]*net.IPNet{preferred})), remoteAddr: "10.0.0.1:80", header: http.Header{"X-Forwarded-For": {"203.0.113.7, 144.12.54.87"}}, expected: "203.0.113.7", reason: ReasonClientRange},
+		{name: "Trusted proxy", extractor: trusted, remoteAddr: "10.0.0.1:80", header: http.Header{"X-Forwarded-For": {"1.1.1.1, 144.12.54.87, 10.0.0.2"}}, expected: "144.12.54.87", reason: ReasonRightmostUntrusted},
+		{name: "Untrusted peer", extractor: trusted, remoteAddr: "144.12.54.87:80", header: http.Header{"X-Forwarded-For": {"1.1.1.1"}}, expected: "144.12.54.87", reason: ReasonRemoteAddr},
+		{name: "Trusted X-Real-IP", extractor: trusted, remoteAddr: "10.0.0.1:80", header: http.Header{"X-Real-Ip": {"144.12.54.87"}}, expected: "144.12.54.87", reason: ReasonXRealIPFallback},
+	}
+
+	for _, v := range testData {
+		r := &http.Request{RemoteAddr: v.remoteAddr, Header: v.header}
+		actual, reason := v.extractor.ResolveWithReason(r)
+		if actual != v.expected {
+			t.Errorf("%s: expected %s but get %s", v.name, v.expected, actual)
+		}
+		if reason != v.reason {
+			t.Errorf("%s: expected %s but get %s", v.name, v.reason, reason)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package realip
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"strconv"
+	"strings"
+)
+
+// NormalizeRemoteAddr parses a remote address as found in
+// http.Request.RemoteAddr. It accepts host:port, [host]:port, [host] and bare
+// IPv4 or IPv6 addresses, and returns the IP and the port, which is zero
+// when the address has none. ok is false for malformed addresses and for
+// unix socket addresses such as "@" or a file path.
+func NormalizeRemoteAddr(addr string) (ip string, port int, ok bool) {
+	ip, port, err := parseRemoteAddr(addr)
+	return ip, port, err == nil
+}
+
+// parseRemoteAddr implements NormalizeRemoteAddr, returning an error
+// describing why a malformed address could not be parsed.
+func parseRemoteAddr(addr string) (ip string, port int, err error) {
+	addr = strings.TrimSpace(addr)
+
+	// A bare address, including IPv6 without brackets
+	if a, err := netip.ParseAddr(addr); err == nil {
+		return a.String(), 0, nil
+	}
+
+	// A bracketed IPv6 address without a port
+	if strings.HasPrefix(addr, "[") && strings.HasSuffix(addr, "]") {
+		a, err := netip.ParseAddr(addr[1 : len(addr)-1])
+		if err != nil {
+			return "", 0, err
+		}
+		if !a.Is6() {
+			return "", 0, fmt.Errorf("bracketed address %s is not IPv6", a)
+		}
+		return a.String(), 0, nil
+	}
+
+	host, p, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "", 0, err
+	}
+
+	a, err := netip.ParseAddr(host)
+	if err != nil {
+		return "", 0, err
+	}
+
+	port, err = strconv.Atoi(p)
+	if err != nil {
+		return "", 0, err
+	}
+	if port < 0 || port > 65535 {
+		return "", 0, fmt.Errorf("port %d out of range", port)
+	}
+
+	return a.String(), port, nil
+}
+
+// isUnixSocketAddr reports whether addr is the remote address of a unix
+// socket connection: empty, "@", an abstract socket name or a file path.
+func isUnixSocketAddr(addr string) bool {
+	return addr == "" || strings.HasPrefix(addr, "@") || strings.HasPrefix(addr, "/")
+}
+
+// remoteIP returns the IP of the request's remote address without the port
+// number, or an empty string if it can't be parsed.
+func remoteIP(in input) string {
+	ip, _, _ := NormalizeRemoteAddr(in.remoteAddr)
+	return ip
+}
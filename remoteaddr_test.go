@@ -0,0 +1,61 @@
+package realip
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestNormalizeRemoteAddr(t *testing.T) {
+	testData := []struct {
+		addr string
+		ip   string
+		port int
+		ok   bool
+	}{
+		{addr: "192.0.2.1:80", ip: "192.0.2.1", port: 80, ok: true},
+		{addr: "192.0.2.1", ip: "192.0.2.1", ok: true},
+		{addr: "[2001:db8::1]:443", ip: "2001:db8::1", port: 443, ok: true},
+		{addr: "[2001:db8::1]", ip: "2001:db8::1", ok: true},
+		{addr: "2001:db8::1", ip: "2001:db8::1", ok: true},
+		{addr: "[fe80::1%eth0]:80", ip: "fe80::1%eth0", port: 80, ok: true},
+
+		// unix sockets
+		{addr: ""},
+		{addr: "@"},
+		{addr: "/var/run/app.sock"},
+
+		// malformed
+		{addr: "192.0.2.1:"},
+		{addr: "192.0.2.1:http"},
+		{addr: "192.0.2.1:70000"},
+		{addr: "[192.0.2.1]"},
+		{addr: "[2001:db8::1"},
+		{addr: "example.com:80"},
+		{addr: "1:2:3"},
+	}
+
+	for _, v := range testData {
+		ip, port, ok := NormalizeRemoteAddr(v.addr)
+		if ip != v.ip || port != v.port || ok != v.ok {
+			t.Errorf("%q: expected (%s, %d, %t) but get (%s, %d, %t)", v.addr, v.ip, v.port, v.ok, ip, port, ok)
+		}
+	}
+}
+
+func TestFromRequestRemoteAddr(t *testing.T) {
+	testData := map[string]string{
+		"2001:db8::1":       "2001:db8::1",
+		"[2001:db8::1]:443": "2001:db8::1",
+		"192.0.2.1:80":      "192.0.2.1",
+	}
+
+	for addr, expected := range testData {
+		r := &http.Request{RemoteAddr: addr, Header: http.Header{}}
+		if actual := FromRequest(r); actual != expected {
+			t.Errorf("%s: expected %s but get %s", addr, expected, actual)
+		}
+		if actual, err := FromRequestE(r); actual != expected || err != nil {
+			t.Errorf("%s: FromRequestE expected %s but get %s (%v)", addr, expected, actual, err)
+		}
+	}
+}
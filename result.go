@@ -0,0 +1,87 @@
+package realip
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// Result is the client address of a request, along with where it was found.
+type Result struct {
+	IP     string
+	Source Source
+
+	// Trusted reports whether the address is the peer of the connection or
+	// was recorded by trusted proxies, as ranked by RankedCandidates. An
+	// address found in a header that is not trusted may have been chosen
+	// by the client.
+	Trusted bool
+
+	// Chain holds the addresses of the X-Forwarded-For and Forwarded
+	// headers, as returned by ChainFromRequest.
+	Chain []string
+
+	// RejectedPrefix holds the addresses the client claimed to the left of
+	// the address resolved from trusted proxies, in header order, which were
+	// skipped as client-controlled. It is nil when nothing was skipped.
+	RejectedPrefix []string
+}
+
+// MarshalJSON encodes the result as a single object for structured logs,
+// e.g. {"ip":"203.0.113.9","source":"x-forwarded-for","trusted":false}. The
+// chain and the rejected prefix are omitted when empty.
+func (res Result) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		IP             string   `json:"ip"`
+		Source         string   `json:"source"`
+		Trusted        bool     `json:"trusted"`
+		Chain          []string `json:"chain,omitempty"`
+		RejectedPrefix []string `json:"rejected_prefix,omitempty"`
+	}{res.IP, res.Source.String(), res.Trusted, res.Chain, res.RejectedPrefix})
+}
+
+// FromRequestDetailed returns the client address FromRequest would return,
+// along with its source, whether it is trusted, the forwarding chain and the
+// addresses skipped as client-controlled, e.g. to flag requests from a public
+// peer claiming another public address in their headers. The zero Result is
+// returned when no address is found.
+func (e *Extractor) FromRequestDetailed(r *http.Request) Result {
+	ip, reason, _ := e.resolveReason(r)
+	ip = e.accept(ip)
+	if ip == "" {
+		return Result{}
+	}
+
+	result := e.source(requestInput(r), ip, reason)
+	result.IP = e.finish(ip)
+	if result.IP == "" || e.checkNoPort(result.IP) != nil {
+		return Result{}
+	}
+	result.Chain = e.ChainFromRequest(r)
+	if reason == ReasonRightmostUntrusted {
+		result.RejectedPrefix = e.rejectedPrefix(requestInput(r), ip)
+	}
+
+	return result
+}
+
+// source returns the source of ip, resolved for reason, and whether it is
+// trusted.
+func (e *Extractor) source(in input, ip string, reason Reason) Result {
+	if reason == ReasonRemoteAddr {
+		return Result{Source: SourceRemoteAddr, Trusted: true}
+	}
+
+	for _, candidate := range e.rankedCandidates(in) {
+		if candidate.Source != SourceRemoteAddr && candidate.IP == ip {
+			return Result{Source: candidate.Source, Trusted: candidate.Trusted}
+		}
+	}
+
+	return Result{Source: SourceHeader, Trusted: e.vouches(remoteIP(in), 0)}
+}
+
+// FromRequestDetailed returns the client address FromRequest would return,
+// along with its source, whether it is trusted and the forwarding chain.
+func FromRequestDetailed(r *http.Request) Result {
+	return defaultExtractor.FromRequestDetailed(r)
+}
@@ -0,0 +1,83 @@
+package realip
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestFromRequestDetailed(t *testing.T) {
+	_, proxies, _ := net.ParseCIDR("10.0.0.0/8")
+	plain := New()
+	trusted := NewExtractor([]*net.IPNet{proxies})
+
+	testData := []struct {
+		name       string
+		extractor  *Extractor
+		remoteAddr string
+		header     http.Header
+		expected   Result
+	}{
+		{name: "Remote address", extractor: plain, remoteAddr: "144.12.54.87:80", header: http.Header{}, expected: Result{IP: "144.12.54.87", Source: SourceRemoteAddr, Trusted: true}},
+		{name: "Public peer claiming", extractor: plain, remoteAddr: "119.14.55.11:80", header: http.Header{"X-Forwarded-For": {"144.12.54.87"}}, expected: Result{IP: "144.12.54.87", Source: SourceXForwardedFor, Chain: []string{"144.12.54.87"}}},
+		{name: "Forwarded", extractor: plain, remoteAddr: "10.0.0.1:80", header: http.Header{"Forwarded": {"for=144.12.54.87"}}, expected: Result{IP: "144.12.54.87", Source: SourceForwarded, Chain: []string{"144.12.54.87"}}},
+		{name: "X-Real-IP", extractor: plain, remoteAddr: "10.0.0.1:80", header: http.Header{"X-Real-Ip": {"144.12.54.87"}}, expected: Result{IP: "144.12.54.87", Source: SourceXRealIP}},
+		{name: "Other header", extractor: New(WithHeaders(XClientIPHeader)), remoteAddr: "10.0.0.1:80", header: http.Header{"X-Client-Ip": {"144.12.54.87"}}, expected: Result{IP: "144.12.54.87", Source: SourceHeader}},
+		{name: "Trusted proxy", extractor: trusted, remoteAddr: "10.0.0.1:80", header: http.Header{"X-Forwarded-For": {"1.2.3.4, 144.12.54.87"}}, expected: Result{IP: "144.12.54.87", Source: SourceXForwardedFor, Trusted: true, Chain: []string{"1.2.3.4", "144.12.54.87"}, RejectedPrefix: []string{"1.2.3.4"}}},
+		{name: "Trusted proxies", extractor: trusted, remoteAddr: "10.0.0.1:80", header: http.Header{"X-Forwarded-For": {"1.2.3.4, 5.6.7.8, 203.0.113.9, 10.0.0.2"}}, expected: Result{IP: "203.0.113.9", Source: SourceXForwardedFor, Trusted: true, Chain: []string{"1.2.3.4", "5.6.7.8", "203.0.113.9", "10.0.0.2"}, RejectedPrefix: []string{"1.2.3.4", "5.6.7.8"}}},
+		{name: "Nothing rejected", extractor: trusted, remoteAddr: "10.0.0.1:80", header: http.Header{"X-Forwarded-For": {"unknown, 144.12.54.87"}}, expected: Result{IP: "144.12.54.87", Source: SourceXForwardedFor, Trusted: true, Chain: []string{"144.12.54.87"}}},
+		{name: "Trusted X-Real-IP", extractor: trusted, remoteAddr: "10.0.0.1:80", header: http.Header{"X-Real-Ip": {"144.12.54.87"}}, expected: Result{IP: "144.12.54.87", Source: SourceXRealIP, Trusted: true}},
+		{name: "Untrusted peer", extractor: trusted, remoteAddr: "119.14.55.11:80", header: http.Header{"X-Forwarded-For": {"144.12.54.87"}}, expected: Result{IP: "119.14.55.11", Source: SourceRemoteAddr, Trusted: true, Chain: []string{"144.12.54.87"}}},
+		{name: "No address", extractor: plain, remoteAddr: "10.0.0.1:80", header: http.Header{"X-Forwarded-For": {"192.168.0.1"}}, expected: Result{}},
+	}
+
+	for _, v := range testData {
+		r := &http.Request{RemoteAddr: v.remoteAddr, Header: v.header}
+		if actual := v.extractor.FromRequestDetailed(r); !reflect.DeepEqual(actual, v.expected) {
+			t.Errorf("%s: expected %+v but get %+v", v.name, v.expected, actual)
+		}
+	}
+
+	r := &http.Request{RemoteAddr: "119.14.55.11:80", Header: http.Header{"X-Forwarded-For": {"144.12.54.87"}}}
+	if actual := FromRequestDetailed(r); actual.Source != SourceXForwardedFor || actual.Trusted {
+		t.Errorf("package FromRequestDetailed: expected untrusted %s but get %+v", SourceXForwardedFor, actual)
+	}
+}
+
+func TestResultMarshalJSON(t *testing.T) {
+	testData := []struct {
+		name     string
+		result   Result
+		expected string
+	}{
+		{
+			name:     "With chain",
+			result:   Result{IP: "203.0.113.9", Source: SourceXForwardedFor, Chain: []string{"203.0.113.9", "10.0.0.2"}},
+			expected: `{"ip":"203.0.113.9","source":"x-forwarded-for","trusted":false,"chain":["203.0.113.9","10.0.0.2"]}`,
+		}, {
+			name:     "With rejected prefix",
+			result:   Result{IP: "203.0.113.9", Source: SourceXForwardedFor, Trusted: true, Chain: []string{"1.2.3.4", "203.0.113.9"}, RejectedPrefix: []string{"1.2.3.4"}},
+			expected: `{"ip":"203.0.113.9","source":"x-forwarded-for","trusted":true,"chain":["1.2.3.4","203.0.113.9"],"rejected_prefix":["1.2.3.4"]}`,
+		}, {
+			name:     "Without chain",
+			result:   Result{IP: "144.12.54.87", Source: SourceRemoteAddr, Trusted: true},
+			expected: `{"ip":"144.12.54.87","source":"remote-addr","trusted":true}`,
+		}, {
+			name:     "Empty chain",
+			result:   Result{IP: "144.12.54.87", Source: SourceRemoteAddr, Trusted: true, Chain: []string{}},
+			expected: `{"ip":"144.12.54.87","source":"remote-addr","trusted":true}`,
+		},
+	}
+
+	for _, v := range testData {
+		actual, err := json.Marshal(v.result)
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", v.name, err)
+		}
+		if string(actual) != v.expected {
+			t.Errorf("%s: expected %s but get %s", v.name, v.expected, actual)
+		}
+	}
+}
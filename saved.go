@@ -0,0 +1,23 @@
+package realip
+
+import "net/http"
+
+// FromSavedHeaders returns client's real public IP address from a flat,
+// single value header map, as stored in HAR files and access logs, and the
+// remote address the request was received from. Header names are
+// canonicalized, so their case doesn't matter.
+func (e *Extractor) FromSavedHeaders(headers map[string]string, remoteAddr string) string {
+	h := make(http.Header, len(headers))
+	for name, value := range headers {
+		h[http.CanonicalHeaderKey(name)] = []string{value}
+	}
+
+	return e.FromRequest(&http.Request{RemoteAddr: remoteAddr, Header: h})
+}
+
+// FromSavedHeaders returns client's real public IP address from a flat,
+// single value header map, as stored in HAR files and access logs, and the
+// remote address the request was received from.
+func FromSavedHeaders(headers map[string]string, remoteAddr string) string {
+	return defaultExtractor.FromSavedHeaders(headers, remoteAddr)
+}
@@ -0,0 +1,40 @@
+package realip
+
+import "testing"
+
+func TestFromSavedHeaders(t *testing.T) {
+	testData := []struct {
+		name       string
+		headers    map[string]string
+		remoteAddr string
+		expected   string
+	}{
+		{
+			name:       "No header",
+			headers:    map[string]string{"user-agent": "curl/8.0"},
+			remoteAddr: "144.12.54.87:8080",
+			expected:   "144.12.54.87",
+		}, {
+			name:       "Lowercase X-Forwarded-For",
+			headers:    map[string]string{"x-forwarded-for": "10.0.0.1, 144.12.54.87"},
+			remoteAddr: "10.0.0.2:8080",
+			expected:   "144.12.54.87",
+		}, {
+			name:       "Mixed case Forwarded",
+			headers:    map[string]string{"FORWARDED": "for=119.14.55.11"},
+			remoteAddr: "10.0.0.2:8080",
+			expected:   "119.14.55.11",
+		}, {
+			name:       "X-Real-IP",
+			headers:    map[string]string{"x-real-ip": "13.182.55.11"},
+			remoteAddr: "10.0.0.2:8080",
+			expected:   "13.182.55.11",
+		},
+	}
+
+	for _, v := range testData {
+		if actual := FromSavedHeaders(v.headers, v.remoteAddr); actual != v.expected {
+			t.Errorf("%s: expected %s but get %s", v.name, v.expected, actual)
+		}
+	}
+}
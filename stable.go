@@ -0,0 +1,33 @@
+package realip
+
+import "net/http"
+
+// StableIPv6Prefix returns the prefix of the given length, /64 if bits is not
+// positive, of the client's IPv6 address, e.g. 2001:db8:1:2::/64. Unlike the
+// address itself, which rotates with IPv6 privacy extensions, the prefix is
+// stable per network, so it can be used to track clients. It is empty for
+// IPv4 clients and when no valid address could be determined.
+func (e *Extractor) StableIPv6Prefix(r *http.Request, bits int) string {
+	if bits <= 0 {
+		bits = 64
+	}
+
+	ip, _ := e.resolve(r)
+	addr, err := parseAddress(e.accept(ip))
+	if err != nil || !addr.Is6() {
+		return ""
+	}
+
+	prefix, err := addr.Prefix(bits)
+	if err != nil {
+		return ""
+	}
+
+	return prefix.String()
+}
+
+// StableIPv6Prefix returns the prefix of the given length, /64 if bits is not
+// positive, of the client's IPv6 address. It is empty for IPv4 clients.
+func StableIPv6Prefix(r *http.Request, bits int) string {
+	return defaultExtractor.StableIPv6Prefix(r, bits)
+}
@@ -0,0 +1,29 @@
+package realip
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestStableIPv6Prefix(t *testing.T) {
+	testData := []struct {
+		name     string
+		client   string
+		bits     int
+		expected string
+	}{
+		{name: "Privacy address", client: "2a00:1450:4001:81b:8d2c:1f3e:24a1:9c07", bits: 0, expected: "2a00:1450:4001:81b::/64"},
+		{name: "Rotated privacy address", client: "2a00:1450:4001:81b:51e0:aa13:7b2:e4d9", bits: 64, expected: "2a00:1450:4001:81b::/64"},
+		{name: "/56", client: "2a00:1450:4001:81b:51e0:aa13:7b2:e4d9", bits: 56, expected: "2a00:1450:4001:800::/56"},
+		{name: "Out of range", client: "2a00:1450:4001:81b:51e0:aa13:7b2:e4d9", bits: 129, expected: ""},
+		{name: "IPv4", client: "144.12.54.87", bits: 64, expected: ""},
+		{name: "IPv4-mapped", client: "::ffff:144.12.54.87", bits: 64, expected: ""},
+	}
+
+	for _, v := range testData {
+		r := &http.Request{RemoteAddr: "10.0.0.1:80", Header: http.Header{"X-Forwarded-For": {v.client}}}
+		if actual := StableIPv6Prefix(r, v.bits); actual != v.expected {
+			t.Errorf("%s: expected %s but get %s", v.name, v.expected, actual)
+		}
+	}
+}
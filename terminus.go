@@ -0,0 +1,36 @@
+package realip
+
+// chainTerminusVerified reports whether the last hop of every list and
+// Forwarded header of the request is the peer of the connection. It is
+// always true without VerifyChainTerminus, and when the peer is a trusted
+// proxy, which wrote the last hop itself. Headers the request does not carry
+// are not checked.
+func (e *Extractor) chainTerminusVerified(in input) bool {
+	if !e.verifyTerminus {
+		return true
+	}
+
+	peer := remoteIP(in)
+	if e.trustedSet && e.isTrusted(peer) {
+		return true
+	}
+
+	remote, remoteErr := parseAddress(peer)
+	for _, spec := range e.headerChain {
+		if spec.Kind == HeaderSingle {
+			continue
+		}
+
+		// Walking in reverse, the first address is the last hop
+		verified := true
+		e.scanHeader(in, spec, true, func(address string) bool {
+			verified = remoteErr == nil && sameAddress(address, remote)
+			return true
+		})
+		if !verified {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,98 @@
+package realip
+
+import (
+	"net"
+	"net/http"
+	"testing"
+)
+
+func TestVerifyChainTerminus(t *testing.T) {
+	_, trusted, _ := net.ParseCIDR("10.0.0.0/8")
+
+	testData := []struct {
+		name      string
+		extractor *Extractor
+		request   *http.Request
+		expected  string
+	}{
+		{
+			name:      "terminus is the peer",
+			extractor: New(VerifyChainTerminus()),
+			request: &http.Request{RemoteAddr: "119.14.55.11:8080", Header: http.Header{
+				"X-Forwarded-For": {"144.12.54.87, 119.14.55.11"},
+			}},
+			expected: "144.12.54.87",
+		},
+		{
+			name:      "trusted peer",
+			extractor: New(VerifyChainTerminus(), WithTrustedProxies(trusted)),
+			request: &http.Request{RemoteAddr: "10.0.0.2:8080", Header: http.Header{
+				"X-Forwarded-For": {"144.12.54.87, 10.0.0.1"},
+			}},
+			expected: "144.12.54.87",
+		},
+		{
+			name:      "public terminus",
+			extractor: New(VerifyChainTerminus()),
+			request: &http.Request{RemoteAddr: "10.0.0.2:8080", Header: http.Header{
+				"X-Forwarded-For": {"144.12.54.87, 119.14.55.11"},
+			}},
+			expected: "10.0.0.2",
+		},
+		{
+			name:      "client appended by a trusted peer",
+			extractor: New(VerifyChainTerminus(), WithTrustedProxies(trusted)),
+			request: &http.Request{RemoteAddr: "10.0.0.1:8080", Header: http.Header{
+				"X-Forwarded-For": {"8.8.8.8"},
+			}},
+			expected: "8.8.8.8",
+		},
+		{
+			name:      "public terminus from a trusted peer",
+			extractor: New(VerifyChainTerminus(), WithTrustedProxies(trusted)),
+			request: &http.Request{RemoteAddr: "10.0.0.2:8080", Header: http.Header{
+				"X-Forwarded-For": {"144.12.54.87, 10.0.0.1, 119.14.55.11"},
+			}},
+			expected: "119.14.55.11",
+		},
+		{
+			name:      "untrusted peer",
+			extractor: New(VerifyChainTerminus(), WithTrustedProxies(trusted)),
+			request: &http.Request{RemoteAddr: "119.14.55.11:8080", Header: http.Header{
+				"X-Forwarded-For": {"144.12.54.87, 10.0.0.1"},
+			}},
+			expected: "119.14.55.11",
+		},
+		{
+			name:      "public Forwarded terminus",
+			extractor: New(VerifyChainTerminus()),
+			request: &http.Request{RemoteAddr: "10.0.0.2:8080", Header: http.Header{
+				"X-Forwarded-For": {"144.12.54.87, 10.0.0.2"},
+				"Forwarded":       {"for=144.12.54.87, for=119.14.55.11"},
+			}},
+			expected: "10.0.0.2",
+		},
+		{
+			name:      "X-Real-IP only",
+			extractor: New(VerifyChainTerminus()),
+			request: &http.Request{RemoteAddr: "10.0.0.2:8080", Header: http.Header{
+				"X-Real-Ip": {"144.12.54.87"},
+			}},
+			expected: "144.12.54.87",
+		},
+		{
+			name:      "disabled",
+			extractor: New(),
+			request: &http.Request{RemoteAddr: "10.0.0.2:8080", Header: http.Header{
+				"X-Forwarded-For": {"144.12.54.87, 119.14.55.11"},
+			}},
+			expected: "144.12.54.87",
+		},
+	}
+
+	for _, v := range testData {
+		if actual := v.extractor.FromRequest(v.request); actual != v.expected {
+			t.Errorf("%s: expected %s but get %s", v.name, v.expected, actual)
+		}
+	}
+}
@@ -0,0 +1,311 @@
+package realip
+
+import "strings"
+
+// isTrusted reports whether address belongs to a trusted proxy.
+func (e *Extractor) isTrusted(address string) bool {
+	ip, err := parseAddress(address)
+	return err == nil && containsAddress(e.trusted, ip)
+}
+
+// isInternal reports whether address belongs to an internal proxy.
+func (e *Extractor) isInternal(address string) bool {
+	ip, err := parseAddress(address)
+	return err == nil && containsAddress(e.internal, ip)
+}
+
+// resolveClientRanges resolves the client address when client ranges are
+// configured, treating every address outside of them as a proxy. The peer is
+// the client if it lies within the client ranges, otherwise the forwarding
+// headers are walked from the closest proxy, and the first address within the
+// client ranges is the client. An empty string is returned if there is none.
+func (e *Extractor) resolveClientRanges(in input) (string, Reason) {
+	var client string
+	isClient := func(address string) bool {
+		if ip, err := parseAddress(address); err == nil && containsAddress(e.clients, ip) {
+			client = address
+			return true
+		}
+		return false
+	}
+
+	if isClient(remoteIP(in)) {
+		return client, ReasonRemoteAddr
+	}
+	for _, spec := range e.headerChain {
+		if e.walkSelected(in, spec, true, isClient) {
+			return client, ReasonClientRange
+		}
+	}
+
+	return "", ReasonNoCandidate
+}
+
+// resolveTrusted resolves the client address when trusted proxies or a
+// number of trusted hops are configured. The forwarding headers are only
+// considered when the peer is a trusted proxy, and are walked from the right,
+// skipping trusted proxies, so the result is the address the first trusted
+// proxy received the request from rather than whatever the client claims.
+func (e *Extractor) resolveTrusted(in input) (string, Reason, error) {
+	remote := remoteIP(in)
+	if e.trustedSet && !e.isTrusted(remote) {
+		e.audit(AuditUntrustedPeer, in, remote, ReasonRemoteAddr)
+		if !e.isPublic(remote) {
+			return remote, ReasonRemoteAddr, ErrUntrustedPeer
+		}
+		return remote, ReasonRemoteAddr, nil
+	}
+
+	client, reason, err := e.trustedClient(in)
+	if client == "" {
+		return remote, ReasonRemoteAddr, nil
+	}
+
+	return client, reason, err
+}
+
+// trustedClient returns the client address of the first header of the
+// header chain that yields one. The client addresses of the list and
+// Forwarded headers must agree, otherwise the first of them is returned
+// with ErrSpoofingDetected.
+func (e *Extractor) trustedClient(in input) (string, Reason, error) {
+	var client, listed string
+	reason := ReasonNoCandidate
+	for _, spec := range e.headerChain {
+		if spec.Kind == HeaderSingle {
+			if client == "" {
+				client, reason = e.single(in, spec), e.singleReason(spec)
+			}
+			continue
+		}
+
+		walker := e.reverseWalker(spec)
+		if e.trustBoundary {
+			walker = e.boundaryWalker(walker)
+		}
+		ip := e.clientFromChain(in, walker)
+		switch {
+		case ip == "":
+			continue
+		case listed == "":
+			listed = ip
+		case ip != listed:
+			e.audit(AuditSpoofingDetected, in, listed, ReasonRightmostUntrusted)
+			return listed, ReasonRightmostUntrusted, ErrSpoofingDetected
+		}
+		if client == "" {
+			client, reason = ip, ReasonRightmostUntrusted
+		}
+	}
+
+	return client, reason, nil
+}
+
+// clientFromChain returns the client address of a forwarding header, which
+// reverse walks from right to left. With a number of trusted hops
+// configured, that many addresses are skipped from the right. Otherwise it
+// is the rightmost address that is not a trusted proxy, or the leftmost one
+// if every address is trusted. An entry that is not a valid address ends the
+// walk: the leftmost trusted address walked, if any, is returned, and never
+// an address to the left of the entry.
+//
+// With internal proxies configured, a private address presented by a
+// trusted proxy that is not internal is rejected, and that proxy is the
+// client instead, following Apache mod_remoteip.
+//
+// The walk stops as soon as the client is found, so addresses to its left,
+// which are controlled by the client, are never parsed.
+func (e *Extractor) clientFromChain(in input, reverse func(input, func(string) bool) bool) string {
+	var client, leftmost string
+	hops, presenter := 0, remoteIP(in)
+	reverse(in, func(address string) bool {
+		// A hop that does not parse was not recorded by a trusted proxy, so
+		// the walk ends there rather than reaching the client's entries
+		ip, err := parseAddress(address)
+		if err != nil {
+			return true
+		}
+
+		switch {
+		case e.internal != nil && !e.isInternal(presenter) && containsAddress(e.private, ip):
+			client = presenter
+		case e.trustedHops > 0 && hops == e.trustedHops:
+			client = address
+		case e.trustedHops == 0 && !e.isTrusted(address):
+			client = address
+		default:
+			hops++
+			leftmost, presenter = address, address
+			return false
+		}
+		return true
+	})
+
+	if client == "" && e.trustedHops == 0 {
+		return leftmost
+	}
+
+	return client
+}
+
+// rejectedPrefix returns the valid addresses to the left of client in the
+// first list or Forwarded header of the header chain yielding it, in header
+// order, or nil if there are none.
+func (e *Extractor) rejectedPrefix(in input, client string) []string {
+	for _, spec := range e.headerChain {
+		if spec.Kind == HeaderSingle {
+			continue
+		}
+
+		walker := e.reverseWalker(spec)
+		if e.trustBoundary {
+			walker = e.boundaryWalker(walker)
+		}
+		if e.clientFromChain(in, walker) == client {
+			return leftOf(in, walker, client)
+		}
+	}
+
+	return nil
+}
+
+// leftOf returns the valid addresses reverse walks to the left of the
+// rightmost occurrence of client, in header order, or nil if there are none.
+func leftOf(in input, reverse func(input, func(string) bool) bool, client string) []string {
+	var prefix []string
+	found := false
+	reverse(in, func(address string) bool {
+		if _, err := parseAddress(address); err != nil {
+			return false
+		}
+		if found {
+			prefix = append(prefix, address)
+		}
+		found = found || address == client
+		return false
+	})
+
+	for i, j := 0, len(prefix)-1; i < j; i, j = i+1, j-1 {
+		prefix[i], prefix[j] = prefix[j], prefix[i]
+	}
+
+	return prefix
+}
+
+// boundaryWalker returns a reverse walker that skips the addresses to the
+// right of the rightmost trusted address, the trust boundary, so addresses
+// appended after a trusted proxy are never mistaken for the client. Without a
+// trusted address in the chain, the peer is the boundary and nothing is
+// skipped.
+func (e *Extractor) boundaryWalker(reverse func(input, func(string) bool) bool) func(input, func(string) bool) bool {
+	return func(in input, fn func(address string) bool) bool {
+		if !reverse(in, e.isTrusted) {
+			return reverse(in, fn)
+		}
+
+		crossed := false
+		return reverse(in, func(address string) bool {
+			crossed = crossed || e.isTrusted(address)
+			return crossed && fn(address)
+		})
+	}
+}
+
+// walkXForwardedForReverse calls fn with every address of the X-Forwarded-For
+// header, from the closest proxy to the client, until fn returns true, and
+// reports whether it did.
+func (e *Extractor) walkXForwardedForReverse(in input, fn func(address string) bool) bool {
+	return e.walkListReverse(e.values(in, xForwardedForHeader), fn)
+}
+
+// walkListReverse calls fn with every address of the lines of a comma
+// separated list header, from the closest proxy to the client, until fn
+// returns true, and reports whether it did. The walk ends at a hidden node.
+func (e *Extractor) walkListReverse(lines []string, fn func(address string) bool) bool {
+	if e.xffOrder == NewestFirst {
+		return e.scanList(lines, true, fn)
+	}
+
+	return e.scanListReverse(lines, true, fn)
+}
+
+// scanListReverse calls fn with every address of the lines of a comma
+// separated list header, from right to left, until fn returns true, and
+// reports whether it did. The lines are scanned in place, without splitting
+// them, and at most the maximum chain length of entries are scanned. Hidden
+// nodes are skipped, or end the scan when stopAtHidden is set.
+func (e *Extractor) scanListReverse(lines []string, stopAtHidden bool, fn func(address string) bool) bool {
+	n := 0
+	for i := len(lines) - 1; i >= 0; i-- {
+		line := lines[i]
+		for end := len(line); end >= 0; n++ {
+			if e.exceedsChainLength(n) {
+				return false
+			}
+			start := strings.LastIndexAny(line[:end], listSeparators) + 1
+			if stop, found := e.visit(strings.TrimSpace(line[start:end]), stopAtHidden, fn); stop {
+				return found
+			}
+			end = start - 1
+		}
+	}
+
+	return false
+}
+
+// walkForwardedReverse calls fn with every for address of the Forwarded
+// header, from right to left, until fn returns true, and reports whether it
+// did.
+func (e *Extractor) walkForwardedReverse(in input, fn func(address string) bool) bool {
+	return e.walkForwardedLinesReverse(e.values(in, forwardedHeader), fn)
+}
+
+// walkForwardedLinesReverse calls fn with every for address of the lines of
+// a Forwarded header, from the last line to the first and from right to
+// left, until fn returns true, and reports whether it did. Only the nodes
+// closest to the server, up to the maximum chain length, are considered, and
+// the walk ends at a hidden node.
+func (e *Extractor) walkForwardedLinesReverse(lines []string, fn func(address string) bool) bool {
+	n := 0
+	for i := len(lines) - 1; i >= 0 && !e.exceedsChainLength(n); i-- {
+		names := e.lastForwardedNames(lines[i], n)
+		for j := len(names) - 1; j >= 0; j-- {
+			n++
+			if stop, found := e.visit(names[j], true, fn); stop {
+				return found
+			}
+		}
+	}
+
+	return false
+}
+
+// lastForwardedNames returns the unparsed addresses of the for nodes of a
+// line of a Forwarded header, in order. When n nodes closer to the server
+// were already walked, only the last nodes up to the maximum chain length are
+// kept, so a huge line is not collected in full.
+func (e *Extractor) lastForwardedNames(line string, n int) []string {
+	if e.maxChainLength <= 0 {
+		var names []string
+		e.eachForwardedLineName(line, func(name string) bool {
+			names = append(names, name)
+			return false
+		})
+		return names
+	}
+
+	// names is a ring buffer of the last limit nodes, the oldest at next
+	limit, next := e.maxChainLength-n, 0
+	names := make([]string, 0, limit)
+	e.eachForwardedLineName(line, func(name string) bool {
+		if len(names) < limit {
+			names = append(names, name)
+		} else {
+			names[next] = name
+			next = (next + 1) % limit
+		}
+		return false
+	})
+
+	return append(names[next:], names[:next]...)
+}
@@ -0,0 +1,338 @@
+package realip
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestFromRequestETrusted(t *testing.T) {
+	_, trusted, _ := net.ParseCIDR("10.0.0.0/8")
+	e := New(WithTrustedProxies(trusted))
+
+	testData := []struct {
+		name     string
+		request  *http.Request
+		expected string
+		err      error
+	}{
+		{
+			name: "Trusted peer",
+			request: &http.Request{RemoteAddr: "10.0.0.1:8080", Header: http.Header{
+				"X-Forwarded-For": {"1.2.3.4, 144.12.54.87, 10.0.0.2"},
+			}},
+			expected: "144.12.54.87",
+		}, {
+			name: "Untrusted public peer",
+			request: &http.Request{RemoteAddr: "119.14.55.11:8080", Header: http.Header{
+				"X-Forwarded-For": {"144.12.54.87"},
+			}},
+			expected: "119.14.55.11",
+		}, {
+			name: "Untrusted private peer",
+			request: &http.Request{RemoteAddr: "192.168.1.1:8080", Header: http.Header{
+				"X-Forwarded-For": {"144.12.54.87"},
+			}},
+			err: ErrUntrustedPeer,
+		}, {
+			name: "Contradicting headers",
+			request: &http.Request{RemoteAddr: "10.0.0.1:8080", Header: http.Header{
+				"X-Forwarded-For": {"144.12.54.87"},
+				"Forwarded":       {"for=119.14.55.11"},
+			}},
+			err: ErrSpoofingDetected,
+		}, {
+			name: "Unix socket peer",
+			request: &http.Request{RemoteAddr: "", Header: http.Header{
+				"X-Forwarded-For": {"144.12.54.87"},
+			}},
+			err: ErrUntrustedPeer,
+		},
+	}
+
+	for _, v := range testData {
+		actual, err := e.FromRequestE(v.request)
+		if actual != v.expected || !errors.Is(err, v.err) {
+			t.Errorf("%s: expected %s (%v) but get %s (%v)", v.name, v.expected, v.err, actual, err)
+		}
+	}
+
+	r := &http.Request{Header: http.Header{"X-Forwarded-For": {"10.0.0.1"}}}
+	if actual, err := FromRequestE(r); !errors.Is(err, ErrNoValidIP) {
+		t.Errorf("No public address: expected %v but get %s (%v)", ErrNoValidIP, actual, err)
+	}
+}
+
+func TestTrustedMixedPrivatePublicHops(t *testing.T) {
+	// client -> CDN (public) -> internal LB (private) -> app
+	r := &http.Request{RemoteAddr: "10.0.0.5:8080", Header: http.Header{
+		"X-Forwarded-For": {"1.2.3.4, 144.12.54.87, 13.182.55.11, 10.0.0.5"},
+	}}
+
+	_, cdn, _ := net.ParseCIDR("13.182.0.0/16")
+	_, lb, _ := net.ParseCIDR("10.0.0.0/8")
+
+	testData := []struct {
+		name      string
+		extractor *Extractor
+	}{
+		{name: "Trusted hops", extractor: New(WithTrustedHops(2))},
+		{name: "Trusted proxies", extractor: New(WithTrustedProxies(cdn, lb))},
+		{name: "Trusted proxies and hops", extractor: New(WithTrustedProxies(cdn, lb), WithTrustedHops(2))},
+	}
+
+	for _, v := range testData {
+		if actual := v.extractor.FromRequest(r); actual != "144.12.54.87" {
+			t.Errorf("%s: expected %s but get %s", v.name, "144.12.54.87", actual)
+		}
+	}
+
+	short := &http.Request{RemoteAddr: "10.0.0.5:8080", Header: http.Header{
+		"X-Forwarded-For": {"13.182.55.11, 10.0.0.5"},
+	}}
+	if actual := New(WithTrustedHops(2)).FromRequest(short); actual != "10.0.0.5" {
+		t.Errorf("Chain too short: expected %s but get %s", "10.0.0.5", actual)
+	}
+}
+
+func TestTrustedMalformedHop(t *testing.T) {
+	_, trusted, _ := net.ParseCIDR("10.0.0.0/8")
+	proxies := New(WithTrustedProxies(trusted))
+	hops := New(WithTrustedHops(1))
+
+	testData := []struct {
+		name      string
+		extractor *Extractor
+		header    http.Header
+		expected  string
+	}{
+		{name: "Garbage terminus", extractor: proxies, header: http.Header{"X-Forwarded-For": {"6.6.6.6, garbage"}}, expected: "10.0.0.1"},
+		{name: "Garbage behind a trusted hop", extractor: proxies, header: http.Header{"X-Forwarded-For": {"6.6.6.6, garbage, 10.0.0.2"}}, expected: "10.0.0.2"},
+		{name: "Garbage after the client", extractor: proxies, header: http.Header{"X-Forwarded-For": {"garbage, 144.12.54.87, 10.0.0.2"}}, expected: "144.12.54.87"},
+		{name: "Garbage line", extractor: proxies, header: http.Header{"X-Forwarded-For": {"6.6.6.6", "1.2.3.4.5"}}, expected: "10.0.0.1"},
+		{name: "Garbage Forwarded node", extractor: proxies, header: http.Header{"Forwarded": {"for=6.6.6.6, for=garbage"}}, expected: "10.0.0.1"},
+		{name: "Garbage within trusted hops", extractor: hops, header: http.Header{"X-Forwarded-For": {"6.6.6.6, 144.12.54.87, garbage"}}, expected: "10.0.0.1"},
+	}
+
+	for _, v := range testData {
+		r := &http.Request{RemoteAddr: "10.0.0.1:8080", Header: v.header}
+		if actual := v.extractor.FromRequest(r); actual != v.expected {
+			t.Errorf("%s: expected %s but get %s", v.name, v.expected, actual)
+		}
+	}
+}
+
+func TestDuplicatePublicAddresses(t *testing.T) {
+	_, private, _ := net.ParseCIDR("10.0.0.0/8")
+	_, duplicate, _ := net.ParseCIDR("144.12.54.87/32")
+
+	// The chain holds the same public address at index 0 and 2
+	newRequest := func(remoteAddr string) *http.Request {
+		return &http.Request{RemoteAddr: remoteAddr, Header: http.Header{
+			"X-Forwarded-For": {"144.12.54.87, 10.0.0.1, 144.12.54.87"},
+		}}
+	}
+
+	testData := []struct {
+		name      string
+		extractor *Extractor
+		request   *http.Request
+		expected  string
+	}{
+		{name: "Leftmost public (index 0)", extractor: New(), request: newRequest("10.0.0.2:80"), expected: "144.12.54.87"},
+		{name: "Rightmost untrusted (index 2)", extractor: New(WithTrustedProxies(private)), request: newRequest("10.0.0.2:80"), expected: "144.12.54.87"},
+		{name: "One trusted hop (index 1)", extractor: New(WithTrustedHops(1)), request: newRequest("10.0.0.2:80"), expected: "10.0.0.1"},
+		{name: "Two trusted hops (index 0)", extractor: New(WithTrustedHops(2)), request: newRequest("10.0.0.2:80"), expected: "144.12.54.87"},
+		{name: "Trusted duplicate (index 1)", extractor: New(WithTrustedProxies(duplicate)), request: newRequest("144.12.54.87:80"), expected: "10.0.0.1"},
+	}
+
+	for _, v := range testData {
+		for i := 0; i < 3; i++ {
+			if actual := v.extractor.FromRequest(v.request); actual != v.expected {
+				t.Errorf("%s: expected %s but get %s", v.name, v.expected, actual)
+			}
+		}
+	}
+}
+
+func TestWithXFFOrder(t *testing.T) {
+	_, trusted, _ := net.ParseCIDR("10.0.0.0/8")
+	r := &http.Request{RemoteAddr: "10.0.0.1:8080", Header: http.Header{
+		"X-Forwarded-For": {"10.0.0.3, 10.0.0.2, 144.12.54.87, 1.2.3.4"},
+	}}
+
+	testData := []struct {
+		name      string
+		extractor *Extractor
+		expected  string
+	}{
+		{name: "Newest first trusted", extractor: New(WithTrustedProxies(trusted), WithXFFOrder(NewestFirst)), expected: "144.12.54.87"},
+		{name: "Oldest first trusted", extractor: New(WithTrustedProxies(trusted), WithXFFOrder(OldestFirst)), expected: "1.2.3.4"},
+		{name: "Newest first hops", extractor: New(WithTrustedHops(2), WithXFFOrder(NewestFirst)), expected: "144.12.54.87"},
+		{name: "Newest first untrusted", extractor: New(WithXFFOrder(NewestFirst)), expected: "1.2.3.4"},
+		{name: "Default", extractor: New(), expected: "144.12.54.87"},
+	}
+
+	for _, v := range testData {
+		if actual := v.extractor.FromRequest(r); actual != v.expected {
+			t.Errorf("%s: expected %s but get %s", v.name, v.expected, actual)
+		}
+	}
+}
+
+func TestWithTrustBoundary(t *testing.T) {
+	_, lb, _ := net.ParseCIDR("10.0.0.0/8")
+	_, cdn, _ := net.ParseCIDR("13.182.0.0/16")
+	e := New(WithTrustedProxies(lb, cdn), WithTrustBoundary())
+
+	testData := []struct {
+		name     string
+		xff      string
+		expected string
+	}{
+		{name: "Injected after the CDN", xff: "144.12.54.87, 13.182.1.1, 119.14.55.11", expected: "144.12.54.87"},
+		{name: "Several trusted proxies", xff: "1.2.3.4, 144.12.54.87, 13.182.1.2, 13.182.1.1, 119.14.55.11", expected: "144.12.54.87"},
+		{name: "No trusted entry", xff: "144.12.54.87, 119.14.55.11", expected: "119.14.55.11"},
+		{name: "Trusted entry last", xff: "144.12.54.87, 10.0.0.2", expected: "144.12.54.87"},
+
+		// The documented limitation: a client bypassing the CDN plants a
+		// boundary, and its real address is skipped as garbage
+		{name: "Planted boundary", xff: "6.6.6.6, 13.182.0.1, 119.14.55.11", expected: "6.6.6.6"},
+	}
+
+	for _, v := range testData {
+		r := &http.Request{RemoteAddr: "10.0.0.1:8080", Header: http.Header{"X-Forwarded-For": {v.xff}}}
+		if actual := e.FromRequest(r); actual != v.expected {
+			t.Errorf("%s: expected %s but get %s", v.name, v.expected, actual)
+		}
+	}
+
+	r := &http.Request{RemoteAddr: "10.0.0.1:8080", Header: http.Header{"X-Forwarded-For": {testData[0].xff}}}
+	if actual := New(WithTrustedProxies(lb, cdn)).FromRequest(r); actual != "119.14.55.11" {
+		t.Errorf("Default: expected %s but get %s", "119.14.55.11", actual)
+	}
+}
+
+func TestWithClientRanges(t *testing.T) {
+	_, clients, _ := net.ParseCIDR("144.12.0.0/16")
+	e := New(WithClientRanges([]*net.IPNet{clients}))
+
+	testData := []struct {
+		name     string
+		request  *http.Request
+		expected string
+	}{
+		{
+			name: "Closest client entry",
+			request: &http.Request{RemoteAddr: "10.0.0.1:8080", Header: http.Header{
+				"X-Forwarded-For": {"144.12.1.1, 144.12.54.87, 119.14.55.11, 10.0.0.2"},
+			}},
+			expected: "144.12.54.87",
+		}, {
+			name: "Forwarded entry",
+			request: &http.Request{RemoteAddr: "10.0.0.1:8080", Header: http.Header{
+				"X-Forwarded-For": {"119.14.55.11"},
+				"Forwarded":       {"for=144.12.54.87, for=10.0.0.2"},
+			}},
+			expected: "144.12.54.87",
+		}, {
+			name: "Client peer",
+			request: &http.Request{RemoteAddr: "144.12.54.87:8080", Header: http.Header{
+				"X-Forwarded-For": {"144.12.1.1"},
+			}},
+			expected: "144.12.54.87",
+		}, {
+			name: "No client entry",
+			request: &http.Request{RemoteAddr: "10.0.0.1:8080", Header: http.Header{
+				"X-Forwarded-For": {"119.14.55.11, 10.0.0.2"},
+				"X-Real-Ip":       {"13.182.55.11"},
+			}},
+			expected: "",
+		},
+	}
+
+	for _, v := range testData {
+		if actual := e.FromRequest(v.request); actual != v.expected {
+			t.Errorf("%s: expected %s but get %s", v.name, v.expected, actual)
+		}
+	}
+
+	if _, err := e.FromRequestE(testData[3].request); !errors.Is(err, ErrNoValidIP) {
+		t.Errorf("No client entry: expected %v but get %v", ErrNoValidIP, err)
+	}
+}
+
+func BenchmarkTrustedWalkLongChain(b *testing.B) {
+	_, trusted, _ := net.ParseCIDR("10.0.0.0/8")
+	e := New(WithTrustedProxies(trusted))
+
+	chain := make([]string, 0, 1003)
+	for i := 0; i < 1000; i++ {
+		chain = append(chain, "1.2.3.4")
+	}
+	chain = append(chain, "144.12.54.87", "10.0.0.3", "10.0.0.2")
+	r := &http.Request{RemoteAddr: "10.0.0.1:8080", Header: http.Header{
+		"X-Forwarded-For": {strings.Join(chain, ", ")},
+	}}
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if ip := e.FromRequest(r); ip != "144.12.54.87" {
+			b.Fatalf("expected %s but get %s", "144.12.54.87", ip)
+		}
+	}
+}
+
+type stubResolver map[string][]string
+
+func (s stubResolver) LookupIPAddr(_ context.Context, host string) ([]net.IPAddr, error) {
+	ips, ok := s[host]
+	if !ok {
+		return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+
+	addrs := make([]net.IPAddr, len(ips))
+	for i, ip := range ips {
+		addrs[i] = net.IPAddr{IP: net.ParseIP(ip)}
+	}
+	return addrs, nil
+}
+
+func TestWithTrustedProxyHostnames(t *testing.T) {
+	resolver := stubResolver{
+		"lb.internal":  {"10.0.0.5", "fd00::5"},
+		"cdn.internal": {"13.182.55.11"},
+	}
+
+	opt, err := WithTrustedProxyHostnames(context.Background(), []string{"lb.internal", "cdn.internal"}, resolver)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	e := New(opt)
+
+	testData := []struct {
+		name       string
+		remoteAddr string
+		expected   string
+	}{
+		{name: "Trusted IPv4", remoteAddr: "10.0.0.5:8080", expected: "144.12.54.87"},
+		{name: "Trusted IPv6", remoteAddr: "[fd00::5]:8080", expected: "144.12.54.87"},
+		{name: "Neighbour of trusted", remoteAddr: "10.0.0.6:8080", expected: "10.0.0.6"},
+	}
+
+	for _, v := range testData {
+		r := &http.Request{RemoteAddr: v.remoteAddr, Header: http.Header{
+			"X-Forwarded-For": {"144.12.54.87, 13.182.55.11"},
+		}}
+		if actual := e.FromRequest(r); actual != v.expected {
+			t.Errorf("%s: expected %s but get %s", v.name, v.expected, actual)
+		}
+	}
+
+	if _, err := WithTrustedProxyHostnames(context.Background(), []string{"missing.internal"}, resolver); err == nil {
+		t.Errorf("unresolvable: expected an error")
+	}
+}
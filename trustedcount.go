@@ -0,0 +1,49 @@
+package realip
+
+import "net/http"
+
+// FromRequestTrustedCount returns the client address of a request relayed by
+// exactly n trusted proxies: the remote address and the X-Forwarded-For
+// entries form a chain, from which the n rightmost addresses are skipped,
+// and the next one is returned. With n = 0, or when the chain has no more
+// than n addresses, the remote address is returned. Malformed entries are
+// not counted.
+//
+// Unlike WithTrustedHops, n counts the peer of the connection as one of the
+// proxies, so FromRequestTrustedCount(r, n+1) resolves X-Forwarded-For
+// like an Extractor created with WithTrustedHops(n).
+//
+// Counting hops needs no knowledge of the proxies' addresses, but it is only
+// safe as long as n matches the deployment exactly: with fewer proxies in
+// front of the application than n, the result is an address the client
+// wrote itself, and with more, it is one of the proxies. Unlike an allowlist
+// of trusted proxies, requests that bypass the proxies and reach the
+// application directly are not detected either. Prefer WithTrustedProxies
+// when the proxies' addresses are known.
+func (e *Extractor) FromRequestTrustedCount(r *http.Request, n int) string {
+	in := requestInput(r)
+	remote := remoteIP(in)
+	ip, hops := remote, 1
+	if n > 0 {
+		e.walkXForwardedForReverse(in, func(address string) bool {
+			if _, err := parseAddress(address); err != nil {
+				return false
+			}
+			if hops == n {
+				ip = address
+				return true
+			}
+			hops++
+			return false
+		})
+	}
+
+	return e.finish(ip)
+}
+
+// FromRequestTrustedCount returns the client address of a request relayed by
+// exactly n trusted proxies, skipping the n rightmost addresses of the chain
+// formed by the remote address and X-Forwarded-For.
+func FromRequestTrustedCount(r *http.Request, n int) string {
+	return defaultExtractor.FromRequestTrustedCount(r, n)
+}
@@ -0,0 +1,41 @@
+package realip
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestFromRequestTrustedCount(t *testing.T) {
+	r := &http.Request{RemoteAddr: "10.0.0.1:8080", Header: http.Header{
+		"X-Forwarded-For": {"1.2.3.4, 144.12.54.87", "garbage, 119.14.55.11"},
+	}}
+
+	testData := map[int]string{
+		-1: "10.0.0.1",
+		0:  "10.0.0.1",
+		1:  "119.14.55.11",
+		2:  "144.12.54.87",
+		3:  "1.2.3.4",
+		4:  "10.0.0.1",
+		10: "10.0.0.1",
+	}
+
+	for n, expected := range testData {
+		if actual := FromRequestTrustedCount(r, n); actual != expected {
+			t.Errorf("%d: expected %s but get %s", n, expected, actual)
+		}
+	}
+
+	r = &http.Request{RemoteAddr: "[2a00:1450::1]:443", Header: http.Header{}}
+	if actual := FromRequestTrustedCount(r, 1); actual != "2a00:1450::1" {
+		t.Errorf("No header: expected %s but get %s", "2a00:1450::1", actual)
+	}
+
+	r = &http.Request{RemoteAddr: "10.0.0.1:8080", Header: http.Header{"X-Forwarded-For": {"1.2.3.4, 144.12.54.87, 119.14.55.11"}}}
+	for n := 1; n <= 2; n++ {
+		expected := New(WithTrustedHops(n)).FromRequest(r)
+		if actual := FromRequestTrustedCount(r, n+1); actual != expected {
+			t.Errorf("WithTrustedHops(%d): expected %s but get %s", n, expected, actual)
+		}
+	}
+}
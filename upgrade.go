@@ -0,0 +1,36 @@
+package realip
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+)
+
+// FromUpgradeRequest returns the public client address of the handshake
+// request of a WebSocket or other upgraded connection, to be stored once for
+// the lifetime of the connection, as the remote address of the hijacked
+// net.Conn is the closest proxy rather than the client. It only reads the
+// headers and RemoteAddr of the request, so it is safe to call before the
+// body is read and before the connection is upgraded.
+//
+// It is the same as FromRequestE, except that a resolved address that is not
+// public, e.g. a private X-Real-IP or RemoteAddr, yields an error wrapping
+// ErrNoValidIP instead of being returned.
+func (e *Extractor) FromUpgradeRequest(r *http.Request) (string, error) {
+	ip, err := e.FromRequestE(r)
+	if err != nil {
+		return "", err
+	}
+	if net.ParseIP(ip) != nil && !e.isPublic(ip) {
+		return "", fmt.Errorf("%w: %s is not public", ErrNoValidIP, ip)
+	}
+
+	return ip, nil
+}
+
+// FromUpgradeRequest returns the public client address of the handshake
+// request of a WebSocket or other upgraded connection, or an error if it
+// can't be determined.
+func FromUpgradeRequest(r *http.Request) (string, error) {
+	return defaultExtractor.FromUpgradeRequest(r)
+}
@@ -0,0 +1,49 @@
+package realip
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"testing"
+)
+
+func TestFromUpgradeRequest(t *testing.T) {
+	_, proxies, _ := net.ParseCIDR("10.0.0.0/8")
+	trusted := NewExtractor([]*net.IPNet{proxies})
+
+	testData := []struct {
+		name       string
+		extractor  *Extractor
+		remoteAddr string
+		header     http.Header
+		expected   string
+		err        error
+	}{
+		{name: "X-Forwarded-For", extractor: defaultExtractor, remoteAddr: "10.0.0.1:8080", header: http.Header{"X-Forwarded-For": {"10.0.0.2, 144.12.54.87"}}, expected: "144.12.54.87"},
+		{name: "Public peer", extractor: defaultExtractor, remoteAddr: "144.12.54.87:8080", header: http.Header{}, expected: "144.12.54.87"},
+		{name: "Private peer", extractor: defaultExtractor, remoteAddr: "10.0.0.1:8080", header: http.Header{}, err: ErrNoValidIP},
+		{name: "Private X-Real-IP", extractor: defaultExtractor, remoteAddr: "10.0.0.1:8080", header: http.Header{"X-Real-Ip": {"192.168.0.1"}}, err: ErrNoValidIP},
+		{name: "No public entry", extractor: defaultExtractor, remoteAddr: "10.0.0.1:8080", header: http.Header{"X-Forwarded-For": {"10.0.0.2"}}, err: ErrNoValidIP},
+		{name: "Trusted proxy", extractor: trusted, remoteAddr: "10.0.0.1:8080", header: http.Header{"X-Forwarded-For": {"1.2.3.4, 144.12.54.87"}}, expected: "144.12.54.87"},
+		{name: "Untrusted private peer", extractor: trusted, remoteAddr: "192.168.0.1:8080", header: http.Header{"X-Forwarded-For": {"144.12.54.87"}}, err: ErrUntrustedPeer},
+	}
+
+	for _, v := range testData {
+		r := &http.Request{Method: http.MethodGet, RemoteAddr: v.remoteAddr, Header: v.header}
+		r.Header.Set("Connection", "Upgrade")
+		r.Header.Set("Upgrade", "websocket")
+
+		actual, err := v.extractor.FromUpgradeRequest(r)
+		if !errors.Is(err, v.err) {
+			t.Errorf("%s: expected %v but get %v", v.name, v.err, err)
+		}
+		if actual != v.expected {
+			t.Errorf("%s: expected %s but get %s", v.name, v.expected, actual)
+		}
+	}
+
+	r := &http.Request{RemoteAddr: "10.0.0.1:8080", Header: http.Header{"X-Forwarded-For": {"144.12.54.87"}}}
+	if actual, err := FromUpgradeRequest(r); err != nil || actual != "144.12.54.87" {
+		t.Errorf("package FromUpgradeRequest: expected %s but get %s, %v", "144.12.54.87", actual, err)
+	}
+}
@@ -0,0 +1,81 @@
+package realip
+
+import (
+	"fmt"
+	"net/netip"
+	"strings"
+)
+
+// NewStrict returns an Extractor configured with the given options, like New,
+// and an error if the configuration is unsafe, as reported by Validate.
+func NewStrict(opts ...Option) (*Extractor, error) {
+	e := New(opts...)
+	if err := e.Validate(); err != nil {
+		return nil, err
+	}
+
+	return e, nil
+}
+
+// Validate checks the configuration of the Extractor for mistakes that
+// defeat spoofing protection. It returns an error wrapping ErrPublicTrust if
+// a trusted proxy range overlaps public address space, e.g. 0.0.0.0/0, as any
+// client could then pose as a proxy, unless WithAllowPublicTrust is set.
+func (e *Extractor) Validate() error {
+	if e.allowPublicTrust {
+		return nil
+	}
+
+	for _, prefix := range e.trusted {
+		if !e.isNonPublicPrefix(prefix) {
+			return fmt.Errorf("%w: %s", ErrPublicTrust, prefix)
+		}
+	}
+
+	return nil
+}
+
+// isNonPublicPrefix reports whether prefix lies entirely within the private
+// or non-routable blocks.
+func (e *Extractor) isNonPublicPrefix(prefix netip.Prefix) bool {
+	for _, blocks := range [][]netip.Prefix{e.private, nonRoutableCidrs} {
+		for _, block := range blocks {
+			if block.Bits() <= prefix.Bits() && block.Contains(prefix.Addr()) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// validateHeaders checks the forwarding headers for signs of tampering in
+// validation mode.
+func (e *Extractor) validateHeaders(in input) error {
+	for _, spec := range e.headerChain {
+		if spec.Kind == HeaderSingle {
+			if err := e.validateSingle(in, spec.Name); err != nil {
+				return err
+			}
+		}
+	}
+	if e.fallbackHeader != "" {
+		return e.validateSingle(in, e.fallbackHeader)
+	}
+
+	return nil
+}
+
+// validateSingle checks the named single address header. Holding a list, it
+// was not set by a proxy, which would overwrite it, but appended to by the
+// client. Headers the Extractor ignores are not checked.
+func (e *Extractor) validateSingle(in input, name string) error {
+	for _, line := range e.values(in, name) {
+		if strings.IndexByte(line, ',') >= 0 {
+			e.audit(AuditSpoofingDetected, in, "", ReasonNoCandidate)
+			return fmt.Errorf("%w: %s holds several values", ErrSpoofingDetected, name)
+		}
+	}
+
+	return nil
+}
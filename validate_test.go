@@ -0,0 +1,109 @@
+package realip
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"testing"
+)
+
+func TestWithValidationSingleValueHeader(t *testing.T) {
+	r := &http.Request{RemoteAddr: "10.0.0.1:8080", Header: http.Header{
+		"X-Real-Ip": {"203.0.113.5, 6.6.6.6"},
+	}}
+
+	if actual, err := New(WithValidation()).FromRequestE(r); !errors.Is(err, ErrSpoofingDetected) {
+		t.Errorf("validation: expected %v but get %s (%v)", ErrSpoofingDetected, actual, err)
+	}
+	if actual := New(WithValidation()).FromRequest(r); actual != "" {
+		t.Errorf("validation: expected empty result but get %s", actual)
+	}
+
+	r.Header.Set("X-Real-Ip", "203.0.113.5")
+	if actual, err := New(WithValidation()).FromRequestE(r); actual != "203.0.113.5" || err != nil {
+		t.Errorf("validation single value: expected %s but get %s (%v)", "203.0.113.5", actual, err)
+	}
+
+	r = &http.Request{RemoteAddr: "10.0.0.1:8080", Header: http.Header{
+		"X-Real-Ip":       {"203.0.113.5, 6.6.6.6"},
+		"X-Client-Ip":     {"203.0.113.5, 6.6.6.6"},
+		"X-Forwarded-For": {"144.12.54.87"},
+	}}
+	if actual, err := New(WithValidation(), WithoutXRealIP()).FromRequestE(r); actual != "144.12.54.87" || err != nil {
+		t.Errorf("validation ignored header: expected %s but get %s (%v)", "144.12.54.87", actual, err)
+	}
+	if actual, err := New(WithValidation(), WithHeader(XClientIPHeader, HeaderSingle), WithoutXRealIP()).FromRequestE(r); !errors.Is(err, ErrSpoofingDetected) {
+		t.Errorf("validation custom header: expected %v but get %s (%v)", ErrSpoofingDetected, actual, err)
+	}
+}
+
+func TestWithValidationFallbackHeader(t *testing.T) {
+	e := New(WithValidation(), WithFallbackHeader("X-Custom"))
+
+	r := &http.Request{RemoteAddr: "10.0.0.1:8080", Header: http.Header{"X-Custom": {"203.0.113.5, 6.6.6.6"}}}
+	if actual, err := e.FromRequestE(r); !errors.Is(err, ErrSpoofingDetected) {
+		t.Errorf("list: expected %v but get %s (%v)", ErrSpoofingDetected, actual, err)
+	}
+
+	r = &http.Request{RemoteAddr: "10.0.0.1:8080", Header: http.Header{"X-Custom": {"203.0.113.5"}, "X-Real-Ip": {"1.2.3.4, 6.6.6.6"}}}
+	if actual, err := e.FromRequestE(r); actual != "203.0.113.5" || err != nil {
+		t.Errorf("replaced X-Real-IP: expected %s but get %s (%v)", "203.0.113.5", actual, err)
+	}
+}
+
+func TestNewStrict(t *testing.T) {
+	testData := []struct {
+		name  string
+		cidrs []string
+		opts  []Option
+		err   error
+	}{
+		{name: "Everything", cidrs: []string{"0.0.0.0/0"}, err: ErrPublicTrust},
+		{name: "Everything IPv6", cidrs: []string{"::/0"}, err: ErrPublicTrust},
+		{name: "Private and public", cidrs: []string{"10.0.0.0/8", "13.182.0.0/16"}, err: ErrPublicTrust},
+		{name: "Wider than private", cidrs: []string{"10.0.0.0/7"}, err: ErrPublicTrust},
+		{name: "Private", cidrs: []string{"10.0.0.0/8", "192.168.1.0/24", "fd00::/8"}},
+		{name: "Allowed public", cidrs: []string{"0.0.0.0/0"}, opts: []Option{WithAllowPublicTrust()}},
+	}
+
+	for _, v := range testData {
+		opts := v.opts
+		for _, cidr := range v.cidrs {
+			_, n, _ := net.ParseCIDR(cidr)
+			opts = append(opts, WithTrustedProxies(n))
+		}
+
+		e, err := NewStrict(opts...)
+		if !errors.Is(err, v.err) || (err == nil) != (e != nil) {
+			t.Errorf("%s: expected %v but get %v", v.name, v.err, err)
+		}
+		if err := New(opts...).Validate(); !errors.Is(err, v.err) {
+			t.Errorf("%s: Validate expected %v but get %v", v.name, v.err, err)
+		}
+	}
+}
+
+func TestWithValidationRemoteAddr(t *testing.T) {
+	e := New(WithValidation())
+
+	for _, remoteAddr := range []string{"not-an-address", "10.0.0.1:http", "10.0.0.1:99999", "[10.0.0.1]", "@"} {
+		r := &http.Request{RemoteAddr: remoteAddr, Header: http.Header{}}
+		if _, err := e.FromRequestE(r); err == nil || errors.Is(err, ErrNoValidIP) {
+			t.Errorf("%s: expected a RemoteAddr error but get %v", remoteAddr, err)
+		}
+		if _, err := New().FromRequestE(r); !errors.Is(err, ErrNoValidIP) {
+			t.Errorf("%s: without validation expected %v but get %v", remoteAddr, ErrNoValidIP, err)
+		}
+	}
+
+	var addrErr *net.AddrError
+	r := &http.Request{RemoteAddr: "not-an-address", Header: http.Header{}}
+	if _, err := e.FromRequestE(r); !errors.As(err, &addrErr) {
+		t.Errorf("expected a wrapped *net.AddrError but get %v", err)
+	}
+
+	r = &http.Request{RemoteAddr: "not-an-address", Header: http.Header{"X-Forwarded-For": {"144.12.54.87"}}}
+	if actual, err := e.FromRequestE(r); actual != "144.12.54.87" || err != nil {
+		t.Errorf("Valid header: expected %s but get %s (%v)", "144.12.54.87", actual, err)
+	}
+}
@@ -0,0 +1,58 @@
+package realip
+
+import (
+	"context"
+	"net/http"
+)
+
+// ResolveVerified resolves the client's IP address and checks it with the
+// verifier configured with WithForwardConfirmedRDNS, such as a forward
+// confirmed reverse DNS lookup for crawler verification. verified is false
+// when no verifier is configured, when verification fails, errors or exceeds
+// the maximum resolve time, and when no valid address could be resolved, in
+// which case ip is empty.
+func (e *Extractor) ResolveVerified(ctx context.Context, r *http.Request) (ip string, verified bool) {
+	ip, err := e.FromRequestE(r)
+	if err != nil {
+		return "", false
+	}
+	if e.verifier == nil {
+		return ip, false
+	}
+
+	verified, err = e.runHook(ctx, func(ctx context.Context) (bool, error) {
+		return e.verifier(ctx, ip)
+	})
+
+	return ip, verified && err == nil
+}
+
+// runHook runs a pluggable hook. With a maximum resolve time configured, the
+// hook gets a context with that deadline, and runHook returns the context's
+// error once it expires, even if the hook ignores its context and is still
+// running.
+func (e *Extractor) runHook(ctx context.Context, hook func(context.Context) (bool, error)) (bool, error) {
+	if e.maxResolveTime <= 0 {
+		return hook(ctx)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, e.maxResolveTime)
+	defer cancel()
+
+	type result struct {
+		ok  bool
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		ok, err := hook(ctx)
+		done <- result{ok, err}
+	}()
+
+	select {
+	case res := <-done:
+		return res.ok, res.err
+	case <-ctx.Done():
+		return false, ctx.Err()
+	}
+}
@@ -0,0 +1,74 @@
+package realip
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestResolveVerified(t *testing.T) {
+	verifier := func(ctx context.Context, ip string) (bool, error) {
+		switch ip {
+		case "66.249.66.1":
+			return true, nil
+		case "66.249.66.2":
+			return false, errors.New("lookup failed")
+		default:
+			return false, nil
+		}
+	}
+	e := New(WithForwardConfirmedRDNS(verifier))
+
+	testData := []struct {
+		name       string
+		extractor  *Extractor
+		remoteAddr string
+		ip         string
+		verified   bool
+	}{
+		{name: "Verified", extractor: e, remoteAddr: "66.249.66.1:80", ip: "66.249.66.1", verified: true},
+		{name: "Not verified", extractor: e, remoteAddr: "144.12.54.87:80", ip: "144.12.54.87", verified: false},
+		{name: "Verifier error", extractor: e, remoteAddr: "66.249.66.2:80", ip: "66.249.66.2", verified: false},
+		{name: "No verifier", extractor: New(), remoteAddr: "66.249.66.1:80", ip: "66.249.66.1", verified: false},
+		{name: "No address", extractor: e, remoteAddr: "@", ip: "", verified: false},
+	}
+
+	for _, v := range testData {
+		r := &http.Request{RemoteAddr: v.remoteAddr, Header: http.Header{}}
+		ip, verified := v.extractor.ResolveVerified(context.Background(), r)
+		if ip != v.ip || verified != v.verified {
+			t.Errorf("%s: expected (%s, %t) but get (%s, %t)", v.name, v.ip, v.verified, ip, verified)
+		}
+	}
+}
+
+func TestWithMaxResolveTime(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+
+	slow := func(ctx context.Context, ip string) (bool, error) {
+		<-release
+		return true, nil
+	}
+	e := New(WithForwardConfirmedRDNS(slow), WithMaxResolveTime(10*time.Millisecond))
+
+	r := &http.Request{RemoteAddr: "66.249.66.1:80", Header: http.Header{}}
+	start := time.Now()
+	ip, verified := e.ResolveVerified(context.Background(), r)
+	if ip != "66.249.66.1" || verified {
+		t.Errorf("expected (%s, false) but get (%s, %t)", "66.249.66.1", ip, verified)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expected the slow hook to be abandoned but it took %v", elapsed)
+	}
+
+	fast := func(ctx context.Context, ip string) (bool, error) {
+		return true, nil
+	}
+	e = New(WithForwardConfirmedRDNS(fast), WithMaxResolveTime(time.Second))
+	if ip, verified := e.ResolveVerified(context.Background(), r); ip != "66.249.66.1" || !verified {
+		t.Errorf("fast hook: expected (%s, true) but get (%s, %t)", "66.249.66.1", ip, verified)
+	}
+}
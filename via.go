@@ -0,0 +1,73 @@
+package realip
+
+import (
+	"net/http"
+	"strings"
+)
+
+// CountVia returns the number of intermediaries listed in the Via headers of
+// the request, across every header line, e.g. 2 for "1.1 proxy1, HTTP/1.1
+// proxy2". Each intermediary appends an entry made of the protocol it
+// received the request with, with or without the protocol name, and its own
+// name, optionally followed by a comment. Commas within comments don't split
+// entries, and empty or malformed entries are not counted.
+//
+// Comparing this number with the expected number of proxies is a sanity
+// check for FromRequestTrustedCount or WithTrustedHops. Like any header, Via
+// can be forged by the client, so a larger count only reveals a client
+// padding the chain or a proxy missing from the configuration.
+func CountVia(r *http.Request) int {
+	n := 0
+	for _, line := range r.Header["Via"] {
+		for _, entry := range splitVia(line) {
+			if isViaEntry(entry) {
+				n++
+			}
+		}
+	}
+
+	return n
+}
+
+// splitVia splits a line of a Via header on the commas that are not within a
+// comment.
+func splitVia(line string) []string {
+	var entries []string
+	depth, start := 0, 0
+	for i := 0; i < len(line); i++ {
+		switch line[i] {
+		case '(':
+			depth++
+		case ')':
+			if depth > 0 {
+				depth--
+			}
+		case ',':
+			if depth == 0 {
+				entries = append(entries, line[start:i])
+				start = i + 1
+			}
+		}
+	}
+
+	return append(entries, line[start:])
+}
+
+// isViaEntry reports whether entry, a Via element, holds a protocol version,
+// e.g. 1.1 or HTTP/1.1, followed by the name of the intermediary.
+func isViaEntry(entry string) bool {
+	fields := strings.Fields(entry)
+	if len(fields) < 2 || strings.HasPrefix(fields[1], "(") {
+		return false
+	}
+
+	protocol := fields[0]
+	if i := strings.IndexByte(protocol, '/'); i >= 0 {
+		if i == 0 {
+			return false
+		}
+		protocol = protocol[i+1:]
+	}
+
+	return protocol != "" && !strings.ContainsAny(protocol, "(),")
+}
@@ -0,0 +1,33 @@
+package realip
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestCountVia(t *testing.T) {
+	testData := []struct {
+		name     string
+		via      []string
+		expected int
+	}{
+		{name: "No header", expected: 0},
+		{name: "Version only form", via: []string{"1.1 proxy1"}, expected: 1},
+		{name: "Protocol name form", via: []string{"HTTP/1.1 proxy2"}, expected: 1},
+		{name: "Both forms", via: []string{"1.0 fred, 1.1 p.example.net"}, expected: 2},
+		{name: "Several lines", via: []string{"1.1 proxy1", "HTTP/1.1 proxy2, 2 cdn"}, expected: 3},
+		{name: "Comment with comma", via: []string{"1.1 varnish (Varnish/6.0, cache), 1.1 proxy1"}, expected: 2},
+		{name: "Empty elements", via: []string{", 1.1 proxy1,, "}, expected: 1},
+		{name: "Malformed entries", via: []string{"proxy1, /1.1 proxy2, 1.1 (comment), 1.1 proxy3"}, expected: 1},
+	}
+
+	for _, v := range testData {
+		r := &http.Request{Header: http.Header{}}
+		if v.via != nil {
+			r.Header["Via"] = v.via
+		}
+		if actual := CountVia(r); actual != v.expected {
+			t.Errorf("%s: expected %d but get %d", v.name, v.expected, actual)
+		}
+	}
+}